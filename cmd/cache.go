@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"github.com/marcosQuesada/githubTop/pkg/provider/cache"
+	"github.com/marcosQuesada/githubTop/pkg/storage"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"strings"
+	"time"
+)
+
+const snapshotPath = "/admin/cache/snapshot"
+
+var (
+	snapshotFile string
+	instanceURL  string
+	adminUser    string
+	adminPass    string
+	importMaxTTL time.Duration
+)
+
+// cacheCmd groups cache administration commands
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Cache administration",
+	Long: `Cache administration, snapshots are read from a running instance (--url)
+or straight from a persistent backend (--redis or --cache-file)`,
+}
+
+// cacheExportCmd dumps cache contents to a snapshot file
+var cacheExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export cache snapshot",
+	Long:  `Export cache entries, including its remaining TTL, to a portable snapshot file`,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := os.Stdout
+		if snapshotFile != "-" {
+			f, err := os.Create(snapshotFile)
+			if err != nil {
+				log.Fatalf("unexpected error creating snapshot file, error %v", err)
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			w = f
+		}
+
+		if instanceURL != "" {
+			cl := newAdminClient(instanceURL)
+			resp, err := cl.Get(strings.TrimRight(instanceURL, "/") + snapshotPath)
+			if err != nil {
+				log.Fatalf("unexpected error requesting snapshot, error %v", err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			if resp.StatusCode != http.StatusOK {
+				log.Fatalf("unexpected snapshot response status %d", resp.StatusCode)
+			}
+
+			if _, err := io.Copy(w, resp.Body); err != nil {
+				log.Fatalf("unexpected error writing snapshot, error %v", err)
+			}
+
+			return
+		}
+
+		c, terminate := newSnapshotBackend()
+		defer terminate()
+
+		n, err := cache.NewSnapshotter(c, cache.NewDefaultTypeRegistry(), 0).Export(context.Background(), w)
+		if err != nil {
+			log.Fatalf("unexpected error exporting snapshot, error %v", err)
+		}
+		log.Infof("Cache snapshot exported, total entries %d", n)
+	},
+}
+
+// cacheImportCmd restores cache contents from a snapshot file
+var cacheImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import cache snapshot",
+	Long:  `Import cache entries from a snapshot file, expired entries are skipped`,
+	Run: func(cmd *cobra.Command, args []string) {
+		r := os.Stdin
+		if snapshotFile != "-" {
+			f, err := os.Open(snapshotFile)
+			if err != nil {
+				log.Fatalf("unexpected error opening snapshot file, error %v", err)
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			r = f
+		}
+
+		if instanceURL != "" {
+			cl := newAdminClient(instanceURL)
+			resp, err := cl.Post(strings.TrimRight(instanceURL, "/")+snapshotPath, "application/x-ndjson", r)
+			if err != nil {
+				log.Fatalf("unexpected error sending snapshot, error %v", err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			if resp.StatusCode != http.StatusOK {
+				log.Fatalf("unexpected snapshot response status %d", resp.StatusCode)
+			}
+
+			res := struct{ Imported int }{}
+			if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				log.Fatalf("unexpected error decoding response, error %v", err)
+			}
+			log.Infof("Cache snapshot imported, total entries %d", res.Imported)
+
+			return
+		}
+
+		c, terminate := newSnapshotBackend()
+		defer terminate()
+
+		n, err := cache.NewSnapshotter(c, cache.NewDefaultTypeRegistry(), importMaxTTL).Import(context.Background(), r)
+		if err != nil {
+			log.Fatalf("unexpected error importing snapshot, error %v", err)
+		}
+		log.Infof("Cache snapshot imported, total entries %d", n)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheExportCmd)
+	cacheCmd.AddCommand(cacheImportCmd)
+
+	cacheImportCmd.Flags().DurationVar(&importMaxTTL, "max-ttl", cache.DefaultSnapshotMaxTTL, "Imported entries max TTL, longer remaining TTLs are clamped to it")
+
+	cacheCmd.PersistentFlags().StringVarP(&snapshotFile, "file", "f", "-", "Snapshot file, - for stdout / stdin")
+	cacheCmd.PersistentFlags().StringVar(&instanceURL, "url", "", "Running instance url, as http://localhost:8000")
+	cacheCmd.PersistentFlags().StringVar(&adminUser, "user", "", "Running instance user")
+	cacheCmd.PersistentFlags().StringVar(&adminPass, "pass", "", "Running instance password")
+	cacheCmd.PersistentFlags().StringVarP(&redisURL, "redis", "s", "", "Redis url if any (redis://, rediss://, ?sentinel=master, ?cluster=true)")
+	cacheCmd.PersistentFlags().StringVar(&redisPrefix, "redis-prefix", "", "Redis key prefix shared by all features, as running instances")
+	cacheCmd.PersistentFlags().StringVar(&redisCachePrefix, "redis-cache-prefix", "cache:", "Redis cache key prefix, after --redis-prefix")
+	cacheCmd.PersistentFlags().StringVar(&cacheFile, "cache-file", "", "Persistent on-disk cache file, instance must be stopped")
+	cacheCmd.PersistentFlags().StringVar(&cacheCodec, "cache-codec", "json", "Redis and on-disk cache codec (json, msgpack)")
+	cacheCmd.PersistentFlags().StringVar(&cacheCompression, "cache-compression", "none", "Redis and on-disk cache compression (none, gzip, snappy)")
+}
+
+// newSnapshotBackend opens persistent cache backend
+func newSnapshotBackend() (provider.Cache, func()) {
+	switch {
+	case redisURL != "":
+		cl, err := storage.NewRedisClient(context.Background(), redisURL)
+		if err != nil {
+			log.Fatalf("unexpected error connecting to redis, error %v", err)
+		}
+		c := cache.NewRedisWithConfig(cl, cache.RedisConfig{Prefix: redisPrefix + redisCachePrefix, Serializer: newSerializer()})
+
+		return c, func() { _ = cl.Close() }
+	case cacheFile != "":
+		c, err := cache.NewBolt(cacheFile, 0, time.Hour, newSerializer())
+		if err != nil {
+			log.Fatalf("unexpected error opening cache file, error %v", err)
+		}
+
+		return c, c.Terminate
+	}
+
+	log.Fatal("a running instance url or a persistent cache backend is required")
+	return nil, nil
+}
+
+// newSerializer builds cache serializer from flags
+func newSerializer() *cache.Serializer {
+	codec, err := cache.ParseCodec(cacheCodec)
+	if err != nil {
+		log.Fatalf("unexpected cache codec, error %v", err)
+	}
+	compression, err := cache.ParseCompression(cacheCompression)
+	if err != nil {
+		log.Fatalf("unexpected cache compression, error %v", err)
+	}
+
+	return cache.NewSerializer(codec, compression, cache.NewDefaultTypeRegistry())
+}
+
+// newAdminClient authenticates against running instance, access token is kept as cookie
+func newAdminClient(baseURL string) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		log.Fatalf("unexpected error creating cookie jar, error %v", err)
+	}
+	cl := &http.Client{Jar: jar}
+
+	raw, err := json.Marshal(map[string]string{"user": adminUser, "pass": adminPass})
+	if err != nil {
+		log.Fatalf("unexpected error encoding credentials, error %v", err)
+	}
+
+	resp, err := cl.Post(strings.TrimRight(baseURL, "/")+"/auth", "application/json", bytes.NewReader(raw))
+	if err != nil {
+		log.Fatalf("unexpected error authenticating, error %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Fatal(fmt.Sprintf("authentication failed, status %d", resp.StatusCode))
+	}
+
+	return cl
+}
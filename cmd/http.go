@@ -1,13 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/leader"
 	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/metrics"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"github.com/marcosQuesada/githubTop/pkg/provider/cache"
+	"github.com/marcosQuesada/githubTop/pkg/provider/events"
+	"github.com/marcosQuesada/githubTop/pkg/provider/history"
+	"github.com/marcosQuesada/githubTop/pkg/provider/limiter"
 	"github.com/marcosQuesada/githubTop/pkg/provider/ranking"
 	httpServer "github.com/marcosQuesada/githubTop/pkg/server/http"
 	"github.com/marcosQuesada/githubTop/pkg/service"
+	"github.com/marcosQuesada/githubTop/pkg/storage"
 	"github.com/spf13/cobra"
 	"os"
 	"os/signal"
@@ -26,11 +34,59 @@ var (
 	requestRetries       int
 	cacheTTL             time.Duration
 	cacheExpirationFreq  time.Duration
+	negativeCacheTTL     time.Duration
 	tokenTTL             time.Duration
+	adminUsers           []string
 	rateLimitWindow      time.Duration
 	rateLimitMaxRequests int
-	redisHost            string
+	redisURL             string
+	redisPrefix          string
 	redisRanking         bool
+	redisCachePrefix     string
+	redisRankingPrefix   string
+	cacheFile            string
+	cacheCodec           string
+	cacheCompression     string
+	adaptiveTTL          bool
+	cacheTTLMin          time.Duration
+	cacheTTLMax          time.Duration
+	popularityPivot      int
+	trendingHalfLife     time.Duration
+	rankingSnapshot      string
+	distinctRanking      bool
+	heavyHitters         int
+	contributionCap      int
+	rankingMode          string
+	locationAliases      string
+	rankingSnapshotFreq  time.Duration
+	countPolicy          string
+	writeBehind          bool
+	scoreQueueSize       int
+	scoreBatchSize       int
+	scoreFlushInterval   time.Duration
+	viewedContributors   bool
+	searchEvents         string
+	searchEventsFile     string
+	searchEventsBuffer   int
+	searchEventsMaxLen   int64
+	replicatedRanking    bool
+	rankingPeers         []string
+	replicaID            string
+	replicaStateFile     string
+	peerSecret           string
+	gossipInterval       time.Duration
+	cacheSelf            string
+	cachePeers           []string
+	cachePeersFile       string
+	redisRateLimit       bool
+	cacheFillLock        bool
+	cacheFillLease       time.Duration
+	cacheFillWait        time.Duration
+	leaderElection       bool
+	leaderLease          time.Duration
+	topHistory           string
+	topHistoryFile       string
+	topHistoryRetention  time.Duration
 )
 
 // httpCmd represents the http command
@@ -48,6 +104,39 @@ var httpCmd = &cobra.Command{
 			RateLimitConfig: rateCfg,
 		}
 
+		// ranking snapshots are the only leader gated background job
+		if leaderElection && (redisRanking || heavyHitters > 0 || replicatedRanking || rankingSnapshot == "") {
+			log.Fatal("leader election just gates inMemory ranking snapshots, it requires --ranking-snapshot on inMemory ranking")
+		}
+
+		var redisClient redis.UniversalClient
+		if redisURL != "" || redisRanking || searchEvents == "redis" || redisRateLimit || leaderElection || topHistory == "redis" {
+			var err error
+			redisClient, err = storage.NewRedisClient(context.Background(), redisURL)
+			if err != nil {
+				log.Fatalf("unexpected error connecting to redis, error %v", err)
+			}
+			defer func() {
+				_ = redisClient.Close()
+			}()
+		}
+
+		// background jobs run on leader replica only
+		var elector leader.Elector
+		leaders := metrics.NewLeaderGauge(AppName)
+		if leaderElection {
+			e := leader.NewRedis(redisClient, redisPrefix, newReplicaID(), leaderLease, leaders)
+			e.Run()
+			defer e.Terminate()
+			elector = e
+		} else {
+			elector = leader.NewLocal(newReplicaID(), leaders)
+		}
+
+		if redisRateLimit {
+			cfg.Limiter = limiter.NewRedis(redisClient, oauthToken, rateCfg, limiter.Config{Prefix: redisPrefix + limiter.DefaultKeyPrefix})
+		}
+
 		cacheCfg := provider.NewCacheConfig(cacheTTL, cacheExpirationFreq)
 		repo := provider.NewHttpGithubRepository(AppName, cfg)
 		var middleware provider.Cache
@@ -55,29 +144,213 @@ var httpCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("unexepcted error initializing lru cache, error %v", err)
 		}
-		if redisHost != "" {
+		serializer := newSerializer()
+
+		switch {
+		case redisURL != "":
 			middleware.Terminate()
-			cl := redis.NewClient(&redis.Options{
-				Addr: redisHost,
+			middleware = cache.NewRedisWithConfig(redisClient, cache.RedisConfig{
+				Prefix:     redisPrefix + redisCachePrefix,
+				TTL:        cacheTTL,
+				Serializer: serializer,
 			})
-			middleware = cache.NewRedis(cl, cacheTTL)
+		case cacheFile != "":
+			middleware.Terminate()
+			middleware, err = cache.NewBolt(cacheFile, cacheTTL, cacheExpirationFreq, serializer)
+			if err != nil {
+				log.Fatalf("unexpected error opening cache file, error %v", err)
+			}
 		}
 
-		cache := provider.NewCacheMiddleware(middleware, repo)
+		var peerCache *cache.Peer
+		if len(cachePeers) > 0 || cachePeersFile != "" {
+			if redisURL != "" {
+				log.Fatal("cache peers require a local cache, redis cache is already shared")
+			}
+
+			if peerSecret == "" {
+				log.Fatal("cache peers require a --peer-secret, owners request github on peer lookups")
+			}
+			peerCache = cache.NewPeer(middleware, cache.PeerConfig{
+				Self:       newCacheSelf(),
+				Peers:      cachePeers,
+				Secret:     peerSecret,
+				Serializer: serializer,
+			})
+			middleware = peerCache
+		}
+		defer middleware.Terminate()
+
+		if cachePeersFile != "" {
+			w, err := cache.NewPeersWatcher(peerCache, cachePeersFile, cache.DefaultPeersReloadFrequency)
+			if err != nil {
+				log.Fatalf("unexpected error loading cache peers file, error %v", err)
+			}
+			w.Run()
+			defer w.Terminate()
+		}
 
 		var rnkPer provider.Ranking
-		rnkPer = ranking.NewInMemory(ranking.DefaultPriorityQueueSize)
-		if redisRanking {
-			cl := redis.NewClient(&redis.Options{
-				Addr: redisHost,
+		var gossip *ranking.Gossip
+		inMemoryRanking := ranking.NewInMemory(ranking.DefaultPriorityQueueSize)
+		rnkPer = inMemoryRanking
+		switch {
+		case redisRanking:
+			rnkPer = ranking.NewRedisWithPrefix(redisClient, redisPrefix+redisRankingPrefix)
+		case heavyHitters > 0:
+			rnkPer = ranking.NewSpaceSaving(heavyHitters)
+		case replicatedRanking:
+			if peerSecret == "" {
+				log.Fatal("replicated ranking requires a --peer-secret, gossiped merges can not be undone")
+			}
+			if replicaStateFile == "" {
+				log.Fatal("replicated ranking requires a --replica-state-file, replica slots must survive restarts")
+			}
+
+			replicated := ranking.NewReplicated(newReplicaID(), ranking.DefaultPriorityQueueSize)
+			if err := replicated.Persist(replicaStateFile); err != nil {
+				log.Fatalf("unexpected error restoring replica state, error %v", err)
+			}
+			gossip = ranking.NewGossip(replicated, ranking.GossipConfig{
+				Peers:    rankingPeers,
+				Interval: gossipInterval,
+				Secret:   peerSecret,
 			})
-			rnkPer = ranking.NewRedis(cl)
+			gossip.Run()
+			defer gossip.Terminate()
+			rnkPer = replicated
+		}
+
+		if rnkPer == inMemoryRanking && rankingSnapshot != "" {
+			snapshot := ranking.NewFileSnapshot(inMemoryRanking, rankingSnapshot, rankingSnapshotFreq).WithElector(elector)
+			if err := snapshot.Restore(); err != nil {
+				log.Fatalf("unexpected error restoring ranking snapshot, error %v", err)
+			}
+			snapshot.Run()
+			defer func() {
+				if err := snapshot.Terminate(); err != nil {
+					log.Errorf("unexpected error writing ranking snapshot, error %v", err)
+				}
+			}()
 		}
 		rnk := provider.NewLocationRanking(rnkPer)
-		svc := service.New(cache, rnk)
+
+		var trendingPer provider.Ranking
+		trendingPer = ranking.NewTrendingInMemory(ranking.DefaultPriorityQueueSize, trendingHalfLife)
+		if redisRanking {
+			trendingPer = ranking.NewTrendingRedis(redisClient, redisPrefix+redisRankingPrefix, trendingHalfLife)
+		}
+		trending := provider.NewLocationRanking(trendingPer)
+
+		svcCfg := service.Config{Trending: trending}
+		if distinctRanking {
+			var distinctPer provider.Ranking
+			distinctPer = ranking.NewDistinctInMemory(ranking.DefaultPriorityQueueSize, contributionCap)
+			if redisRanking {
+				distinctPer = ranking.NewDistinctRedis(redisClient, redisPrefix+redisRankingPrefix, contributionCap)
+			}
+			svcCfg.Distinct = provider.NewLocationRanking(distinctPer)
+		}
+
+		if viewedContributors {
+			f := ranking.NewInMemoryFactory(ranking.DefaultPriorityQueueSize, ranking.DefaultCityRankingSize, ranking.DefaultRankedCities)
+			if redisRanking {
+				f = ranking.NewRedisFactory(redisClient, redisPrefix+redisRankingPrefix, ranking.DefaultCityRankingSize, ranking.DefaultCityRankingTTL)
+			}
+			svcCfg.Viewed = provider.NewContributorRanking(f)
+		}
+
+		mode, err := provider.ParseRankingMode(rankingMode)
+		if err != nil {
+			log.Fatalf("unexpected ranking mode %s", rankingMode)
+		}
+		if mode == provider.RankingDistinct && !distinctRanking {
+			log.Fatal("distinct ranking mode requires --distinct-ranking")
+		}
+		svcCfg.DefaultMode = mode
+
+		if locationAliases != "" {
+			n, err := service.LoadNormalizer(locationAliases)
+			if err != nil {
+				log.Fatalf("unexpected error loading location aliases, error %v", err)
+			}
+			svcCfg.Normalizer = n
+		}
+
+		policy, err := service.ParseCountPolicy(countPolicy)
+		if err != nil {
+			log.Fatalf("unexpected ranking count policy %s", countPolicy)
+		}
+		svcCfg.CountPolicy = policy
+
+		if writeBehind {
+			wb := service.NewWriteBehind(service.WriteBehindConfig{
+				QueueSize:     scoreQueueSize,
+				BatchSize:     scoreBatchSize,
+				FlushInterval: scoreFlushInterval,
+			}, rnk, trending, svcCfg.Distinct)
+			wb.Run()
+			defer wb.Terminate()
+			svcCfg.Recorder = wb
+		}
+
+		if searchEvents != "" {
+			emitter := events.NewBuffered(newSearchEventSink(redisClient), events.BufferedConfig{
+				Size:   searchEventsBuffer,
+				Events: metrics.NewSearchEventsCounter(AppName),
+			})
+			emitter.Run()
+			defer emitter.Terminate()
+			svcCfg.Events = emitter
+		}
+
+		if topHistory != "" {
+			h, closer := newTopHistory(redisClient)
+			defer closer()
+			svcCfg.History = h
+		}
+
+		snapshotMaxTTL := cacheTTL
+		if adaptiveTTL && cacheTTLMax > snapshotMaxTTL {
+			snapshotMaxTTL = cacheTTLMax
+		}
+
+		cacheMiddlewareCfg := provider.CacheMiddlewareConfig{
+			NegativeTTL: negativeCacheTTL,
+			Lookups:     metrics.NewCacheLookupsCounter(AppName),
+			TTLs:        metrics.NewCacheTTLHistogram(AppName),
+			Snapshotter: cache.NewSnapshotter(middleware, cache.NewDefaultTypeRegistry(), snapshotMaxTTL),
+			History:     svcCfg.History,
+		}
+		if cacheFillLock {
+			if redisURL == "" {
+				log.Fatal("cache fill lock requires redis cache")
+			}
+			cacheMiddlewareCfg.FillLock = cache.NewRedisLock(redisClient, redisPrefix+redisCachePrefix+cache.DefaultLockPrefix)
+			cacheMiddlewareCfg.FillLease = cacheFillLease
+			cacheMiddlewareCfg.FillWait = cacheFillWait
+		}
+		if adaptiveTTL {
+			if cacheTTLMin > cacheTTLMax {
+				log.Fatalf("cache ttl min %s greater than max %s", cacheTTLMin, cacheTTLMax)
+			}
+			cacheMiddlewareCfg.TTLPolicy = provider.NewAdaptiveTTL(rnkPer, provider.AdaptiveTTLConfig{
+				Min:   cacheTTLMin,
+				Max:   cacheTTLMax,
+				Pivot: popularityPivot,
+			})
+		}
+		cached := provider.NewCacheMiddlewareWithConfig(middleware, repo, cacheMiddlewareCfg)
+		svc := service.NewWithConfig(cached, rnk, svcCfg)
 		ac := service.NewDefaultStaticAuthorizer()
-		auth := service.NewAuth(ac, "config/app.rsa", "config/app.rsa.pub", tokenTTL, AppName)
+		auth := service.NewAuthWithAdmins(ac, "config/app.rsa", "config/app.rsa.pub", tokenTTL, AppName, adminUsers)
 		s := httpServer.New(port, svc, auth, AppName)
+		if gossip != nil {
+			s.Handle(ranking.GossipPath, gossip)
+		}
+		if peerCache != nil {
+			s.Handle(cache.PeerPath, cache.NewPeerHandler(peerCache, cached))
+		}
 
 		c := make(chan os.Signal, 1)
 
@@ -110,9 +383,127 @@ func init() {
 	httpCmd.Flags().IntVarP(&requestRetries, "retries", "r", 3, "http request on error retry")
 	httpCmd.Flags().DurationVarP(&cacheTTL, "cache-ttl", "c", time.Hour*24, "cache TTL")
 	httpCmd.Flags().DurationVarP(&cacheExpirationFreq, "cache-exp-freq", "e", time.Second*5, "cache expiration frequency")
+	httpCmd.Flags().DurationVar(&negativeCacheTTL, "negative-cache-ttl", time.Minute*5, "empty results and upstream validation errors cache TTL, 0 disables it")
 	httpCmd.Flags().DurationVarP(&tokenTTL, "token-ttl", "l", time.Minute*1, "auth token expiration")
+	httpCmd.Flags().StringSliceVar(&adminUsers, "admin-users", nil, "Users granted admin endpoints access, as test,ops, admin endpoints are refused to everyone if empty")
 	httpCmd.Flags().DurationVarP(&rateLimitWindow, "rate-window", "w", time.Minute*1, "rate limit time window")
 	httpCmd.Flags().IntVarP(&rateLimitMaxRequests, "rate-max", "m", 30, "rate limit max requests")
-	httpCmd.Flags().StringVarP(&redisHost, "redis", "s", "", "Redis host if any")
+	httpCmd.Flags().StringVarP(&redisURL, "redis", "s", "", "Redis url if any (redis://, rediss://, ?sentinel=master, ?cluster=true)")
+	httpCmd.Flags().BoolVar(&redisRateLimit, "redis-rate-limit", false, "Share github rate limit budget by oauth token across replicas on redis, local limiter on redis failures")
 	httpCmd.Flags().BoolVarP(&redisRanking, "redis-ranking", "k", false, "Use redis ranking")
+	httpCmd.Flags().StringVar(&redisPrefix, "redis-prefix", "", "Redis key prefix shared by all features (cache, rankings, leader lease, rate limit, history and search events)")
+	httpCmd.Flags().StringVar(&redisCachePrefix, "redis-cache-prefix", "cache:", "Redis cache key prefix, after --redis-prefix")
+	httpCmd.Flags().BoolVar(&cacheFillLock, "cache-fill-lock", false, "Lease missing redis cache keys, so that a single replica requests github for each one")
+	httpCmd.Flags().DurationVar(&cacheFillLease, "cache-fill-lease", provider.DefaultFillLease, "Cache fill lease duration, expired leases are taken over")
+	httpCmd.Flags().DurationVar(&cacheFillWait, "cache-fill-wait", provider.DefaultFillWait, "Max wait on keys leased by other replicas, github is requested once exceeded")
+	httpCmd.Flags().StringVar(&redisRankingPrefix, "redis-ranking-prefix", "", "Redis ranking key prefix, after --redis-prefix")
+	httpCmd.Flags().BoolVar(&adaptiveTTL, "adaptive-ttl", false, "Pick cache TTL by location popularity and data change rate")
+	httpCmd.Flags().DurationVar(&cacheTTLMin, "cache-ttl-min", time.Hour, "adaptive cache TTL lower bound")
+	httpCmd.Flags().DurationVar(&cacheTTLMax, "cache-ttl-max", time.Hour*72, "adaptive cache TTL upper bound")
+	httpCmd.Flags().IntVar(&popularityPivot, "popularity-pivot", provider.DefaultPopularityPivot, "searches score where a location is considered half hot")
+	httpCmd.Flags().DurationVar(&trendingHalfLife, "trending-half-life", ranking.DefaultHalfLife, "trending locations search weight half life")
+	httpCmd.Flags().IntVar(&heavyHitters, "heavy-hitters", 0, "Use a fixed memory Space-Saving inMemory ranking with this number of counters, 0 disables it")
+	httpCmd.Flags().BoolVar(&distinctRanking, "distinct-ranking", false, "Track searched locations by distinct searchers too")
+	httpCmd.Flags().IntVar(&contributionCap, "contribution-cap", ranking.DefaultContributionCap, "max searches counted from the same searcher by location on distinct ranking")
+	httpCmd.Flags().StringVar(&rankingMode, "ranking-mode", string(provider.RankingRaw), "default searched locations ranking mode (raw, distinct)")
+	httpCmd.Flags().StringVar(&locationAliases, "location-aliases", "", "Location aliases json file, as config/location-aliases.json")
+	httpCmd.Flags().StringVar(&rankingSnapshot, "ranking-snapshot", "", "InMemory ranking snapshot file, restored on startup and written on shutdown")
+	httpCmd.Flags().DurationVar(&rankingSnapshotFreq, "ranking-snapshot-freq", time.Minute, "InMemory ranking snapshot frequency")
+	httpCmd.Flags().BoolVar(&viewedContributors, "viewed-contributors", false, "Rank served contributors by position weighted views")
+	httpCmd.Flags().StringVar(&searchEvents, "search-events", "", "Search events sink (stdout, file, redis), disabled if empty")
+	httpCmd.Flags().StringVar(&searchEventsFile, "search-events-file", "search-events.ndjson", "Search events append only log file")
+	httpCmd.Flags().IntVar(&searchEventsBuffer, "search-events-buffer", events.DefaultBufferSize, "Pending search events, events are dropped once full")
+	httpCmd.Flags().StringVar(&topHistory, "top-history", "", "Top contributors history store (memory, file, redis), disabled if empty")
+	httpCmd.Flags().StringVar(&topHistoryFile, "top-history-file", "top-history.ndjson", "Top contributors history file")
+	httpCmd.Flags().DurationVar(&topHistoryRetention, "top-history-retention", provider.DefaultHistoryRetention, "Top contributors snapshots retention")
+	httpCmd.Flags().Int64Var(&searchEventsMaxLen, "search-events-max-len", events.DefaultStreamMaxLen, "Search events redis stream approximated max length")
+	httpCmd.Flags().StringVar(&countPolicy, "ranking-count-policy", string(service.CountOnSuccess), "searches counted on rankings (request, success, non-empty)")
+	httpCmd.Flags().BoolVar(&writeBehind, "ranking-write-behind", false, "Count searches off the request path, flushed to rankings in batches")
+	httpCmd.Flags().IntVar(&scoreQueueSize, "ranking-queue-size", service.DefaultScoreQueueSize, "write behind pending searches, searches are dropped once full")
+	httpCmd.Flags().IntVar(&scoreBatchSize, "ranking-batch-size", service.DefaultScoreBatchSize, "write behind max searches by flush")
+	httpCmd.Flags().DurationVar(&scoreFlushInterval, "ranking-flush-interval", service.DefaultScoreFlushInterval, "write behind flush frequency")
+	httpCmd.Flags().StringVar(&cacheFile, "cache-file", "", "Persistent on-disk cache file, ignored when redis cache is enabled")
+	httpCmd.Flags().StringVar(&cacheCodec, "cache-codec", "json", "Redis and on-disk cache codec (json, msgpack)")
+	httpCmd.Flags().StringVar(&cacheCompression, "cache-compression", "none", "Redis and on-disk cache compression (none, gzip, snappy)")
+	httpCmd.Flags().BoolVar(&replicatedRanking, "replicated-ranking", false, "Use a conflict free replicated inMemory ranking gossiped with --ranking-peers")
+	httpCmd.Flags().StringSliceVar(&rankingPeers, "ranking-peers", nil, "Replicated ranking peers base urls, as http://10.0.0.2:8000,http://10.0.0.3:8000")
+	httpCmd.Flags().StringVar(&replicaID, "replica-id", "", "Unique and stable replica id, hostname:port if empty")
+	httpCmd.Flags().StringVar(&replicaStateFile, "replica-state-file", "", "Replicated ranking own counters file, mandatory with replicated ranking, restored on restarts")
+	httpCmd.Flags().BoolVar(&leaderElection, "leader-election", false, "Elect inMemory ranking snapshot writer replica through a redis lease, replica always leads if disabled")
+	httpCmd.Flags().DurationVar(&leaderLease, "leader-lease", leader.DefaultLease, "Leadership lease duration, renewed each third of it")
+	httpCmd.Flags().StringVar(&peerSecret, "peer-secret", "", "Shared secret required on peer to peer requests, mandatory with replicated ranking or cache peers")
+	httpCmd.Flags().DurationVar(&gossipInterval, "ranking-gossip-interval", ranking.DefaultGossipInterval, "Replicated ranking state exchange frequency")
+	httpCmd.Flags().StringVar(&cacheSelf, "cache-self", "", "Own base url as listed on cache peers, http://hostname:port if empty")
+	httpCmd.Flags().StringSliceVar(&cachePeers, "cache-peers", nil, "Peer to peer cache base urls, as http://10.0.0.2:8000,http://10.0.0.3:8000")
+	httpCmd.Flags().StringVar(&cachePeersFile, "cache-peers-file", "", "Peer to peer cache peers file, one base url by line, reloaded on changes")
+}
+
+// newReplicaID returns replica id from flags, hostname and port by default
+func newReplicaID() string {
+	if replicaID != "" {
+		return replicaID
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("unexpected error getting hostname, error %v", err)
+	}
+
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+// newCacheSelf returns own cache peer base url from flags, hostname and port by default
+func newCacheSelf() string {
+	if cacheSelf != "" {
+		return cacheSelf
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("unexpected error getting hostname, error %v", err)
+	}
+
+	return fmt.Sprintf("http://%s:%d", host, port)
+}
+
+// newTopHistory opens top contributors history from flags, along with its closer
+func newTopHistory(cl redis.UniversalClient) (provider.TopHistory, func()) {
+	switch topHistory {
+	case "memory":
+		return history.NewInMemory(topHistoryRetention), func() {}
+	case "file":
+		f, err := history.NewFile(topHistoryFile, topHistoryRetention)
+		if err != nil {
+			log.Fatalf("unexpected error opening top history file, error %v", err)
+		}
+
+		return f, func() {
+			_ = f.Close()
+		}
+	case "redis":
+		return history.NewRedis(cl, redisPrefix, topHistoryRetention), func() {}
+	}
+
+	log.Fatalf("unexpected top history store %s", topHistory)
+	return nil, nil
+}
+
+// newSearchEventSink opens search events sink from flags
+func newSearchEventSink(cl redis.UniversalClient) provider.SearchEventSink {
+	switch searchEvents {
+	case "stdout":
+		return events.NewWriter(os.Stdout)
+	case "file":
+		f, err := events.NewFile(searchEventsFile)
+		if err != nil {
+			log.Fatalf("unexpected error opening search events file, error %v", err)
+		}
+
+		return f
+	case "redis":
+		return events.NewRedisStream(cl, redisPrefix, searchEventsMaxLen)
+	}
+
+	log.Fatalf("unexpected search events sink %s", searchEvents)
+	return nil
 }
@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"github.com/marcosQuesada/githubTop/pkg/provider/events"
+	"github.com/marcosQuesada/githubTop/pkg/provider/ranking"
+	"github.com/marcosQuesada/githubTop/pkg/service"
+	"github.com/marcosQuesada/githubTop/pkg/storage"
+	"github.com/spf13/cobra"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const rankingAdminPath = "/admin/ranking"
+
+var (
+	eventsFile string
+	replayFrom string
+	replayTo   string
+	noReset    bool
+)
+
+// rankingAdmin defines searched locations rankings administration
+type rankingAdmin interface {
+	RemoveLocation(ctx context.Context, city string) error
+	ResetLocations(ctx context.Context) error
+	MergeLocations(ctx context.Context, from, to string) error
+}
+
+// rankingCmd groups searched locations ranking administration commands
+var rankingCmd = &cobra.Command{
+	Use:   "ranking",
+	Short: "Searched locations ranking administration",
+	Long: `Searched locations ranking administration, applied on a running instance (--url)
+or straight on a persistent ranking (--redis or --ranking-snapshot)`,
+}
+
+// rankingRemoveCmd deletes a location from rankings
+var rankingRemoveCmd = &cobra.Command{
+	Use:   "remove [city]",
+	Short: "Remove location from rankings",
+	Long:  `Remove an abusive location from all time, window, trending and distinct rankings`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adm, terminate := newRankingAdmin()
+		defer terminate()
+
+		if err := adm.RemoveLocation(context.Background(), args[0]); err != nil {
+			log.Fatalf("unexpected error removing location, error %v", err)
+		}
+		log.Infof("Location %s removed", args[0])
+	},
+}
+
+// rankingResetCmd deletes all ranked locations
+var rankingResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset rankings",
+	Long:  `Delete all ranked locations from all time, window, trending and distinct rankings`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		adm, terminate := newRankingAdmin()
+		defer terminate()
+
+		if err := adm.ResetLocations(context.Background()); err != nil {
+			log.Fatalf("unexpected error resetting rankings, error %v", err)
+		}
+		log.Info("Rankings reset")
+	},
+}
+
+// rankingMergeCmd moves a location scores into another one
+var rankingMergeCmd = &cobra.Command{
+	Use:   "merge [from] [to]",
+	Short: "Merge locations",
+	Long:  `Add misspelled location scores to the right one and remove it`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		adm, terminate := newRankingAdmin()
+		defer terminate()
+
+		if err := adm.MergeLocations(context.Background(), args[0], args[1]); err != nil {
+			log.Fatalf("unexpected error merging locations, error %v", err)
+		}
+		log.Infof("Location %s merged into %s", args[0], args[1])
+	},
+}
+
+// rankingRebuildCmd recomputes rankings from search events
+var rankingRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild rankings replaying search events",
+	Long: `Reset persistent rankings and replay search events log (--events-file, or redis stream otherwise)
+applying current location aliases and count policy. Time ranges (--from, --to) are replayed on top of
+current rankings (--no-reset), as reset would drop searches out of range`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if (replayFrom != "" || replayTo != "") && !noReset {
+			log.Fatal("time ranges are only replayed on top of current rankings, --no-reset is required")
+		}
+
+		policy, err := service.ParseCountPolicy(countPolicy)
+		if err != nil {
+			log.Fatalf("unexpected ranking count policy %s", countPolicy)
+		}
+
+		cfg := service.ReplayConfig{
+			From:        parseReplayTime(replayFrom),
+			To:          parseReplayTime(replayTo),
+			CountPolicy: policy,
+			Normalizer:  newLocationNormalizer(),
+		}
+
+		p := newPersistentRankings()
+		defer p.close()
+
+		var src provider.SearchEventSource
+		switch {
+		case eventsFile != "":
+			src = events.NewFileSource(eventsFile)
+		case p.client != nil:
+			src = events.NewRedisStreamSource(p.client, redisPrefix)
+		default:
+			log.Fatal("an events file or a redis events stream is required")
+		}
+
+		if !noReset {
+			for _, rnk := range p.all() {
+				if err := rnk.Reset(context.Background()); err != nil {
+					log.Fatalf("unexpected error resetting rankings, error %v", err)
+				}
+			}
+		}
+
+		stats, err := service.Replay(context.Background(), src, cfg, p.all()...)
+		if err != nil {
+			log.Fatalf("unexpected error replaying search events, error %v", err)
+		}
+		log.Infof("Rankings rebuilt, %d searches replayed from %d events", stats.Replayed, stats.Read)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rankingCmd)
+	rankingCmd.AddCommand(rankingRemoveCmd)
+	rankingCmd.AddCommand(rankingResetCmd)
+	rankingCmd.AddCommand(rankingMergeCmd)
+	rankingCmd.AddCommand(rankingRebuildCmd)
+
+	rankingCmd.PersistentFlags().StringVar(&instanceURL, "url", "", "Running instance url, as http://localhost:8000")
+	rankingCmd.PersistentFlags().StringVar(&adminUser, "user", "", "Running instance user")
+	rankingCmd.PersistentFlags().StringVar(&adminPass, "pass", "", "Running instance password")
+	rankingCmd.PersistentFlags().StringVarP(&redisURL, "redis", "s", "", "Redis url if any (redis://, rediss://, ?sentinel=master, ?cluster=true)")
+	rankingCmd.PersistentFlags().StringVar(&redisPrefix, "redis-prefix", "", "Redis key prefix shared by all features, as running instances")
+	rankingCmd.PersistentFlags().StringVar(&redisRankingPrefix, "redis-ranking-prefix", "", "Redis ranking key prefix, after --redis-prefix")
+	rankingCmd.PersistentFlags().StringVar(&rankingSnapshot, "ranking-snapshot", "", "InMemory ranking snapshot file, instance must be stopped")
+	rankingCmd.PersistentFlags().StringVar(&locationAliases, "location-aliases", "", "Location aliases json file, as config/location-aliases.json")
+	rankingCmd.PersistentFlags().DurationVar(&trendingHalfLife, "trending-half-life", ranking.DefaultHalfLife, "trending locations search weight half life, as running instances")
+	rankingCmd.PersistentFlags().IntVar(&contributionCap, "contribution-cap", ranking.DefaultContributionCap, "max searches counted from the same searcher by location on distinct ranking, as running instances")
+
+	rankingRebuildCmd.Flags().StringVar(&eventsFile, "events-file", "", "Search events log file, redis stream if empty")
+	rankingRebuildCmd.Flags().StringVar(&replayFrom, "from", "", "Replay searches from time, RFC3339 or date as 2026-09-01")
+	rankingRebuildCmd.Flags().StringVar(&replayTo, "to", "", "Replay searches up to time, RFC3339 or date as 2026-09-01")
+	rankingRebuildCmd.Flags().BoolVar(&noReset, "no-reset", false, "Replay on top of current rankings, required on time ranges")
+	rankingRebuildCmd.Flags().StringVar(&countPolicy, "ranking-count-policy", string(service.CountOnSuccess), "searches counted on rankings (request, success, non-empty)")
+}
+
+// parseReplayTime parses RFC3339 times or dates, zero time if empty
+func parseReplayTime(v string) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t
+	}
+
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		log.Fatalf("unexpected replay time %s, error %v", v, err)
+	}
+
+	return t
+}
+
+// newRankingAdmin returns running instance client or a service on top of persistent rankings
+func newRankingAdmin() (rankingAdmin, func()) {
+	if instanceURL != "" {
+		return &remoteRankingAdmin{client: newAdminClient(instanceURL), url: strings.TrimRight(instanceURL, "/")}, func() {}
+	}
+
+	p := newPersistentRankings()
+	cfg := service.Config{Normalizer: newLocationNormalizer()}
+	if p.trending != nil {
+		cfg.Trending = p.trending
+	}
+	if p.distinct != nil {
+		cfg.Distinct = p.distinct
+	}
+
+	return service.NewWithConfig(nil, p.ranking, cfg), p.close
+}
+
+// newLocationNormalizer loads location aliases if any
+func newLocationNormalizer() service.LocationNormalizer {
+	if locationAliases == "" {
+		return nil
+	}
+
+	n, err := service.LoadNormalizer(locationAliases)
+	if err != nil {
+		log.Fatalf("unexpected error loading location aliases, error %v", err)
+	}
+
+	return n
+}
+
+// persistentRankings holds rankings administered straight on its persistence, trending and distinct
+// rankings only on redis
+type persistentRankings struct {
+	ranking  *provider.LocationRanking
+	trending *provider.LocationRanking
+	distinct *provider.LocationRanking
+	client   redis.UniversalClient
+	close    func()
+}
+
+// all returns defined rankings
+func (p *persistentRankings) all() []service.SearchedLocationsRanking {
+	res := []service.SearchedLocationsRanking{p.ranking}
+	for _, r := range []*provider.LocationRanking{p.trending, p.distinct} {
+		if r != nil {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+// newPersistentRankings opens redis or inMemory snapshot rankings, snapshot is written on close
+func newPersistentRankings() *persistentRankings {
+	switch {
+	case redisURL != "":
+		cl, err := storage.NewRedisClient(context.Background(), redisURL)
+		if err != nil {
+			log.Fatalf("unexpected error connecting to redis, error %v", err)
+		}
+
+		return &persistentRankings{
+			ranking:  provider.NewLocationRanking(ranking.NewRedisWithPrefix(cl, redisPrefix+redisRankingPrefix)),
+			trending: provider.NewLocationRanking(ranking.NewTrendingRedis(cl, redisPrefix+redisRankingPrefix, trendingHalfLife)),
+			distinct: provider.NewLocationRanking(ranking.NewDistinctRedis(cl, redisPrefix+redisRankingPrefix, contributionCap)),
+			client:   cl,
+			close:    func() { _ = cl.Close() },
+		}
+	case rankingSnapshot != "":
+		r := ranking.NewInMemory(ranking.DefaultPriorityQueueSize)
+		snapshot := ranking.NewFileSnapshot(r, rankingSnapshot, 0)
+		if err := snapshot.Restore(); err != nil {
+			log.Fatalf("unexpected error restoring ranking snapshot, error %v", err)
+		}
+
+		return &persistentRankings{
+			ranking: provider.NewLocationRanking(r),
+			close: func() {
+				if err := snapshot.Write(); err != nil {
+					log.Fatalf("unexpected error writing ranking snapshot, error %v", err)
+				}
+			},
+		}
+	}
+
+	log.Fatal("a running instance url or a persistent ranking is required")
+	return nil
+}
+
+// remoteRankingAdmin applies ranking administration on a running instance admin endpoints
+type remoteRankingAdmin struct {
+	client *http.Client
+	url    string
+}
+
+// RemoveLocation requests location removal
+func (r *remoteRankingAdmin) RemoveLocation(_ context.Context, city string) error {
+	return r.do("DELETE", rankingAdminPath+"/locations/"+url.PathEscape(city), nil)
+}
+
+// ResetLocations requests rankings reset
+func (r *remoteRankingAdmin) ResetLocations(_ context.Context) error {
+	return r.do("DELETE", rankingAdminPath+"/locations", nil)
+}
+
+// MergeLocations requests locations merge
+func (r *remoteRankingAdmin) MergeLocations(_ context.Context, from, to string) error {
+	raw, err := json.Marshal(map[string]string{"from": from, "to": to})
+	if err != nil {
+		return err
+	}
+
+	return r.do("POST", rankingAdminPath+"/merge", raw)
+}
+
+func (r *remoteRankingAdmin) do(method, path string, body []byte) error {
+	req, err := http.NewRequest(method, r.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected ranking admin response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package leader
+
+import (
+	"context"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLease defines leadership lease duration, renewed each third of it
+	DefaultLease = time.Second * 15
+	// leaseKey and fenceKey share hash tag, so that scripts run on cluster slots
+	leaseKey = "{leader}:lease"
+	fenceKey = "{leader}:fence"
+)
+
+// acquire renews lease if replica holds it, takes it with a new fencing token if free,
+// returns leadership fence or 0 if another replica holds it
+var acquire = redis.NewScript(`
+local holder = redis.call("HGET", KEYS[1], "id")
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return tonumber(redis.call("HGET", KEYS[1], "fence"))
+end
+
+if holder then
+	return 0
+end
+
+local fence = redis.call("INCR", KEYS[2])
+redis.call("HSET", KEYS[1], "id", ARGV[1], "fence", fence)
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+
+return fence
+`)
+
+// release deletes lease just if replica still holds it
+var release = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "id") == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Redis elects leader through a redis lease, renewed while replica is alive. Leadership is held locally
+// up to lease duration since last renew request, so that a replica unable to renew stops leading before
+// its lease expires and another replica takes it.
+type Redis struct {
+	client  redis.UniversalClient
+	id      string
+	lease   time.Duration
+	prefix  string
+	leaders metrics.Gauge
+	fence   int64
+	until   time.Time
+	mutex   sync.RWMutex
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewRedis instantiates redis elector, replica ids must be unique, leaders gauge is optional
+func NewRedis(cl redis.UniversalClient, prefix, id string, lease time.Duration, leaders metrics.Gauge) *Redis {
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+
+	if leaders != nil {
+		leaders.With("replica", id).Set(0)
+	}
+
+	return &Redis{
+		client:  cl,
+		id:      id,
+		lease:   lease,
+		prefix:  prefix,
+		leaders: leaders,
+		done:    make(chan struct{}),
+	}
+}
+
+// Leader returns current leadership fencing token, false if replica is not the leader
+func (r *Redis) Leader() (int64, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.fence == 0 || time.Now().After(r.until) {
+		return 0, false
+	}
+
+	return r.fence, true
+}
+
+// Run starts election worker, leadership is tried right away
+func (r *Redis) Run() {
+	r.Campaign(context.Background())
+
+	r.wg.Add(1)
+	go r.runner()
+}
+
+// Terminate stops election worker and releases lease if held, so that another replica takes it
+func (r *Redis) Terminate() {
+	close(r.done)
+	r.wg.Wait()
+
+	if _, ok := r.Leader(); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.lease/3)
+	defer cancel()
+	if err := release.Run(ctx, r.client, []string{r.prefix + leaseKey}, r.id).Err(); err != nil {
+		log.Errorf("unexpected error releasing leadership, error %v", err)
+	}
+	r.set(0, time.Time{})
+}
+
+// Campaign takes or renews leadership lease, failures keep leadership until lease ends
+func (r *Redis) Campaign(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.lease/3)
+	defer cancel()
+
+	start := time.Now()
+	fence, err := acquire.Run(ctx, r.client, []string{r.prefix + leaseKey, r.prefix + fenceKey}, r.id, r.lease.Milliseconds()).Int64()
+	if err != nil {
+		log.Errorf("unexpected error campaigning for leadership, error %v", err)
+		if _, ok := r.Leader(); !ok {
+			r.set(0, time.Time{})
+		}
+		return
+	}
+
+	r.set(fence, start.Add(r.lease))
+}
+
+func (r *Redis) runner() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Campaign(context.Background())
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// set updates leadership, transitions are logged and tracked on leaders gauge
+func (r *Redis) set(fence int64, until time.Time) {
+	r.mutex.Lock()
+	prev := r.fence
+	r.fence, r.until = fence, until
+	r.mutex.Unlock()
+
+	if prev == fence {
+		return
+	}
+
+	if fence > 0 {
+		log.Infof("replica %s leads background jobs, fence %d", r.id, fence)
+	} else {
+		log.Infof("replica %s stopped leading background jobs", r.id)
+	}
+
+	if r.leaders != nil {
+		v := 0.0
+		if fence > 0 {
+			v = 1
+		}
+		r.leaders.With("replica", r.id).Set(v)
+	}
+}
@@ -0,0 +1,97 @@
+package leader
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"testing"
+	"time"
+)
+
+const testPrefix = "test:leader:"
+
+func TestRedisElectsSingleLeaderAndHandsOverOnTerminate(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer cleanup(t, cl)
+
+	a := NewRedis(cl, testPrefix, "a", time.Second, nil)
+	b := NewRedis(cl, testPrefix, "b", time.Second, nil)
+	a.Run()
+	b.Run()
+	defer b.Terminate()
+
+	fence, ok := a.Leader()
+	if !ok {
+		t.Fatal("Unexpected first replica not leading")
+	}
+
+	if _, ok := b.Leader(); ok {
+		t.Fatal("Unexpected second replica leading along with first one")
+	}
+
+	a.Terminate()
+	b.Campaign(context.Background())
+
+	next, ok := b.Leader()
+	if !ok {
+		t.Fatal("Unexpected second replica not leading once first one terminated")
+	}
+
+	if next <= fence {
+		t.Errorf("Unexpected fence not growing, got %d after %d", next, fence)
+	}
+}
+
+func TestRedisTakesOverExpiredLeadership(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer cleanup(t, cl)
+
+	a := NewRedis(cl, testPrefix, "a", time.Millisecond*100, nil)
+	b := NewRedis(cl, testPrefix, "b", time.Millisecond*100, nil)
+
+	a.Campaign(context.Background())
+	if _, ok := a.Leader(); !ok {
+		t.Fatal("Unexpected first replica not leading")
+	}
+
+	time.Sleep(time.Millisecond * 150)
+	if _, ok := a.Leader(); ok {
+		t.Error("Unexpected leadership kept without renewing lease")
+	}
+
+	b.Campaign(context.Background())
+	if _, ok := b.Leader(); !ok {
+		t.Error("Unexpected expired leadership not taken over")
+	}
+}
+
+func TestLocalAlwaysLeads(t *testing.T) {
+	if fence, ok := NewLocal("a", nil).Leader(); !ok || fence != 0 {
+		t.Errorf("Unexpected local leadership, fence %d leading %t", fence, ok)
+	}
+}
+
+func cleanup(t *testing.T, cl *redis.Client) {
+	ctx := context.Background()
+	keys, err := cl.Keys(ctx, testPrefix+"*").Result()
+	if err != nil {
+		t.Fatalf("unexpected error listing keys, error %v", err)
+	}
+
+	if len(keys) > 0 {
+		if err := cl.Del(ctx, keys...).Err(); err != nil {
+			t.Fatalf("unexpected error deleting keys, error %v", err)
+		}
+	}
+	_ = cl.Close()
+}
@@ -63,3 +63,43 @@ func GetMetricsEndpoint(n, s string) (success, error metrics.Counter, duration m
 
 	return
 }
+
+// NewCacheLookupsCounter counts cache lookups labeled by status
+func NewCacheLookupsCounter(n string) metrics.Counter {
+	return prometheus.NewCounterFrom(pro.CounterOpts{
+		Namespace: n,
+		Subsystem: "cache",
+		Name:      "lookups",
+		Help:      "Total cache lookups by status.",
+	}, []string{"status"})
+}
+
+// NewCacheTTLHistogram observes chosen cache entries ttl in seconds
+func NewCacheTTLHistogram(n string) metrics.Histogram {
+	return prometheus.NewSummaryFrom(pro.SummaryOpts{
+		Namespace: n,
+		Subsystem: "cache",
+		Name:      "ttl_seconds",
+		Help:      "Chosen cache entries ttl in seconds.",
+	}, []string{})
+}
+
+// NewSearchEventsCounter counts emitted search events labeled by status (written, dropped, failed)
+func NewSearchEventsCounter(n string) metrics.Counter {
+	return prometheus.NewCounterFrom(pro.CounterOpts{
+		Namespace: n,
+		Subsystem: "search_events",
+		Name:      "total",
+		Help:      "Total search events by status.",
+	}, []string{"status"})
+}
+
+// NewLeaderGauge tracks background jobs leadership labeled by replica, 1 on current leader
+func NewLeaderGauge(n string) metrics.Gauge {
+	return prometheus.NewGaugeFrom(pro.GaugeOpts{
+		Namespace: n,
+		Subsystem: "leader",
+		Name:      "status",
+		Help:      "Background jobs leadership by replica, 1 on current leader.",
+	}, []string{"replica"})
+}
@@ -4,14 +4,42 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	kitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/google/go-github/github"
+	"github.com/hashicorp/golang-lru/simplelru"
 	"github.com/marcosQuesada/githubTop/pkg/log"
-
+	"io"
+	"net/http"
+	"sort"
+	"sync"
 	"time"
 )
 
+const (
+	// CacheHit happens on entry found in cache
+	CacheHit CacheStatus = "hit"
+	// CacheMiss happens on entry not found in cache
+	CacheMiss CacheStatus = "miss"
+	// CacheNegativeHit happens on cached empty result or upstream error found in cache
+	CacheNegativeHit CacheStatus = "negative_hit"
+)
+
 var (
 	// ErrCacheMiss happens on entry not found in cache
 	ErrCacheMiss = errors.New("entry not found in cache")
+	// ErrSnapshotUnavailable happens on cache without snapshot support
+	ErrSnapshotUnavailable = errors.New("cache snapshot unavailable")
+)
+
+const (
+	// DefaultFillLease bounds cache fill leases, longer than repository requests with retries
+	DefaultFillLease = time.Second * 15
+	// DefaultFillWait bounds replicas wait on leased keys
+	DefaultFillWait = time.Second * 10
+	// DefaultFillPoll defines cache polling frequency on leased keys
+	DefaultFillPoll = time.Millisecond * 100
+	// DefaultMaxCacheStats bounds tracked entries refresh history
+	DefaultMaxCacheStats = 10000
 )
 
 // Cache defines a generic cache interface
@@ -19,6 +47,9 @@ type Cache interface {
 	// Add entry to cache
 	Add(ctx context.Context, k string, v interface{}) error
 
+	// AddWithTTL adds entry to cache with its own expiration
+	AddWithTTL(ctx context.Context, k string, v interface{}, ttl time.Duration) error
+
 	// Get entry from cache, puts Entry in top of LRU, so refresh expiration entry
 	Get(ctx context.Context, k string) (interface{}, error)
 
@@ -26,9 +57,95 @@ type Cache interface {
 	Terminate()
 }
 
+// CacheLock leases cache keys, so that a single replica fills each missing entry
+type CacheLock interface {
+	// TryLock takes key lease for ttl, false if held by another replica, token identifies the lease
+	TryLock(ctx context.Context, k string, ttl time.Duration) (token string, ok bool, err error)
+
+	// Unlock releases key lease if token still holds it
+	Unlock(ctx context.Context, k, token string) error
+}
+
+// CacheSnapshotter dumps and restores cache contents, including entries remaining ttl
+type CacheSnapshotter interface {
+	Export(ctx context.Context, w io.Writer) (int, error)
+	Import(ctx context.Context, r io.Reader) (int, error)
+}
+
+// CacheStatus defines cache lookup result
+type CacheStatus string
+
+type cacheStatusKey struct{}
+
+// WithCacheStatus returns a context able to track cache lookup status
+func WithCacheStatus(ctx context.Context) context.Context {
+	var s CacheStatus
+	return context.WithValue(ctx, cacheStatusKey{}, &s)
+}
+
+// CacheStatusFromContext returns tracked cache lookup status, empty if none
+func CacheStatusFromContext(ctx context.Context) CacheStatus {
+	s, ok := ctx.Value(cacheStatusKey{}).(*CacheStatus)
+	if !ok {
+		return ""
+	}
+
+	return *s
+}
+
+// SetCacheStatus tracks cache lookup status on contexts built with WithCacheStatus
+func SetCacheStatus(ctx context.Context, status CacheStatus) {
+	if s, ok := ctx.Value(cacheStatusKey{}).(*CacheStatus); ok {
+		*s = status
+	}
+}
+
+type topRequestKey struct{}
+
+// WithTopRequest returns a context carrying looked up request, so that caches are able to load missing entries
+func WithTopRequest(ctx context.Context, req GithubTopRequest) context.Context {
+	return context.WithValue(ctx, topRequestKey{}, req)
+}
+
+// TopRequestFromContext returns looked up request, false if none
+func TopRequestFromContext(ctx context.Context) (GithubTopRequest, bool) {
+	req, ok := ctx.Value(topRequestKey{}).(GithubTopRequest)
+
+	return req, ok
+}
+
+// NegativeEntry caches empty results and upstream errors, empty Error means empty result
+type NegativeEntry struct {
+	Error      string `json:"error,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+}
+
+// NegativeCacheError replays a cached upstream error
+type NegativeCacheError struct {
+	Message    string
+	StatusCode int
+}
+
+// Error returns cached error message
+func (e *NegativeCacheError) Error() string {
+	return e.Message
+}
+
 type cacheMiddleware struct {
-	cache      Cache
-	repository GithubRepository
+	cache       Cache
+	repository  GithubRepository
+	negativeTTL time.Duration
+	lookups     kitmetrics.Counter
+	ttlPolicy   TTLPolicy
+	ttls        kitmetrics.Histogram
+	snapshotter CacheSnapshotter
+	fillLock    CacheLock
+	fillLease   time.Duration
+	fillWait    time.Duration
+	fillPoll    time.Duration
+	history     TopHistory
+	stats       *simplelru.LRU
+	mutex       sync.Mutex
 }
 
 // CacheConfig cache parameters configuration
@@ -42,25 +159,91 @@ func NewCacheConfig(ttl, exp time.Duration) CacheConfig {
 	return CacheConfig{ttl, exp}
 }
 
+// CacheMiddlewareConfig defines cache middleware behaviour
+type CacheMiddlewareConfig struct {
+	// NegativeTTL applies to empty results and cacheable upstream errors, zero disables negative caching
+	NegativeTTL time.Duration
+
+	// Lookups counts cache lookups by status, optional
+	Lookups kitmetrics.Counter
+
+	// TTLPolicy picks entries expiration, cache default ttl applies if none
+	TTLPolicy TTLPolicy
+
+	// TTLs observes chosen ttl seconds, optional
+	TTLs kitmetrics.Histogram
+
+	// Snapshotter enables cache export and import, optional
+	Snapshotter CacheSnapshotter
+
+	// FillLock leases missing keys before calling repository, optional
+	FillLock CacheLock
+
+	// FillLease bounds lease duration, expired leases are taken over by waiting replicas
+	FillLease time.Duration
+
+	// FillWait bounds replicas wait on leased keys, repository is called once exceeded
+	FillWait time.Duration
+
+	// FillPoll defines cache polling frequency while waiting on leased keys
+	FillPoll time.Duration
+
+	// History records refreshed top lists, optional
+	History TopHistory
+
+	// MaxStats bounds tracked entries refresh history, least recently refreshed ones are dropped
+	MaxStats int
+}
+
 // NewCacheMiddleware instantiates cached repository
 func NewCacheMiddleware(cache Cache, repo GithubRepository) *cacheMiddleware {
+	return NewCacheMiddlewareWithConfig(cache, repo, CacheMiddlewareConfig{})
+}
+
+// NewCacheMiddlewareWithConfig instantiates cached repository
+func NewCacheMiddlewareWithConfig(cache Cache, repo GithubRepository, cfg CacheMiddlewareConfig) *cacheMiddleware {
+	if cfg.FillLease <= 0 {
+		cfg.FillLease = DefaultFillLease
+	}
+
+	if cfg.FillWait <= 0 {
+		cfg.FillWait = DefaultFillWait
+	}
+
+	if cfg.FillPoll <= 0 {
+		cfg.FillPoll = DefaultFillPoll
+	}
+
+	if cfg.MaxStats <= 0 {
+		cfg.MaxStats = DefaultMaxCacheStats
+	}
+
+	// simplelru only fails on non positive sizes
+	stats, _ := simplelru.NewLRU(cfg.MaxStats, nil)
+
 	return &cacheMiddleware{
-		cache:      cache,
-		repository: repo,
+		cache:       cache,
+		repository:  repo,
+		negativeTTL: cfg.NegativeTTL,
+		lookups:     cfg.Lookups,
+		ttlPolicy:   cfg.TTLPolicy,
+		ttls:        cfg.TTLs,
+		snapshotter: cfg.Snapshotter,
+		fillLock:    cfg.FillLock,
+		fillLease:   cfg.FillLease,
+		fillWait:    cfg.FillWait,
+		fillPoll:    cfg.FillPoll,
+		history:     cfg.History,
+		stats:       stats,
 	}
 }
 
 // GetGithubTopContributors tries cache lookup, on miss access repository
 func (r *cacheMiddleware) GetGithubTopContributors(ctx context.Context, req GithubTopRequest) ([]*Contributor, error) {
 	k := r.key(req.City, req.Size)
-	res, err := r.cache.Get(ctx, k)
+	res, err := r.cache.Get(WithTopRequest(ctx, req), k)
 	if err == nil {
-		c, ok := res.([]*Contributor)
-		if !ok {
-			return nil, fmt.Errorf("unexpected cache type entry, type %T", res)
-		}
-
-		return c, nil
+		return r.cached(ctx, res)
 	}
 
 	if err != ErrCacheMiss {
@@ -68,18 +251,215 @@ func (r *cacheMiddleware) GetGithubTopContributors(ctx context.Context, req Gith
 		log.Errorf("Unexpected Error reading cache, err: %s", err.Error())
 	}
 
+	if r.fillLock != nil {
+		res, release, err := r.lease(WithTopRequest(ctx, req), k)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		if res != nil {
+			return r.cached(ctx, res)
+		}
+	}
+	r.track(ctx, CacheMiss)
+
 	c, errc := r.repository.GetGithubTopContributors(ctx, req)
 	if errc != nil {
+		if code, ok := r.negativeCacheable(errc); ok {
+			if err = r.cache.AddWithTTL(ctx, k, &NegativeEntry{Error: errc.Error(), StatusCode: code}, r.negativeTTL); err != nil {
+				log.Errorf("Error adding negative entry on cache is: %s", err.Error())
+			}
+		}
+
 		return nil, errc
 	}
 
-	if err = r.AddTopContributors(ctx, req.City, req.Size, c); err != nil {
+	if len(c) == 0 && r.negativeTTL > 0 {
+		if err = r.cache.AddWithTTL(ctx, k, &NegativeEntry{}, r.negativeTTL); err != nil {
+			log.Errorf("Error adding negative entry on cache is: %s", err.Error())
+		}
+
+		return c, nil
+	}
+
+	if err = r.refresh(ctx, req.City, k, c); err != nil {
 		log.Errorf("Error adding element on cache is: %s", err.Error())
 	}
+	r.record(ctx, req, c)
 
 	return c, nil
 }
 
+// cached returns cached entry result
+func (r *cacheMiddleware) cached(ctx context.Context, res interface{}) ([]*Contributor, error) {
+	switch c := res.(type) {
+	case []*Contributor:
+		r.track(ctx, CacheHit)
+
+		return c, nil
+	case *NegativeEntry:
+		r.track(ctx, CacheNegativeHit)
+		if c.Error != "" {
+			return nil, &NegativeCacheError{Message: c.Error, StatusCode: c.StatusCode}
+		}
+
+		return []*Contributor{}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected cache type entry, type %T", res)
+}
+
+// lease takes key fill lease before calling repository, while another replica holds it cache is polled
+// until entry is filled, lease expires and gets taken over or wait bound is exceeded. Entry is returned
+// if filled meanwhile, lease failures just let repository do its work.
+func (r *cacheMiddleware) lease(ctx context.Context, k string) (interface{}, func(), error) {
+	noop := func() {}
+	deadline := time.Now().Add(r.fillWait)
+	for {
+		token, ok, err := r.fillLock.TryLock(ctx, k, r.fillLease)
+		if err != nil {
+			log.Errorf("Unexpected error leasing cache key %s, err: %s", k, err.Error())
+			return nil, noop, nil
+		}
+
+		if ok {
+			release := func() {
+				if err := r.fillLock.Unlock(context.Background(), k, token); err != nil {
+					log.Errorf("Unexpected error releasing cache key %s lease, err: %s", k, err.Error())
+				}
+			}
+
+			// entry may have been filled by previous lease holder
+			if res, err := r.cache.Get(ctx, k); err == nil {
+				release()
+				return res, noop, nil
+			}
+
+			return nil, release, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, noop, nil
+		}
+
+		select {
+		case <-time.After(r.fillPoll):
+		case <-ctx.Done():
+			return nil, noop, ctx.Err()
+		}
+
+		if res, err := r.cache.Get(ctx, k); err == nil {
+			return res, noop, nil
+		}
+	}
+}
+
+// Stats returns refresh history from cached entries, sorted by key
+func (r *cacheMiddleware) Stats(_ context.Context) []*CacheEntryStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	res := make([]*CacheEntryStats, 0, r.stats.Len())
+	for _, k := range r.stats.Keys() {
+		if s, ok := r.stats.Peek(k); ok {
+			v := *s.(*CacheEntryStats)
+			res = append(res, &v)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+
+	return res
+}
+
+// ExportCache dumps cache contents
+func (r *cacheMiddleware) ExportCache(ctx context.Context, w io.Writer) (int, error) {
+	if r.snapshotter == nil {
+		return 0, ErrSnapshotUnavailable
+	}
+
+	return r.snapshotter.Export(ctx, w)
+}
+
+// ImportCache restores cache contents
+func (r *cacheMiddleware) ImportCache(ctx context.Context, rd io.Reader) (int, error) {
+	if r.snapshotter == nil {
+		return 0, ErrSnapshotUnavailable
+	}
+
+	return r.snapshotter.Import(ctx, rd)
+}
+
+// refresh updates entry refresh history and adds it to cache using policy ttl
+func (r *cacheMiddleware) refresh(ctx context.Context, city, k string, c []*Contributor) error {
+	stats := r.updateStats(city, k, c)
+	if r.ttlPolicy == nil {
+		return r.cache.Add(ctx, k, c)
+	}
+
+	ttl := r.ttlPolicy.TTL(ctx, stats)
+	r.setTTL(k, ttl)
+	if r.ttls != nil {
+		r.ttls.Observe(ttl.Seconds())
+	}
+	log.Debugf("Caching %s with ttl %s", k, ttl)
+
+	return r.cache.AddWithTTL(ctx, k, c, ttl)
+}
+
+// record stores refreshed top list on history, if enabled
+func (r *cacheMiddleware) record(ctx context.Context, req GithubTopRequest, c []*Contributor) {
+	if r.history == nil {
+		return
+	}
+
+	s := &TopSnapshot{City: req.City, Size: req.Size, FetchedAt: time.Now(), Contributors: c}
+	if err := r.history.Record(ctx, s); err != nil {
+		log.Errorf("Error recording top contributors snapshot: %s", err.Error())
+	}
+}
+
+func (r *cacheMiddleware) updateStats(city, k string, c []*Contributor) CacheEntryStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fp := fingerprint(c)
+	s := &CacheEntryStats{Key: k, City: city}
+	v, ok := r.stats.Get(k)
+	if ok {
+		s = v.(*CacheEntryStats)
+	}
+
+	if !ok {
+		r.stats.Add(k, s)
+	}
+
+	if ok {
+		s.Refreshes++
+		if s.fingerprint != fp {
+			s.Changes++
+		}
+	}
+	s.fingerprint = fp
+	s.UpdatedAt = time.Now()
+
+	return *s
+}
+
+func (r *cacheMiddleware) setTTL(k string, ttl time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if v, ok := r.stats.Peek(k); ok {
+		s := v.(*CacheEntryStats)
+		s.TTL = ttl
+		s.TTLSeconds = int64(ttl.Seconds())
+	}
+}
+
 // AddTopContributors updates cache layer
 func (r *cacheMiddleware) AddTopContributors(ctx context.Context, city string, size int, contributors []*Contributor) error {
 	k := r.key(city, size)
@@ -95,3 +475,25 @@ func (r *cacheMiddleware) Terminate() {
 func (r *cacheMiddleware) key(city string, size int) string {
 	return fmt.Sprintf("city_%s_size_%d", city, size)
 }
+
+func (r *cacheMiddleware) track(ctx context.Context, status CacheStatus) {
+	SetCacheStatus(ctx, status)
+	if r.lookups != nil {
+		r.lookups.With("status", string(status)).Add(1)
+	}
+}
+
+// negativeCacheable selects upstream errors that will not change on retry, as query validation errors
+func (r *cacheMiddleware) negativeCacheable(err error) (int, bool) {
+	if r.negativeTTL == 0 {
+		return 0, false
+	}
+
+	var e *github.ErrorResponse
+	if !errors.As(err, &e) || e.Response == nil {
+		return 0, false
+	}
+
+	code := e.Response.StatusCode
+	return code, code == http.StatusUnprocessableEntity || code == http.StatusNotFound
+}
@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+const (
+	// BoltBucket stores cache entries
+	BoltBucket = "cache"
+
+	// entry expiration prefix, unix nanoseconds
+	expirationSize = 8
+)
+
+// Bolt implements a persistent cache on top of an embedded key value file, entries survive restarts
+type Bolt struct {
+	db         *bolt.DB
+	ttl        time.Duration
+	expireFreq time.Duration
+	serializer *Serializer
+	done       chan struct{}
+}
+
+// NewBolt opens or creates cache file
+func NewBolt(path string, ttl, freq time.Duration, s *Serializer) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(BoltBucket))
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	b := &Bolt{
+		db:         db,
+		ttl:        ttl,
+		expireFreq: freq,
+		serializer: s,
+		done:       make(chan struct{}),
+	}
+	go b.runner()
+
+	return b, nil
+}
+
+// Add cache entry
+func (b *Bolt) Add(ctx context.Context, k string, v interface{}) error {
+	return b.AddWithTTL(ctx, k, v, b.ttl)
+}
+
+// AddWithTTL adds cache entry with its own expiration
+func (b *Bolt) AddWithTTL(_ context.Context, k string, v interface{}, ttl time.Duration) error {
+	raw, err := b.serializer.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	entry := make([]byte, expirationSize, expirationSize+len(raw))
+	binary.BigEndian.PutUint64(entry, uint64(time.Now().Add(ttl).UnixNano()))
+	entry = append(entry, raw...)
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(BoltBucket)).Put([]byte(k), entry)
+	})
+}
+
+// Get cache entry, expired entries are misses even if they have not been compacted yet
+func (b *Bolt) Get(_ context.Context, k string) (interface{}, error) {
+	var raw []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(BoltBucket)).Get([]byte(k))
+		if v == nil || len(v) < expirationSize || isExpired(v) {
+			return provider.ErrCacheMiss
+		}
+
+		// values are only valid during transaction
+		raw = append([]byte{}, v[expirationSize:]...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.serializer.Decode(raw)
+	if errors.Is(err, ErrUnknownVersion) || errors.Is(err, ErrUnknownType) {
+		log.Debugf("Discarding cache entry %s, error %v", k, err)
+
+		return nil, provider.ErrCacheMiss
+	}
+
+	return res, err
+}
+
+// Range iterates non expired entries, entries from unknown versions or types are skipped
+func (b *Bolt) Range(_ context.Context, fn func(k string, v interface{}, expire time.Time) error) error {
+	type rawEntry struct {
+		key    string
+		expire time.Time
+		raw    []byte
+	}
+
+	var entries []rawEntry
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(BoltBucket)).ForEach(func(k, v []byte) error {
+			if len(v) < expirationSize || isExpired(v) {
+				return nil
+			}
+
+			entries = append(entries, rawEntry{
+				key:    string(k),
+				expire: time.Unix(0, int64(binary.BigEndian.Uint64(v[:expirationSize]))),
+				raw:    append([]byte{}, v[expirationSize:]...),
+			})
+
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		v, err := b.serializer.Decode(e.raw)
+		if errors.Is(err, ErrUnknownVersion) || errors.Is(err, ErrUnknownType) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(e.key, v, e.expire); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Len returns stored entries, including expired ones not yet compacted
+func (b *Bolt) Len() int {
+	var size int
+	_ = b.db.View(func(tx *bolt.Tx) error {
+		size = tx.Bucket([]byte(BoltBucket)).Stats().KeyN
+		return nil
+	})
+
+	return size
+}
+
+// Terminate stop worker and close file
+func (b *Bolt) Terminate() {
+	close(b.done)
+	if err := b.db.Close(); err != nil {
+		log.Errorf("Unexpected error closing cache file, err: %s", err.Error())
+	}
+}
+
+func (b *Bolt) runner() {
+	ticker := time.NewTicker(b.expireFreq)
+	for {
+		select {
+		case <-ticker.C:
+			err := b.expire()
+			if err != nil {
+				log.Errorf("Unexpected error expiring cache, err: %s", err.Error())
+			}
+		case <-b.done:
+			ticker.Stop()
+
+			return
+		}
+	}
+}
+
+// expire removes expired entries
+func (b *Bolt) expire() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket([]byte(BoltBucket))
+
+		var expired [][]byte
+		err := bk.ForEach(func(k, v []byte) error {
+			if len(v) < expirationSize || isExpired(v) {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := bk.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func isExpired(v []byte) bool {
+	return int64(binary.BigEndian.Uint64(v[:expirationSize])) <= time.Now().UnixNano()
+}
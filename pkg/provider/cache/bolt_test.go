@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltCacheSurvivesRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "cache.db")
+	c, err := NewBolt(path, time.Hour, time.Hour, NewDefaultSerializer())
+	if err != nil {
+		t.Fatalf("unexpected error creating cache, error %v", err)
+	}
+
+	value := []*provider.Contributor{{ID: 123, Name: "fooBar"}}
+	if err := c.Add(context.Background(), "foo", value); err != nil {
+		t.Fatalf("unexpected error adding cache entry, error %v", err)
+	}
+	c.Terminate()
+
+	c, err = NewBolt(path, time.Hour, time.Hour, NewDefaultSerializer())
+	if err != nil {
+		t.Fatalf("unexpected error reopening cache, error %v", err)
+	}
+	defer c.Terminate()
+
+	res, err := c.Get(context.Background(), "foo")
+	if err != nil {
+		t.Fatalf("unexpected error getting cache entry, error %v", err)
+	}
+
+	v, ok := res.([]*provider.Contributor)
+	if !ok {
+		t.Fatalf("unexpected type on cache, got %T", res)
+	}
+
+	if v[0].Name != value[0].Name {
+		t.Errorf("expected values do not match, expected %s got %s", value[0].Name, v[0].Name)
+	}
+}
+
+func TestBoltCacheExpiresEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolt")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	c, err := NewBolt(filepath.Join(dir, "cache.db"), time.Millisecond*100, time.Hour, NewDefaultSerializer())
+	if err != nil {
+		t.Fatalf("unexpected error creating cache, error %v", err)
+	}
+	defer c.Terminate()
+
+	value := []*provider.Contributor{{ID: 123, Name: "fooBar"}}
+	_ = c.Add(context.Background(), "key_1", value)
+	_ = c.Add(context.Background(), "key_2", value)
+	_ = c.Add(context.Background(), "key_3", value)
+
+	if c.Len() != 3 {
+		t.Errorf("Unexpected cache size, expected 3 got %d", c.Len())
+	}
+
+	time.Sleep(time.Millisecond * 150)
+
+	_, err = c.Get(context.Background(), "key_1")
+	if !errors.Is(err, provider.ErrCacheMiss) {
+		t.Errorf("unexpected error type, got %v", err)
+	}
+
+	if err := c.expire(); err != nil {
+		t.Errorf("Unexpected error expiring cache, err: %s", err.Error())
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("Unexpected cache size, expected 0 got %d", c.Len())
+	}
+}
@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"github.com/vmihailenco/msgpack/v5"
+	"io/ioutil"
+	"reflect"
+	"sync"
+)
+
+const (
+	// EnvelopeVersion defines current serialized entry format version
+	EnvelopeVersion uint8 = 1
+
+	// envelope header: version, codec, compression and type tag length
+	headerSize = 4
+
+	// MaxTagSize bounds type tag length, encoded on a single header byte
+	MaxTagSize = 255
+
+	// ContributorsType tags contributor lists
+	ContributorsType = "contributors"
+	// NegativeType tags negative cache entries
+	NegativeType = "negative"
+)
+
+const (
+	// NoCompression stores payload as it is
+	NoCompression Compression = iota
+	// GzipCompression compress payload using gzip
+	GzipCompression
+	// SnappyCompression compress payload using snappy
+	SnappyCompression
+)
+
+var (
+	// ErrUnknownVersion happens decoding entries from a non supported envelope version
+	ErrUnknownVersion = errors.New("unknown envelope version")
+	// ErrUnknownType happens on non registered type tags
+	ErrUnknownType = errors.New("unknown type")
+	// ErrInvalidTag happens on empty type tags or longer than MaxTagSize
+	ErrInvalidTag = errors.New("invalid type tag")
+	// ErrUnknownCodec happens on non registered codecs
+	ErrUnknownCodec = errors.New("unknown codec")
+	// ErrUnknownCompression happens on non supported compression
+	ErrUnknownCompression = errors.New("unknown compression")
+	// ErrInvalidEnvelope happens on truncated or malformed entries
+	ErrInvalidEnvelope = errors.New("invalid envelope")
+)
+
+// Codec defines a pluggable value serialization format
+type Codec interface {
+	// ID identifies codec on envelope header
+	ID() uint8
+
+	// Name returns codec name
+	Name() string
+
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as json
+type JSONCodec struct{}
+
+// ID returns codec identifier
+func (JSONCodec) ID() uint8 { return 1 }
+
+// Name returns codec name
+func (JSONCodec) Name() string { return "json" }
+
+// Marshal encodes value as json
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decodes json data into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// MsgpackCodec encodes values as msgpack
+type MsgpackCodec struct{}
+
+// ID returns codec identifier
+func (MsgpackCodec) ID() uint8 { return 2 }
+
+// Name returns codec name
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+// Marshal encodes value as msgpack
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) { return msgpack.Marshal(v) }
+
+// Unmarshal decodes msgpack data into v
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+// ParseCodec returns codec by name
+func ParseCodec(name string) (Codec, error) {
+	switch name {
+	case "", JSONCodec{}.Name():
+		return JSONCodec{}, nil
+	case MsgpackCodec{}.Name():
+		return MsgpackCodec{}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+}
+
+// Compression defines payload compression algorithm
+type Compression uint8
+
+// ParseCompression returns compression by name
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "", "none":
+		return NoCompression, nil
+	case "gzip":
+		return GzipCompression, nil
+	case "snappy":
+		return SnappyCompression, nil
+	}
+
+	return NoCompression, fmt.Errorf("%w: %s", ErrUnknownCompression, name)
+}
+
+func (c Compression) compress(raw []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return raw, nil
+	case GzipCompression:
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(raw); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case SnappyCompression:
+		return snappy.Encode(nil, raw), nil
+	}
+
+	return nil, ErrUnknownCompression
+}
+
+func (c Compression) decompress(raw []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return raw, nil
+	case GzipCompression:
+		r, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+		return ioutil.ReadAll(r)
+	case SnappyCompression:
+		return snappy.Decode(nil, raw)
+	}
+
+	return nil, ErrUnknownCompression
+}
+
+// TypeRegistry maps type tags to cached value types, so that entries can be decoded to its original type
+type TypeRegistry struct {
+	byTag  map[string]reflect.Type
+	byType map[reflect.Type]string
+	mutex  sync.RWMutex
+}
+
+// NewTypeRegistry instantiates an empty type registry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		byTag:  make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+// NewDefaultTypeRegistry instantiates a type registry with all provider types
+func NewDefaultTypeRegistry() *TypeRegistry {
+	r := NewTypeRegistry()
+	_ = r.Register(ContributorsType, []*provider.Contributor{})
+	_ = r.Register(NegativeType, &provider.NegativeEntry{})
+
+	return r
+}
+
+// Register binds tag to v type, tags must fit on envelope header
+func (r *TypeRegistry) Register(tag string, v interface{}) error {
+	if err := validTag(tag); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	t := reflect.TypeOf(v)
+	r.byTag[tag] = t
+	r.byType[t] = tag
+
+	return nil
+}
+
+func (r *TypeRegistry) tag(v interface{}) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tag, ok := r.byType[reflect.TypeOf(v)]
+	if !ok {
+		return "", fmt.Errorf("%w: %T", ErrUnknownType, v)
+	}
+
+	return tag, nil
+}
+
+func (r *TypeRegistry) new(tag string) (reflect.Value, error) {
+	if err := validTag(tag); err != nil {
+		return reflect.Value{}, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	t, ok := r.byTag[tag]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%w: %s", ErrUnknownType, tag)
+	}
+
+	return reflect.New(t), nil
+}
+
+// validTag rejects tags that can not be encoded, so that they are never cut to a different tag
+func validTag(tag string) error {
+	if len(tag) == 0 || len(tag) > MaxTagSize {
+		return ErrInvalidTag
+	}
+
+	return nil
+}
+
+// Serializer encodes cache values in a versioned envelope:
+// version(1) | codec(1) | compression(1) | tag length(1) | tag | payload
+type Serializer struct {
+	codec       Codec
+	compression Compression
+	codecs      map[uint8]Codec
+	registry    *TypeRegistry
+}
+
+// NewSerializer instantiates serializer, entries are encoded using codec and compression,
+// decoding works on any known codec
+func NewSerializer(codec Codec, compression Compression, registry *TypeRegistry) *Serializer {
+	codecs := map[uint8]Codec{}
+	for _, c := range []Codec{JSONCodec{}, MsgpackCodec{}, codec} {
+		codecs[c.ID()] = c
+	}
+
+	return &Serializer{
+		codec:       codec,
+		compression: compression,
+		codecs:      codecs,
+		registry:    registry,
+	}
+}
+
+// NewDefaultSerializer instantiates an uncompressed json serializer over default type registry
+func NewDefaultSerializer() *Serializer {
+	return NewSerializer(JSONCodec{}, NoCompression, NewDefaultTypeRegistry())
+}
+
+// Encode wraps v in an envelope
+func (s *Serializer) Encode(v interface{}) ([]byte, error) {
+	tag, err := s.registry.tag(v)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := s.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := s.compression.compress(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, 0, headerSize+len(tag)+len(payload))
+	res = append(res, EnvelopeVersion, s.codec.ID(), uint8(s.compression), uint8(len(tag)))
+	res = append(res, tag...)
+
+	return append(res, payload...), nil
+}
+
+// Decode unwraps envelope returning value with its registered type
+func (s *Serializer) Decode(raw []byte) (interface{}, error) {
+	if len(raw) < headerSize {
+		return nil, ErrInvalidEnvelope
+	}
+
+	if raw[0] != EnvelopeVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, raw[0])
+	}
+
+	codec, ok := s.codecs[raw[1]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCodec, raw[1])
+	}
+
+	tagEnd := headerSize + int(raw[3])
+	if len(raw) < tagEnd {
+		return nil, ErrInvalidEnvelope
+	}
+
+	v, err := s.registry.new(string(raw[headerSize:tagEnd]))
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := Compression(raw[2]).decompress(raw[tagEnd:])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := codec.Unmarshal(payload, v.Interface()); err != nil {
+		return nil, err
+	}
+
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"errors"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"strings"
+	"testing"
+)
+
+var serializerDataProvider = []struct {
+	codec       Codec
+	compression Compression
+}{
+	{JSONCodec{}, NoCompression},
+	{JSONCodec{}, GzipCompression},
+	{JSONCodec{}, SnappyCompression},
+	{MsgpackCodec{}, NoCompression},
+	{MsgpackCodec{}, GzipCompression},
+	{MsgpackCodec{}, SnappyCompression},
+}
+
+func TestSerializerEncodesAndDecodesContributors(t *testing.T) {
+	value := []*provider.Contributor{{ID: 123, Name: "fooBar", Url: "http://foo.bar"}, {ID: 124, Name: "zoo"}}
+
+	for _, d := range serializerDataProvider {
+		s := NewSerializer(d.codec, d.compression, NewDefaultTypeRegistry())
+		raw, err := s.Encode(value)
+		if err != nil {
+			t.Fatalf("unexpected error encoding with %s, error %v", d.codec.Name(), err)
+		}
+
+		res, err := s.Decode(raw)
+		if err != nil {
+			t.Fatalf("unexpected error decoding with %s, error %v", d.codec.Name(), err)
+		}
+
+		v, ok := res.([]*provider.Contributor)
+		if !ok {
+			t.Fatalf("unexpected decoded type, got %T", res)
+		}
+
+		if len(v) != len(value) {
+			t.Fatalf("unexpected decoded size, expected %d got %d", len(value), len(v))
+		}
+
+		if v[0].Name != value[0].Name || v[0].ID != value[0].ID || v[0].Url != value[0].Url {
+			t.Errorf("expected values do not match, expected %v got %v", value[0], v[0])
+		}
+	}
+}
+
+func TestSerializerDecodesEntriesFromAnyKnownCodec(t *testing.T) {
+	value := []*provider.Contributor{{ID: 123, Name: "fooBar"}}
+	raw, err := NewSerializer(MsgpackCodec{}, SnappyCompression, NewDefaultTypeRegistry()).Encode(value)
+	if err != nil {
+		t.Fatalf("unexpected error encoding, error %v", err)
+	}
+
+	res, err := NewDefaultSerializer().Decode(raw)
+	if err != nil {
+		t.Fatalf("unexpected error decoding, error %v", err)
+	}
+
+	if v := res.([]*provider.Contributor); v[0].Name != value[0].Name {
+		t.Errorf("expected values do not match, expected %s got %s", value[0].Name, v[0].Name)
+	}
+}
+
+func TestSerializerDecodesRegisteredTypes(t *testing.T) {
+	type etag struct {
+		Value string
+	}
+
+	r := NewDefaultTypeRegistry()
+	if err := r.Register("etag", &etag{}); err != nil {
+		t.Fatalf("unexpected error registering type, error %v", err)
+	}
+	s := NewSerializer(JSONCodec{}, GzipCompression, r)
+
+	raw, err := s.Encode(&etag{Value: "W/foo"})
+	if err != nil {
+		t.Fatalf("unexpected error encoding, error %v", err)
+	}
+
+	res, err := s.Decode(raw)
+	if err != nil {
+		t.Fatalf("unexpected error decoding, error %v", err)
+	}
+
+	v, ok := res.(*etag)
+	if !ok {
+		t.Fatalf("unexpected decoded type, got %T", res)
+	}
+
+	if v.Value != "W/foo" {
+		t.Errorf("unexpected value, got %s", v.Value)
+	}
+}
+
+func TestSerializerOnUnregisteredTypeFails(t *testing.T) {
+	_, err := NewDefaultSerializer().Encode("foo")
+	if !errors.Is(err, ErrUnknownType) {
+		t.Errorf("unexpected error type, got %v", err)
+	}
+}
+
+func TestTypeRegistryRejectsTagsNotFittingOnHeader(t *testing.T) {
+	r := NewTypeRegistry()
+	for _, tag := range []string{"", strings.Repeat("a", MaxTagSize+1)} {
+		if err := r.Register(tag, &provider.NegativeEntry{}); err != ErrInvalidTag {
+			t.Errorf("unexpected error registering tag size %d, got %v", len(tag), err)
+		}
+	}
+
+	if err := r.Register(strings.Repeat("a", MaxTagSize), &provider.NegativeEntry{}); err != nil {
+		t.Errorf("unexpected error registering max size tag, error %v", err)
+	}
+}
+
+func TestSerializerOnUnknownVersionFails(t *testing.T) {
+	s := NewDefaultSerializer()
+	raw, err := s.Encode([]*provider.Contributor{{ID: 123}})
+	if err != nil {
+		t.Fatalf("unexpected error encoding, error %v", err)
+	}
+
+	raw[0] = EnvelopeVersion + 1
+	_, err = s.Decode(raw)
+	if !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("unexpected error type, got %v", err)
+	}
+
+	// entries written before envelopes were introduced are plain json arrays
+	_, err = s.Decode([]byte(`[{"id":123,"name":"fooBar"}]`))
+	if !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("unexpected error type, got %v", err)
+	}
+}
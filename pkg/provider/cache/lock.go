@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// DefaultLockPrefix namespaces cache fill leases
+const DefaultLockPrefix = "lock:"
+
+// unlock deletes lease just if token still holds it, so that taken over leases are not released
+var unlock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLock leases cache keys across replicas, leases expire on its own if holder dies
+type RedisLock struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisLock instantiates redis cache lock, prefix defaults to DefaultLockPrefix
+func NewRedisLock(cl redis.UniversalClient, prefix string) *RedisLock {
+	if prefix == "" {
+		prefix = DefaultLockPrefix
+	}
+
+	return &RedisLock{
+		client: cl,
+		prefix: prefix,
+	}
+}
+
+// TryLock takes key lease for ttl, false if held by another replica
+func (l *RedisLock) TryLock(ctx context.Context, k string, ttl time.Duration) (string, bool, error) {
+	token, err := newLeaseToken()
+	if err != nil {
+		return "", false, err
+	}
+
+	ok, err := l.client.SetNX(ctx, l.prefix+k, token, ttl).Result()
+	if err != nil {
+		return "", false, err
+	}
+
+	return token, ok, nil
+}
+
+// Unlock releases key lease if token still holds it
+func (l *RedisLock) Unlock(ctx context.Context, k, token string) error {
+	return unlock.Run(ctx, l.client, []string{l.prefix + k}, token).Err()
+}
+
+func newLeaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
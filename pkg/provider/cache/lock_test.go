@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"testing"
+	"time"
+)
+
+func TestRedisLockLeasesKeyToSingleHolderUntilReleasedOrExpired(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer func() {
+		_ = cl.Close()
+	}()
+
+	ctx := context.Background()
+	l := NewRedisLock(cl, "test:lock:")
+	defer func() {
+		_ = cl.Del(ctx, "test:lock:foo").Err()
+	}()
+
+	token, ok, err := l.TryLock(ctx, "foo", time.Second)
+	if err != nil || !ok {
+		t.Fatalf("Unexpected lease result, ok %t error %v", ok, err)
+	}
+
+	if _, ok, _ := l.TryLock(ctx, "foo", time.Second); ok {
+		t.Fatal("Unexpected lease taken while held")
+	}
+
+	if err := l.Unlock(ctx, "foo", "another-token"); err != nil {
+		t.Fatalf("Unexpected error releasing lease, error %v", err)
+	}
+	if _, ok, _ := l.TryLock(ctx, "foo", time.Second); ok {
+		t.Fatal("Unexpected lease released by another token")
+	}
+
+	if err := l.Unlock(ctx, "foo", token); err != nil {
+		t.Fatalf("Unexpected error releasing lease, error %v", err)
+	}
+	if _, ok, _ := l.TryLock(ctx, "foo", time.Millisecond*50); !ok {
+		t.Fatal("Unexpected lease not taken once released")
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if _, ok, _ := l.TryLock(ctx, "foo", time.Second); !ok {
+		t.Error("Unexpected lease not taken once expired")
+	}
+}
@@ -55,11 +55,16 @@ func NewLRUCache(ttl, freq time.Duration) (*LruCache, error) {
 }
 
 // Add LRU entry
-func (c *LruCache) Add(_ context.Context, k string, v interface{}) error {
+func (c *LruCache) Add(ctx context.Context, k string, v interface{}) error {
+	return c.AddWithTTL(ctx, k, v, c.ttl)
+}
+
+// AddWithTTL adds LRU entry with its own expiration
+func (c *LruCache) AddWithTTL(_ context.Context, k string, v interface{}, ttl time.Duration) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	entry := newEntry(c.ttl, v)
+	entry := newEntry(ttl, v)
 	c.lru.Add(k, entry)
 
 	return nil
@@ -80,9 +85,39 @@ func (c *LruCache) Get(_ context.Context, k string) (interface{}, error) {
 		return nil, ErrUnexpectedType
 	}
 
+	// entries with shorter ttl may be expired before worker reaches them
+	if vv.Expire.Before(time.Now()) {
+		return nil, provider.ErrCacheMiss
+	}
+
 	return vv.Value, nil
 }
 
+// Range iterates non expired entries
+func (c *LruCache) Range(_ context.Context, fn func(k string, v interface{}, expire time.Time) error) error {
+	c.mutex.RLock()
+	entries := make(map[string]*Entry, c.lru.Len())
+	for _, k := range c.lru.Keys() {
+		if v, ok := c.lru.Peek(k); ok {
+			entries[k.(string)] = v.(*Entry)
+		}
+	}
+	c.mutex.RUnlock()
+
+	now := time.Now()
+	for k, e := range entries {
+		if e.Expire.Before(now) {
+			continue
+		}
+
+		if err := fn(k, e.Value, e.Expire); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Len returns cache size
 func (c *LruCache) Len() int {
 	c.mutex.RLock()
@@ -2,6 +2,8 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"testing"
 	"time"
 )
@@ -61,3 +63,24 @@ func TestAddEntriesToCacheAndExpirerCleansOldEntries(t *testing.T) {
 
 	c.Terminate()
 }
+
+func TestAddEntryWithTTLExpiresBeforeOlderEntries(t *testing.T) {
+	c, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, err: %s", err.Error())
+	}
+	defer c.Terminate()
+
+	_ = c.Add(context.Background(), "key_1", "foo")
+	_ = c.AddWithTTL(context.Background(), "key_2", "bar", time.Millisecond*50)
+
+	time.Sleep(time.Millisecond * 100)
+
+	if _, err := c.Get(context.Background(), "key_1"); err != nil {
+		t.Errorf("Unexpected error getting entry, err: %s", err.Error())
+	}
+
+	if _, err := c.Get(context.Background(), "key_2"); !errors.Is(err, provider.ErrCacheMiss) {
+		t.Errorf("Unexpected error getting expired entry, got %v", err)
+	}
+}
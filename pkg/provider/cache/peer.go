@@ -0,0 +1,292 @@
+package cache
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PeerPath defines owned entries lookup endpoint
+	PeerPath = "/internal/cache/entry"
+	// PeerSecretHeader carries shared secret on peer requests
+	PeerSecretHeader = "X-Peer-Secret"
+	// defaultPeerTimeout bounds each owner lookup, loads on owner included
+	defaultPeerTimeout = time.Second * 10
+)
+
+// PeerConfig defines peer group, defaults on zero values
+type PeerConfig struct {
+	// Self base url, as http://10.0.0.1:8000, it must be listed as it is by other peers
+	Self string
+	// Peers base urls, self is added if missing
+	Peers []string
+	// Replicas defines virtual nodes by peer
+	Replicas int
+	// Secret is required on peer requests, as owners request github on misses, they are refused without it
+	Secret     string
+	Serializer *Serializer
+	Client     *http.Client
+}
+
+type peerForwardedKey struct{}
+
+// Peer splits cache keys between peers using consistent hashing, each key owner is the only peer
+// storing it and loading it from repository, non owners ask the owner over http, so that github
+// is requested once by key across the group. If owner is unreachable lookup fails, cache middleware
+// falls back to repository and stores the entry locally, served until it expires.
+type Peer struct {
+	local      provider.Cache
+	self       string
+	replicas   int
+	secret     string
+	serializer *Serializer
+	client     *http.Client
+	ring       *Ring
+	mutex      sync.RWMutex
+}
+
+// NewPeer instantiates peer cache on top of local cache
+func NewPeer(local provider.Cache, cfg PeerConfig) *Peer {
+	if cfg.Serializer == nil {
+		cfg.Serializer = NewDefaultSerializer()
+	}
+
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultPeerTimeout}
+	}
+
+	p := &Peer{
+		local:      local,
+		self:       strings.TrimRight(cfg.Self, "/"),
+		replicas:   cfg.Replicas,
+		secret:     cfg.Secret,
+		serializer: cfg.Serializer,
+		client:     cfg.Client,
+	}
+	p.SetPeers(cfg.Peers)
+
+	return p
+}
+
+// SetPeers replaces peer group, just keys from added or removed peers move
+func (p *Peer) SetPeers(peers []string) {
+	nodes := []string{p.self}
+	for _, n := range peers {
+		n = strings.TrimRight(strings.TrimSpace(n), "/")
+		if n != "" && n != p.self {
+			nodes = append(nodes, n)
+		}
+	}
+	r := NewRing(p.replicas, nodes...)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ring = r
+}
+
+// Owner returns key owner base url
+func (p *Peer) Owner(k string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.ring.Owner(k)
+}
+
+// Add entry to local cache
+func (p *Peer) Add(ctx context.Context, k string, v interface{}) error {
+	return p.local.Add(ctx, k, v)
+}
+
+// AddWithTTL adds entry to local cache with its own expiration, non owners only add entries
+// loaded while owner was unreachable
+func (p *Peer) AddWithTTL(ctx context.Context, k string, v interface{}, ttl time.Duration) error {
+	return p.local.AddWithTTL(ctx, k, v, ttl)
+}
+
+// Get entry from local cache on owned keys, from key owner otherwise
+func (p *Peer) Get(ctx context.Context, k string) (interface{}, error) {
+	if forwarded(ctx) {
+		return p.local.Get(ctx, k)
+	}
+
+	owner := p.Owner(k)
+	if owner == p.self {
+		return p.local.Get(ctx, k)
+	}
+
+	if v, err := p.local.Get(ctx, k); err == nil {
+		return v, nil
+	}
+
+	return p.fetch(ctx, owner, k)
+}
+
+// Range iterates local entries, on iterable local caches
+func (p *Peer) Range(ctx context.Context, fn func(k string, v interface{}, expire time.Time) error) error {
+	it, ok := p.local.(Iterable)
+	if !ok {
+		return ErrNotIterable
+	}
+
+	return it.Range(ctx, fn)
+}
+
+// Terminate stops local cache
+func (p *Peer) Terminate() {
+	p.local.Terminate()
+}
+
+func (p *Peer) fetch(ctx context.Context, owner, k string) (interface{}, error) {
+	q := url.Values{"key": []string{k}}
+	if req, ok := provider.TopRequestFromContext(ctx); ok {
+		q.Set("city", req.City)
+		q.Set("size", strconv.Itoa(req.Size))
+		q.Set("version", req.Version)
+		q.Set("sort", req.Sort)
+		q["alias"] = req.Aliases
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, owner+PeerPath+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.secret != "" {
+		r.Header.Set(PeerSecretHeader, p.secret)
+	}
+
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, provider.ErrCacheMiss
+	default:
+		return nil, fmt.Errorf("unexpected peer %s response status %d", owner, resp.StatusCode)
+	}
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.serializer.Decode(raw)
+}
+
+// PeerHandler serves owned entries to peers, missing ones are loaded if request is known
+type PeerHandler struct {
+	peer   *Peer
+	loader provider.GithubRepository
+}
+
+// NewPeerHandler instantiates peer handler, loader is the cache middleware on top of peer cache,
+// so that loaded entries are cached by owner as usual
+func NewPeerHandler(p *Peer, loader provider.GithubRepository) *PeerHandler {
+	return &PeerHandler{
+		peer:   p,
+		loader: loader,
+	}
+}
+
+// ServeHTTP replies with serialized entry, negative cacheable loader errors are relayed as negative entries,
+// not cached by peers, transient ones (rate limits, timeouts, network errors) as bad gateway, so that peers
+// load it on its own. Lookups spend github budget on misses, so peers are always required to share a non empty secret.
+func (h *PeerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.peer.secret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(PeerSecretHeader)), []byte(h.peer.secret)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	k := q.Get("key")
+	if k == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), peerForwardedKey{}, true)
+	v, err := h.peer.local.Get(ctx, k)
+	if err == provider.ErrCacheMiss && h.loader != nil && q.Get("city") != "" {
+		v, err = h.load(ctx, k, q)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+	}
+
+	switch {
+	case err == provider.ErrCacheMiss:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	raw, err := h.peer.serializer.Encode(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(raw)
+}
+
+// load requests missing entry through loader, negative cacheable errors, stored as negative entries by loader
+// cache middleware, are returned as such, any other error is returned as it is
+func (h *PeerHandler) load(ctx context.Context, k string, q url.Values) (interface{}, error) {
+	size, err := strconv.Atoi(q.Get("size"))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := h.loader.GetGithubTopContributors(ctx, provider.GithubTopRequest{
+		City:    q.Get("city"),
+		Size:    size,
+		Version: q.Get("version"),
+		Sort:    q.Get("sort"),
+		Aliases: q["alias"],
+	})
+	if err == nil {
+		return res, nil
+	}
+
+	if e, ok := err.(*provider.NegativeCacheError); ok {
+		return &provider.NegativeEntry{Error: e.Message, StatusCode: e.StatusCode}, nil
+	}
+
+	if v, errc := h.peer.local.Get(ctx, k); errc == nil {
+		if n, ok := v.(*provider.NegativeEntry); ok {
+			return n, nil
+		}
+	}
+
+	return nil, err
+}
+
+// forwarded checks if lookup comes from a peer, so that it is served locally
+func forwarded(ctx context.Context) bool {
+	v, _ := ctx.Value(peerForwardedKey{}).(bool)
+
+	return v
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	peerHelperEnv      = "GITHUBTOP_PEER_HELPER"
+	peerHelperSelfEnv  = "GITHUBTOP_PEER_SELF"
+	peerHelperPeersEnv = "GITHUBTOP_PEER_PEERS"
+	peerTestSecret     = "s3cr3t"
+)
+
+func TestPeerCacheLoadsEachKeyOnOwnerAcrossProcesses(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	var peers []string
+	for i := 0; i < 3; i++ {
+		peers = append(peers, "http://"+freeAddr(t))
+	}
+
+	for _, self := range peers {
+		cmd := exec.Command(os.Args[0], "-test.run=TestPeerHelperProcess")
+		cmd.Env = append(os.Environ(),
+			peerHelperEnv+"=1",
+			peerHelperSelfEnv+"="+self,
+			peerHelperPeersEnv+"="+strings.Join(peers, ","),
+		)
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Unexpected error starting peer process, error %v", err)
+		}
+		defer func() {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}()
+	}
+
+	for _, p := range peers {
+		waitPeer(t, p)
+	}
+
+	cities := []string{"barcelona", "madrid", "paris", "london", "berlin", "rome", "lisbon", "dublin"}
+	for _, city := range cities {
+		for _, p := range peers {
+			var res []*provider.Contributor
+			getJSON(t, fmt.Sprintf("%s/top?city=%s&size=50", p, city), &res)
+			if len(res) != 1 || res[0].Name != city {
+				t.Fatalf("Unexpected contributors from peer %s on city %s, got %v", p, city, res)
+			}
+		}
+	}
+
+	ring := NewRing(DefaultRingReplicas, peers...)
+	var total int
+	for _, p := range peers {
+		var loads []string
+		getJSON(t, p+"/loads", &loads)
+		total += len(loads)
+
+		for _, city := range loads {
+			if owner := ring.Owner(fmt.Sprintf("city_%s_size_%d", city, 50)); owner != p {
+				t.Errorf("Unexpected city %s loaded by %s, owner is %s", city, p, owner)
+			}
+		}
+	}
+
+	if total != len(cities) {
+		t.Errorf("Unexpected repository loads, expected %d got %d", len(cities), total)
+	}
+}
+
+// TestPeerHelperProcess runs a cache peer when spawned from multi process test
+func TestPeerHelperProcess(t *testing.T) {
+	if os.Getenv(peerHelperEnv) != "1" {
+		return
+	}
+
+	self := os.Getenv(peerHelperSelfEnv)
+	lru, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, error %v", err)
+	}
+
+	p := NewPeer(lru, PeerConfig{
+		Self:   self,
+		Peers:  strings.Split(os.Getenv(peerHelperPeersEnv), ","),
+		Secret: peerTestSecret,
+	})
+	repo := &fakeLoadingRepository{}
+	mdw := provider.NewCacheMiddleware(p, repo)
+
+	mux := http.NewServeMux()
+	mux.Handle(PeerPath, NewPeerHandler(p, mdw))
+	mux.HandleFunc("/top", func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		res, err := mdw.GetGithubTopContributors(r.Context(), provider.GithubTopRequest{City: r.URL.Query().Get("city"), Size: size})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	})
+	mux.HandleFunc("/loads", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repo.cities())
+	})
+
+	_ = http.ListenAndServe(strings.TrimPrefix(self, "http://"), mux)
+	os.Exit(0)
+}
+
+func TestPeerCacheServesOwnedKeysLocallyAndRejectsUnauthorizedPeers(t *testing.T) {
+	lru, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, error %v", err)
+	}
+	defer lru.Terminate()
+
+	p := NewPeer(lru, PeerConfig{Self: "http://self", Secret: peerTestSecret})
+	srv := httptest.NewServer(NewPeerHandler(p, nil))
+	defer srv.Close()
+
+	ctx := context.Background()
+	if err := p.Add(ctx, "foo", []*provider.Contributor{{Name: "bar"}}); err != nil {
+		t.Fatalf("Unexpected error adding entry, error %v", err)
+	}
+
+	if _, err := p.Get(ctx, "foo"); err != nil {
+		t.Errorf("Unexpected error getting owned entry, error %v", err)
+	}
+
+	other := NewPeer(lru, PeerConfig{Self: "http://other", Secret: "invalid"})
+	if _, err := other.fetch(ctx, srv.URL, "foo"); err == nil || err == provider.ErrCacheMiss {
+		t.Errorf("Unexpected unauthorized fetch result, error %v", err)
+	}
+
+	other.secret = peerTestSecret
+	v, err := other.fetch(ctx, srv.URL, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error fetching entry, error %v", err)
+	}
+	if c, ok := v.([]*provider.Contributor); !ok || len(c) != 1 || c[0].Name != "bar" {
+		t.Errorf("Unexpected fetched entry, got %v", v)
+	}
+
+	if _, err := other.fetch(ctx, srv.URL, "missing"); err != provider.ErrCacheMiss {
+		t.Errorf("Unexpected missing entry error, got %v", err)
+	}
+}
+
+func TestPeerHandlerRejectsPeersWhenSecretIsNotDefined(t *testing.T) {
+	lru, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, error %v", err)
+	}
+	defer lru.Terminate()
+
+	srv := httptest.NewServer(NewPeerHandler(NewPeer(lru, PeerConfig{Self: "http://self"}), nil))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?key=foo")
+	if err != nil {
+		t.Fatalf("Unexpected response error, error %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Unexpected status code, expected %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestPeerHandlerRelaysJustNegativeCacheableLoaderErrors(t *testing.T) {
+	lru, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, error %v", err)
+	}
+	defer lru.Terminate()
+
+	loader := &failingRepository{err: context.DeadlineExceeded}
+	p := NewPeer(lru, PeerConfig{Self: "http://self", Secret: peerTestSecret})
+	srv := httptest.NewServer(NewPeerHandler(p, loader))
+	defer srv.Close()
+
+	other := NewPeer(lru, PeerConfig{Self: "http://other", Secret: peerTestSecret})
+	ctx := provider.WithTopRequest(context.Background(), provider.GithubTopRequest{City: "barcelona", Size: 50})
+	v, err := other.fetch(ctx, srv.URL, "foo")
+	if err == nil || err == provider.ErrCacheMiss {
+		t.Errorf("Unexpected transient loader error fetch result %v, error %v", v, err)
+	}
+
+	loader.err = &provider.NegativeCacheError{Message: "Validation Failed", StatusCode: http.StatusUnprocessableEntity}
+	v, err = other.fetch(ctx, srv.URL, "foo")
+	if err != nil {
+		t.Fatalf("Unexpected error fetching negative entry, error %v", err)
+	}
+	if n, ok := v.(*provider.NegativeEntry); !ok || n.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Unexpected fetched entry, got %v", v)
+	}
+}
+
+func TestLoadPeersSkipsCommentsAndEmptyLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peers")
+	if err != nil {
+		t.Fatalf("Unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "peers")
+	raw := "# cache peers\nhttp://10.0.0.1:8000\n\n  http://10.0.0.2:8000  \n"
+	if err := ioutil.WriteFile(path, []byte(raw), 0644); err != nil {
+		t.Fatalf("Unexpected error writing peers file, error %v", err)
+	}
+
+	peers, err := LoadPeers(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading peers, error %v", err)
+	}
+
+	if len(peers) != 2 || peers[0] != "http://10.0.0.1:8000" || peers[1] != "http://10.0.0.2:8000" {
+		t.Errorf("Unexpected peers, got %v", peers)
+	}
+}
+
+type fakeLoadingRepository struct {
+	loads []string
+	mutex sync.Mutex
+}
+
+func (f *fakeLoadingRepository) GetGithubTopContributors(_ context.Context, req provider.GithubTopRequest) ([]*provider.Contributor, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.loads = append(f.loads, req.City)
+
+	return []*provider.Contributor{{Name: req.City}}, nil
+}
+
+func (f *fakeLoadingRepository) cities() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.loads...)
+}
+
+type failingRepository struct {
+	err error
+}
+
+func (f *failingRepository) GetGithubTopContributors(_ context.Context, _ provider.GithubTopRequest) ([]*provider.Contributor, error) {
+	return nil, f.err
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error picking free port, error %v", err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+
+	return l.Addr().String()
+}
+
+func waitPeer(t *testing.T, peer string) {
+	for i := 0; i < 100; i++ {
+		if resp, err := http.Get(peer + "/loads"); err == nil {
+			_ = resp.Body.Close()
+			return
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	t.Fatalf("Unexpected peer %s not started", peer)
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Unexpected error requesting %s, error %v", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status requesting %s, got %d", url, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("Unexpected error decoding %s, error %v", url, err)
+	}
+}
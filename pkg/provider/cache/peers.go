@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"bufio"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPeersReloadFrequency defines peers file change checks frequency
+const DefaultPeersReloadFrequency = time.Second * 10
+
+// LoadPeers reads peers file, one base url by line, empty lines and # comments are skipped
+func LoadPeers(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var peers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		peers = append(peers, line)
+	}
+
+	return peers, scanner.Err()
+}
+
+// PeersWatcher reloads peer group from peers file on changes
+type PeersWatcher struct {
+	peer    *Peer
+	path    string
+	freq    time.Duration
+	modTime time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewPeersWatcher loads peers file into peer group, watched on Run
+func NewPeersWatcher(p *Peer, path string, freq time.Duration) (*PeersWatcher, error) {
+	if freq <= 0 {
+		freq = DefaultPeersReloadFrequency
+	}
+
+	w := &PeersWatcher{
+		peer: p,
+		path: path,
+		freq: freq,
+		done: make(chan struct{}),
+	}
+
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Run starts watcher worker
+func (w *PeersWatcher) Run() {
+	w.wg.Add(1)
+	go w.runner()
+}
+
+// Terminate stops watcher worker
+func (w *PeersWatcher) Terminate() {
+	close(w.done)
+	w.wg.Wait()
+}
+
+func (w *PeersWatcher) runner() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.freq)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := w.reload()
+			if err != nil {
+				log.Errorf("unexpected error reloading cache peers file %s, error %v", w.path, err)
+				continue
+			}
+			if changed {
+				log.Infof("cache peers reloaded from %s", w.path)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// reload sets peers file group if file was modified since last load
+func (w *PeersWatcher) reload() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+
+	if info.ModTime().Equal(w.modTime) {
+		return false, nil
+	}
+
+	peers, err := LoadPeers(w.path)
+	if err != nil {
+		return false, err
+	}
+	w.peer.SetPeers(peers)
+	w.modTime = info.ModTime()
+
+	return true, nil
+}
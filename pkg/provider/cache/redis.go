@@ -2,55 +2,133 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"time"
 )
 
 // Redis implements a cache with expiration
 type Redis struct {
-	client *redis.Client
-	ttl    time.Duration
+	client     redis.UniversalClient
+	prefix     string
+	ttl        time.Duration
+	serializer *Serializer
 }
 
-// NewRedis instantiates redis cache
-func NewRedis(cl *redis.Client, ttl time.Duration) *Redis {
+// RedisConfig defines redis cache parameters
+type RedisConfig struct {
+	// Prefix namespaces cache keys, so that client can be shared
+	Prefix     string
+	TTL        time.Duration
+	Serializer *Serializer
+}
+
+// NewRedis instantiates redis cache using default serializer
+func NewRedis(cl redis.UniversalClient, ttl time.Duration) *Redis {
+	return NewRedisWithConfig(cl, RedisConfig{TTL: ttl, Serializer: NewDefaultSerializer()})
+}
+
+// NewRedisWithConfig instantiates redis cache
+func NewRedisWithConfig(cl redis.UniversalClient, cfg RedisConfig) *Redis {
 	return &Redis{
-		client: cl,
-		ttl:    ttl,
+		client:     cl,
+		prefix:     cfg.Prefix,
+		ttl:        cfg.TTL,
+		serializer: cfg.Serializer,
 	}
 }
 
 // Add Cache entry
 func (r *Redis) Add(ctx context.Context, k string, v interface{}) error {
-	raw, err := json.Marshal(v)
+	return r.AddWithTTL(ctx, k, v, r.ttl)
+}
+
+// AddWithTTL adds cache entry with its own expiration
+func (r *Redis) AddWithTTL(ctx context.Context, k string, v interface{}, ttl time.Duration) error {
+	raw, err := r.serializer.Encode(v)
 	if err != nil {
 		return err
 	}
-	cmd := r.client.Set(ctx, k, raw, r.ttl)
+	cmd := r.client.Set(ctx, r.key(k), raw, ttl)
 
 	return cmd.Err()
 }
 
-// Get cache entry
+// Get cache entry, entries from unknown envelope versions or types are considered misses
 func (r *Redis) Get(ctx context.Context, k string) (interface{}, error) {
-	cmd := r.client.Get(ctx, k)
+	cmd := r.client.Get(ctx, r.key(k))
 	if cmd.Err() == redis.Nil {
 		return nil, provider.ErrCacheMiss
 	}
-	raw, err := cmd.Result()
+	raw, err := cmd.Bytes()
 	if err != nil {
 		return nil, err
 	}
 
-	var res []*provider.Contributor
-	err = json.Unmarshal([]byte(raw), &res)
+	res, err := r.serializer.Decode(raw)
+	if errors.Is(err, ErrUnknownVersion) || errors.Is(err, ErrUnknownType) {
+		log.Debugf("Discarding cache entry %s, error %v", k, err)
+
+		return nil, provider.ErrCacheMiss
+	}
 
 	return res, err
 }
 
-// Terminate stop cache
-func (r *Redis) Terminate() {
-	_ = r.client.Close()
+// Range iterates prefixed entries, on cluster mode all masters are scanned
+func (r *Redis) Range(ctx context.Context, fn func(k string, v interface{}, expire time.Time) error) error {
+	if cl, ok := r.client.(*redis.ClusterClient); ok {
+		return cl.ForEachMaster(ctx, func(ctx context.Context, m *redis.Client) error {
+			return r.scan(ctx, m, fn)
+		})
+	}
+
+	return r.scan(ctx, r.client, fn)
+}
+
+func (r *Redis) scan(ctx context.Context, cl redis.Cmdable, fn func(k string, v interface{}, expire time.Time) error) error {
+	iter := cl.Scan(ctx, 0, r.prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		k := iter.Val()
+		raw, err := cl.Get(ctx, k).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		ttl, err := cl.PTTL(ctx, k).Result()
+		if err != nil {
+			return err
+		}
+
+		// keys without expiration or already gone
+		if ttl < 0 {
+			continue
+		}
+
+		v, err := r.serializer.Decode(raw)
+		if errors.Is(err, ErrUnknownVersion) || errors.Is(err, ErrUnknownType) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(k[len(r.prefix):], v, time.Now().Add(ttl)); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+// Terminate stop cache, client is shared so it's closed by its owner
+func (r *Redis) Terminate() {}
+
+func (r *Redis) key(k string) string {
+	return r.prefix + k
 }
@@ -91,3 +91,30 @@ func TestAddEntryOnRedisCacheAndWaitExpiration(t *testing.T) {
 		t.Errorf("unexpected error type, got %t", err)
 	}
 }
+
+func TestRedisCacheOnUnknownEnvelopeVersionIsCacheMiss(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	opt := &redis.Options{
+		Network: "",
+		Addr:    ":6379",
+		DB:      0,
+	}
+	cl := redis.NewClient(opt)
+	s := NewSerializer(MsgpackCodec{}, GzipCompression, NewDefaultTypeRegistry())
+	r := NewRedisWithConfig(cl, RedisConfig{TTL: time.Second, Serializer: s})
+
+	key := "foo"
+	err := cl.Set(context.Background(), key, `[{"id":123,"name":"fooBar"}]`, time.Second).Err()
+	if err != nil {
+		t.Fatalf("unexpected error adding raw entry, error %v", err)
+	}
+
+	_, err = r.Get(context.Background(), key)
+	if !errors.Is(err, provider.ErrCacheMiss) {
+		t.Errorf("unexpected error type, got %v", err)
+	}
+}
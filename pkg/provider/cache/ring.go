@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultRingReplicas defines virtual nodes by peer, spreading keys evenly
+const DefaultRingReplicas = 100
+
+// Ring assigns keys to peers using consistent hashing, so that adding or removing a peer
+// just moves its own keys
+type Ring struct {
+	replicas int
+	hashes   []uint32
+	nodes    map[uint32]string
+}
+
+// NewRing instantiates a ring with replicas virtual nodes by peer
+func NewRing(replicas int, nodes ...string) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultRingReplicas
+	}
+
+	r := &Ring{
+		replicas: replicas,
+		nodes:    make(map[uint32]string),
+	}
+	for _, n := range nodes {
+		for i := 0; i < replicas; i++ {
+			h := hash(n + "#" + strconv.Itoa(i))
+			r.hashes = append(r.hashes, h)
+			r.nodes[h] = n
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+
+	return r
+}
+
+// Owner returns peer owning key, empty on empty rings
+func (r *Ring) Owner(k string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := hash(k)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+
+	return r.nodes[r.hashes[i]]
+}
+
+func hash(k string) uint32 {
+	return crc32.ChecksumIEEE([]byte(k))
+}
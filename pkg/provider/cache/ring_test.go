@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingAssignsKeysToAllPeersAndMovesJustRemovedPeerKeys(t *testing.T) {
+	peers := []string{"http://a:8000", "http://b:8000", "http://c:8000"}
+	r := NewRing(DefaultRingReplicas, peers...)
+
+	owners := map[string]string{}
+	byPeer := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		k := fmt.Sprintf("city_%d_size_50", i)
+		owners[k] = r.Owner(k)
+		byPeer[owners[k]]++
+	}
+
+	for _, p := range peers {
+		if byPeer[p] < 500 {
+			t.Errorf("Unexpected keys owned by peer %s, got %d", p, byPeer[p])
+		}
+	}
+
+	shrunk := NewRing(DefaultRingReplicas, peers[:2]...)
+	for k, owner := range owners {
+		if owner != peers[2] && shrunk.Owner(k) != owner {
+			t.Fatalf("Unexpected key %s moved from %s to %s", k, owner, shrunk.Owner(k))
+		}
+	}
+}
+
+func TestEmptyRingHasNoOwner(t *testing.T) {
+	if o := NewRing(0).Owner("foo"); o != "" {
+		t.Errorf("Unexpected owner, got %s", o)
+	}
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io"
+	"time"
+)
+
+const (
+	// maxSnapshotLine bounds snapshot entry size
+	maxSnapshotLine = 16 * 1024 * 1024
+	// DefaultSnapshotMaxTTL bounds imported entries ttl when none is configured
+	DefaultSnapshotMaxTTL = time.Hour * 72
+)
+
+// ErrNotIterable happens on caches that can not enumerate its entries
+var ErrNotIterable = errors.New("cache is not iterable")
+
+// Iterable enumerates cache entries with its expiration
+type Iterable interface {
+	Range(ctx context.Context, fn func(k string, v interface{}, expire time.Time) error) error
+}
+
+// SnapshotEntry defines a portable cache entry, snapshots are written as one json entry per line
+type SnapshotEntry struct {
+	Key    string          `json:"key"`
+	Type   string          `json:"type"`
+	Expire time.Time       `json:"expire"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// Snapshotter dumps and restores cache contents
+type Snapshotter struct {
+	cache    provider.Cache
+	registry *TypeRegistry
+	maxTTL   time.Duration
+}
+
+// NewSnapshotter instantiates snapshotter, registry types are the ones that can be dumped and restored,
+// imported entries ttl is clamped to maxTTL (DefaultSnapshotMaxTTL if not positive)
+func NewSnapshotter(c provider.Cache, r *TypeRegistry, maxTTL time.Duration) *Snapshotter {
+	if maxTTL <= 0 {
+		maxTTL = DefaultSnapshotMaxTTL
+	}
+
+	return &Snapshotter{
+		cache:    c,
+		registry: r,
+		maxTTL:   maxTTL,
+	}
+}
+
+// Export writes non expired entries, entries from non registered types are skipped
+func (s *Snapshotter) Export(ctx context.Context, w io.Writer) (int, error) {
+	it, ok := s.cache.(Iterable)
+	if !ok {
+		return 0, ErrNotIterable
+	}
+
+	var total int
+	enc := json.NewEncoder(w)
+	err := it.Range(ctx, func(k string, v interface{}, expire time.Time) error {
+		tag, err := s.registry.tag(v)
+		if err != nil {
+			return nil
+		}
+
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		total++
+		return enc.Encode(SnapshotEntry{Key: k, Type: tag, Expire: expire, Value: raw})
+	})
+
+	return total, err
+}
+
+// Import restores entries keeping its remaining ttl up to max ttl, expired ones are skipped
+func (s *Snapshotter) Import(ctx context.Context, r io.Reader) (int, error) {
+	var total int
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxSnapshotLine)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		e := SnapshotEntry{}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return total, fmt.Errorf("invalid snapshot entry, error %w", err)
+		}
+
+		ttl := time.Until(e.Expire)
+		if ttl <= 0 {
+			continue
+		}
+
+		if ttl > s.maxTTL {
+			ttl = s.maxTTL
+		}
+
+		v, err := s.registry.new(e.Type)
+		if err != nil {
+			return total, err
+		}
+
+		if err := json.Unmarshal(e.Value, v.Interface()); err != nil {
+			return total, fmt.Errorf("invalid snapshot entry %s value, error %w", e.Key, err)
+		}
+
+		if err := s.cache.AddWithTTL(ctx, e.Key, v.Elem().Interface(), ttl); err != nil {
+			return total, err
+		}
+		total++
+	}
+
+	return total, sc.Err()
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotExportAndImportKeepsRemainingTTL(t *testing.T) {
+	src, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, err: %s", err.Error())
+	}
+	defer src.Terminate()
+
+	_ = src.Add(context.Background(), "city_barcelona_size_50", []*provider.Contributor{{ID: 123, Name: "fooBar"}})
+	_ = src.AddWithTTL(context.Background(), "city_barcelonaa_size_50", &provider.NegativeEntry{}, time.Minute)
+	_ = src.AddWithTTL(context.Background(), "city_madrid_size_50", []*provider.Contributor{{ID: 1}}, time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+
+	b := &bytes.Buffer{}
+	n, err := NewSnapshotter(src, NewDefaultTypeRegistry(), 0).Export(context.Background(), b)
+	if err != nil {
+		t.Fatalf("unexpected error exporting snapshot, error %v", err)
+	}
+
+	if n != 2 {
+		t.Errorf("unexpected exported entries, expected 2 got %d", n)
+	}
+
+	dst, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, err: %s", err.Error())
+	}
+	defer dst.Terminate()
+
+	n, err = NewSnapshotter(dst, NewDefaultTypeRegistry(), 0).Import(context.Background(), b)
+	if err != nil {
+		t.Fatalf("unexpected error importing snapshot, error %v", err)
+	}
+
+	if n != 2 || dst.Len() != 2 {
+		t.Fatalf("unexpected imported entries, expected 2 got %d", n)
+	}
+
+	res, err := dst.Get(context.Background(), "city_barcelona_size_50")
+	if err != nil {
+		t.Fatalf("unexpected error getting entry, error %v", err)
+	}
+
+	if v := res.([]*provider.Contributor); v[0].Name != "fooBar" {
+		t.Errorf("unexpected imported value, got %s", v[0].Name)
+	}
+
+	v, _ := dst.lru.Peek("city_barcelonaa_size_50")
+	if ttl := time.Until(v.(*Entry).Expire); ttl > time.Minute {
+		t.Errorf("unexpected remaining ttl, got %s", ttl)
+	}
+}
+
+func TestSnapshotImportSkipsExpiredEntries(t *testing.T) {
+	dst, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, err: %s", err.Error())
+	}
+	defer dst.Terminate()
+
+	raw := `{"key":"city_barcelona_size_50","type":"contributors","expire":"2020-01-01T00:00:00Z","value":[{"id":1}]}`
+	n, err := NewSnapshotter(dst, NewDefaultTypeRegistry(), 0).Import(context.Background(), strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("unexpected error importing snapshot, error %v", err)
+	}
+
+	if n != 0 || dst.Len() != 0 {
+		t.Errorf("unexpected imported entries, got %d", n)
+	}
+}
+
+func TestSnapshotImportRejectsTooLongTypeTags(t *testing.T) {
+	dst, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, err: %s", err.Error())
+	}
+	defer dst.Terminate()
+
+	raw := `{"key":"city_barcelona_size_50","type":"` + strings.Repeat("a", MaxTagSize+1) + `","expire":"2100-01-01T00:00:00Z","value":[{"id":1}]}`
+	_, err = NewSnapshotter(dst, NewDefaultTypeRegistry(), 0).Import(context.Background(), strings.NewReader(raw))
+	if err != ErrInvalidTag {
+		t.Errorf("unexpected error importing snapshot, got %v", err)
+	}
+}
+
+func TestSnapshotImportClampsTTLToMax(t *testing.T) {
+	dst, err := NewLRUCache(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error creating cache, err: %s", err.Error())
+	}
+	defer dst.Terminate()
+
+	raw := `{"key":"city_barcelona_size_50","type":"contributors","expire":"2100-01-01T00:00:00Z","value":[{"id":1}]}`
+	n, err := NewSnapshotter(dst, NewDefaultTypeRegistry(), time.Hour).Import(context.Background(), strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("unexpected error importing snapshot, error %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected imported entries, got %d", n)
+	}
+
+	v, _ := dst.lru.Peek("city_barcelona_size_50")
+	if ttl := time.Until(v.(*Entry).Expire); ttl > time.Hour {
+		t.Errorf("unexpected remaining ttl, got %s", ttl)
+	}
+}
@@ -2,8 +2,14 @@ package provider
 
 import (
 	"context"
-
+	"errors"
+	"github.com/go-kit/kit/metrics"
+	"github.com/google/go-github/github"
+	"net/http"
+	"net/url"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRepositoryMiddlewareOnCacheHit(t *testing.T) {
@@ -90,18 +96,207 @@ func TestRepositoryMiddlewareOnCacheMiss(t *testing.T) {
 	}
 }
 
+func TestRepositoryMiddlewareCachesEmptyResultsAsNegativeEntries(t *testing.T) {
+	ch := &fakeCache{}
+	repo := &fakeRepository{contributors: []*Contributor{}}
+	lookups := &fakeCounter{}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{NegativeTTL: time.Minute, Lookups: lookups})
+
+	req := GithubTopRequest{City: "barcelonaa", Size: 50, Version: APIv1}
+	for i := 0; i < 2; i++ {
+		ctx := WithCacheStatus(context.Background())
+		v, err := r.GetGithubTopContributors(ctx, req)
+		if err != nil {
+			t.Fatalf("Unexpected error getting contributors, err: %s", err.Error())
+		}
+
+		if len(v) != 0 {
+			t.Errorf("Unexpected contributors size, expected 0 got %d", len(v))
+		}
+
+		expected := []CacheStatus{CacheMiss, CacheNegativeHit}[i]
+		if s := CacheStatusFromContext(ctx); s != expected {
+			t.Errorf("unexpected cache status, expected %s got %s", expected, s)
+		}
+	}
+
+	if ch.ttl != time.Minute {
+		t.Errorf("unexpected negative entry ttl, got %s", ch.ttl)
+	}
+
+	if repo.called != 1 {
+		t.Fatalf("unexpected repository total calls, expected 1 got %d", repo.called)
+	}
+
+	if lookups.statuses[CacheNegativeHit] != 1 || lookups.statuses[CacheMiss] != 1 {
+		t.Errorf("unexpected lookup metrics, got %v", lookups.statuses)
+	}
+}
+
+func TestRepositoryMiddlewareCachesUpstreamValidationErrors(t *testing.T) {
+	ch := &fakeCache{}
+	upstream := &github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusUnprocessableEntity, Request: &http.Request{Method: "GET", URL: &url.URL{}}},
+		Message:  "Validation Failed",
+	}
+	repo := &fakeRepository{err: upstream}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{NegativeTTL: time.Minute})
+
+	req := GithubTopRequest{City: "barcelona", Size: 50, Version: APIv1}
+	_, err := r.GetGithubTopContributors(context.Background(), req)
+	if err != upstream {
+		t.Fatalf("unexpected error, got %v", err)
+	}
+
+	_, err = r.GetGithubTopContributors(context.Background(), req)
+	var e *NegativeCacheError
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error type, got %T", err)
+	}
+
+	if e.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected status code, got %d", e.StatusCode)
+	}
+
+	if repo.called != 1 {
+		t.Fatalf("unexpected repository total calls, expected 1 got %d", repo.called)
+	}
+}
+
+func TestRepositoryMiddlewareDoesNotCacheTransientErrors(t *testing.T) {
+	ch := &fakeCache{}
+	repo := &fakeRepository{err: context.DeadlineExceeded}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{NegativeTTL: time.Minute})
+
+	req := GithubTopRequest{City: "barcelona", Size: 50, Version: APIv1}
+	for i := 0; i < 2; i++ {
+		_, err := r.GetGithubTopContributors(context.Background(), req)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("unexpected error, got %v", err)
+		}
+	}
+
+	if repo.called != 2 {
+		t.Fatalf("unexpected repository total calls, expected 2 got %d", repo.called)
+	}
+}
+
+func TestRepositoryMiddlewareWaitsOnLeasedKeysFilledByAnotherReplica(t *testing.T) {
+	ch := newFakeSharedCache()
+	lock := &fakeLock{leases: map[string]time.Time{"city_barcelona_size_2": time.Now().Add(time.Hour)}}
+	repo := &fakeRepository{contributors: []*Contributor{{ID: 3}}}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{FillLock: lock, FillPoll: time.Millisecond * 10})
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		_ = ch.Add(context.Background(), "city_barcelona_size_2", []*Contributor{{ID: 1}, {ID: 2}})
+	}()
+
+	ctx := WithCacheStatus(context.Background())
+	v, err := r.GetGithubTopContributors(ctx, GithubTopRequest{City: "barcelona", Size: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error getting contributors, err: %s", err.Error())
+	}
+
+	if len(v) != 2 || repo.called != 0 {
+		t.Errorf("Unexpected result, expected entry filled by lease holder, got %d contributors and %d repository calls", len(v), repo.called)
+	}
+
+	if s := CacheStatusFromContext(ctx); s != CacheHit {
+		t.Errorf("Unexpected cache status, got %s", s)
+	}
+}
+
+func TestRepositoryMiddlewareTakesOverExpiredLeases(t *testing.T) {
+	ch := newFakeSharedCache()
+	lock := &fakeLock{leases: map[string]time.Time{"city_barcelona_size_2": time.Now().Add(time.Millisecond * 50)}}
+	repo := &fakeRepository{contributors: []*Contributor{{ID: 1}, {ID: 2}}}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{FillLock: lock, FillPoll: time.Millisecond * 10})
+
+	v, err := r.GetGithubTopContributors(context.Background(), GithubTopRequest{City: "barcelona", Size: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error getting contributors, err: %s", err.Error())
+	}
+
+	if len(v) != 2 || repo.called != 1 {
+		t.Errorf("Unexpected result, got %d contributors and %d repository calls", len(v), repo.called)
+	}
+
+	if lock.taken != 1 || lock.held("city_barcelona_size_2") {
+		t.Errorf("Unexpected lease state, taken %d times, held %t", lock.taken, lock.held("city_barcelona_size_2"))
+	}
+}
+
+func TestRepositoryMiddlewareCallsRepositoryOnceWaitIsExceeded(t *testing.T) {
+	ch := newFakeSharedCache()
+	lock := &fakeLock{leases: map[string]time.Time{"city_barcelona_size_2": time.Now().Add(time.Hour)}}
+	repo := &fakeRepository{contributors: []*Contributor{{ID: 1}}}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{
+		FillLock: lock,
+		FillWait: time.Millisecond * 50,
+		FillPoll: time.Millisecond * 10,
+	})
+
+	if _, err := r.GetGithubTopContributors(context.Background(), GithubTopRequest{City: "barcelona", Size: 2}); err != nil {
+		t.Fatalf("Unexpected error getting contributors, err: %s", err.Error())
+	}
+
+	if repo.called != 1 {
+		t.Errorf("Unexpected repository calls, expected 1 got %d", repo.called)
+	}
+}
+
+func TestRepositoryMiddlewareRecordsRefreshedTopsOnHistory(t *testing.T) {
+	ch := newFakeSharedCache()
+	h := &fakeHistory{}
+	repo := &fakeRepository{contributors: []*Contributor{{ID: 1}, {ID: 2}}}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{History: h})
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.GetGithubTopContributors(context.Background(), GithubTopRequest{City: "barcelona", Size: 50}); err != nil {
+			t.Fatalf("Unexpected error getting contributors, err: %s", err.Error())
+		}
+	}
+
+	if len(h.recorded) != 1 {
+		t.Fatalf("Unexpected recorded snapshots, expected 1 got %d", len(h.recorded))
+	}
+
+	s := h.recorded[0]
+	if s.City != "barcelona" || s.Size != 50 || len(s.Contributors) != 2 || s.FetchedAt.IsZero() {
+		t.Errorf("Unexpected recorded snapshot, got %+v", s)
+	}
+}
+
+type fakeHistory struct {
+	recorded []*TopSnapshot
+}
+
+func (f *fakeHistory) Record(_ context.Context, s *TopSnapshot) error {
+	f.recorded = append(f.recorded, s)
+
+	return nil
+}
+
+func (f *fakeHistory) At(_ context.Context, _ string, _ int, _ time.Time) (*TopSnapshot, error) {
+	return nil, ErrTopSnapshotNotFound
+}
+
 type fakeRepository struct {
 	contributors []*Contributor
+	err          error
 	called       int
 }
 
 func (f *fakeRepository) GetGithubTopContributors(ctx context.Context, req GithubTopRequest) ([]*Contributor, error) {
 	f.called++
-	return f.contributors, nil
+	return f.contributors, f.err
 }
 
 type fakeCache struct {
 	contributors []*Contributor
+	negative     *NegativeEntry
+	ttl          time.Duration
 	called       int
 }
 
@@ -110,7 +305,19 @@ func (f *fakeCache) Add(_ context.Context, k string, v interface{}) error {
 	return nil
 }
 
+func (f *fakeCache) AddWithTTL(_ context.Context, k string, v interface{}, ttl time.Duration) error {
+	f.called++
+	f.ttl = ttl
+	if n, ok := v.(*NegativeEntry); ok {
+		f.negative = n
+	}
+	return nil
+}
+
 func (f *fakeCache) Get(_ context.Context, k string) (interface{}, error) {
+	if f.negative != nil {
+		return f.negative, nil
+	}
 	if len(f.contributors) == 0 {
 		return nil, ErrCacheMiss
 	}
@@ -118,3 +325,84 @@ func (f *fakeCache) Get(_ context.Context, k string) (interface{}, error) {
 }
 
 func (f *fakeCache) Terminate() {}
+
+type fakeCounter struct {
+	statuses map[CacheStatus]int
+}
+
+func (f *fakeCounter) With(labelValues ...string) metrics.Counter {
+	if f.statuses == nil {
+		f.statuses = make(map[CacheStatus]int)
+	}
+	f.statuses[CacheStatus(labelValues[1])]++
+	return f
+}
+
+func (f *fakeCounter) Add(delta float64) {}
+
+type fakeSharedCache struct {
+	entries map[string]interface{}
+	mutex   sync.Mutex
+}
+
+func newFakeSharedCache() *fakeSharedCache {
+	return &fakeSharedCache{entries: make(map[string]interface{})}
+}
+
+func (f *fakeSharedCache) Add(_ context.Context, k string, v interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.entries[k] = v
+
+	return nil
+}
+
+func (f *fakeSharedCache) AddWithTTL(ctx context.Context, k string, v interface{}, _ time.Duration) error {
+	return f.Add(ctx, k, v)
+}
+
+func (f *fakeSharedCache) Get(_ context.Context, k string) (interface{}, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	v, ok := f.entries[k]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	return v, nil
+}
+
+func (f *fakeSharedCache) Terminate() {}
+
+type fakeLock struct {
+	leases map[string]time.Time
+	taken  int
+	mutex  sync.Mutex
+}
+
+func (f *fakeLock) TryLock(_ context.Context, k string, ttl time.Duration) (string, bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if time.Now().Before(f.leases[k]) {
+		return "", false, nil
+	}
+	f.leases[k] = time.Now().Add(ttl)
+	f.taken++
+
+	return "token", true, nil
+}
+
+func (f *fakeLock) Unlock(_ context.Context, k, _ string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.leases, k)
+
+	return nil
+}
+
+func (f *fakeLock) held(k string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return time.Now().Before(f.leases[k])
+}
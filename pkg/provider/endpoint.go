@@ -13,6 +13,8 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/time/rate"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -57,7 +59,10 @@ func NewGithubClient(appName string, cfg HttpConfig) *GithubClient {
 	e := makeGithubClientEndpoint(client)
 	e = metrics.InstrumentingMiddleware(appName, "githubClient")(e)
 	e = log.LoggingMiddleware(kitlog.With(log.MiddlewareLogger, "method", "githubClient"))(e)
-	limit := rate.NewLimiter(rate.Every(cfg.RateLimitConfig.RateLimitWindow), cfg.RateLimitConfig.RateLimitMaxReq)
+	var limit ratelimit.Allower = rate.NewLimiter(rate.Every(cfg.RateLimitConfig.RateLimitWindow), cfg.RateLimitConfig.RateLimitMaxReq)
+	if cfg.Limiter != nil {
+		limit = cfg.Limiter
+	}
 	e = ratelimit.NewErroringLimiter(limit)(e)
 
 	return &GithubClient{
@@ -67,9 +72,22 @@ func NewGithubClient(appName string, cfg HttpConfig) *GithubClient {
 	}
 }
 
+// LocationQuery builds users search query on any of the locations, multi word locations are quoted
+func LocationQuery(city string, aliases ...string) string {
+	q := make([]string, 0, len(aliases)+1)
+	for _, c := range append([]string{city}, aliases...) {
+		if strings.ContainsAny(c, " ,") {
+			c = strconv.Quote(c)
+		}
+		q = append(q, fmt.Sprintf(Query, c))
+	}
+
+	return strings.Join(q, " OR ")
+}
+
 // DoRequest fires http request
 func (r *GithubClient) DoRequest(ctx context.Context, req GithubTopRequest, page, size int) ([]*Contributor, error) {
-	query := fmt.Sprintf(Query, req.City)
+	query := LocationQuery(req.City, req.Aliases...)
 	opt := &github.SearchOptions{
 		ListOptions: github.ListOptions{
 			Page:    page,
@@ -95,7 +113,7 @@ func (r *GithubClient) DoRequest(ctx context.Context, req GithubTopRequest, page
 		return nil, ErrMaxRetries
 	}
 
-	cs := make([]*Contributor, size)
+	cs := make([]*Contributor, len(response.Result.Users))
 	for k, u := range response.Result.Users {
 		c := &Contributor{ID: *u.ID, Name: *u.Login, Url: *u.URL}
 		if req.Version == APIv2 {
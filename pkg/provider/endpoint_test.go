@@ -163,3 +163,16 @@ func TestGithubClientOnFakeServerWithTimeout(t *testing.T) {
 		t.Error("Unexpected Context timeout error!")
 	}
 }
+
+func TestLocationQueryOnAliases(t *testing.T) {
+	q := LocationQuery("barcelona")
+	if q != "location:barcelona" {
+		t.Errorf("Unexpected single location query, got %s", q)
+	}
+
+	q = LocationQuery("barcelona", "bcn", "barcelona, spain")
+	expected := `location:barcelona OR location:bcn OR location:"barcelona, spain"`
+	if q != expected {
+		t.Errorf("Unexpected aliases query, expected %s got %s", expected, q)
+	}
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// SearchEvent models a served top contributors search, city is the normalized one, requested city is kept
+// so that history can be replayed under other normalization rules
+type SearchEvent struct {
+	Time          time.Time   `json:"time"`
+	RequestedCity string      `json:"requested_city"`
+	City          string      `json:"city"`
+	Size          int         `json:"size"`
+	Sort          string      `json:"sort"`
+	Version       string      `json:"version"`
+	Client        string      `json:"client"`
+	CacheStatus   CacheStatus `json:"cache_status,omitempty"`
+	LatencyMs     float64     `json:"latency_ms"`
+	Results       int         `json:"results"`
+	Error         string      `json:"error,omitempty"`
+}
+
+// SearchEventSink persists search events batches
+type SearchEventSink interface {
+	Write(ctx context.Context, events []*SearchEvent) error
+	Close() error
+}
+
+// SearchEventSource reads persisted search events in log order, on searches from "from" to "to",
+// unbounded on zero times, fn errors stop reading
+type SearchEventSource interface {
+	Read(ctx context.Context, from, to time.Time, fn func(e *SearchEvent) error) error
+}
+
+// InRange checks if event happened from "from" to "to", both included, zero times are unbounded
+func (e *SearchEvent) InRange(from, to time.Time) bool {
+	if !from.IsZero() && e.Time.Before(from) {
+		return false
+	}
+
+	return to.IsZero() || !e.Time.After(to)
+}
@@ -0,0 +1,146 @@
+package events
+
+import (
+	"context"
+	kitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBufferSize bounds pending events
+	DefaultBufferSize = 10000
+	// DefaultBatchSize defines max events by sink write
+	DefaultBatchSize = 500
+	// DefaultFlushInterval defines sink write frequency
+	DefaultFlushInterval = time.Second
+)
+
+// BufferedConfig defines buffer sizing, defaults on zero values
+type BufferedConfig struct {
+	Size          int
+	BatchSize     int
+	FlushInterval time.Duration
+	// Events counts events by status (written, dropped, failed), optional
+	Events kitmetrics.Counter
+}
+
+// Buffered emits events off the request path, pending events are written to sink in batches once
+// batch size is reached or on each flush interval. As back-pressure, events are dropped, and counted,
+// once buffer is full, so that slow sinks never block requests.
+type Buffered struct {
+	sink          provider.SearchEventSink
+	queue         chan *provider.SearchEvent
+	batchSize     int
+	flushInterval time.Duration
+	events        kitmetrics.Counter
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewBuffered instantiates buffered emitter over sink
+func NewBuffered(sink provider.SearchEventSink, cfg BufferedConfig) *Buffered {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultBufferSize
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+
+	return &Buffered{
+		sink:          sink,
+		queue:         make(chan *provider.SearchEvent, cfg.Size),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		events:        cfg.Events,
+		done:          make(chan struct{}),
+	}
+}
+
+// Emit enqueues event, dropped if buffer is full
+func (b *Buffered) Emit(e *provider.SearchEvent) {
+	select {
+	case b.queue <- e:
+	default:
+		b.track("dropped", 1)
+	}
+}
+
+// Run starts sink writer
+func (b *Buffered) Run() {
+	b.wg.Add(1)
+	go b.runner()
+}
+
+// Terminate stops sink writer, pending events are written and sink closed
+func (b *Buffered) Terminate() {
+	close(b.done)
+	b.wg.Wait()
+
+	if err := b.sink.Close(); err != nil {
+		log.Errorf("unexpected error closing search events sink, error %v", err)
+	}
+}
+
+func (b *Buffered) runner() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*provider.SearchEvent, 0, b.batchSize)
+	for {
+		select {
+		case e := <-b.queue:
+			batch = append(batch, e)
+			if len(batch) >= b.batchSize {
+				batch = b.flush(batch)
+			}
+		case <-ticker.C:
+			batch = b.flush(batch)
+		case <-b.done:
+			for {
+				select {
+				case e := <-b.queue:
+					batch = append(batch, e)
+					if len(batch) >= b.batchSize {
+						batch = b.flush(batch)
+					}
+				default:
+					b.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch to sink, returns emptied batch
+func (b *Buffered) flush(batch []*provider.SearchEvent) []*provider.SearchEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := b.sink.Write(context.Background(), batch); err != nil {
+		log.Errorf("unexpected error writing search events, error %v", err)
+		b.track("failed", len(batch))
+
+		return batch[:0]
+	}
+	b.track("written", len(batch))
+
+	return batch[:0]
+}
+
+func (b *Buffered) track(status string, n int) {
+	if b.events != nil {
+		b.events.With("status", status).Add(float64(n))
+	}
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-kit/kit/metrics"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBufferedWritesPendingEventsOnTerminate(t *testing.T) {
+	out := &bytes.Buffer{}
+	counter := &fakeCounter{}
+	b := NewBuffered(NewWriter(out), BufferedConfig{BatchSize: 2, FlushInterval: time.Hour, Events: counter})
+	b.Run()
+
+	for _, city := range []string{"barcelona", "madrid", "london"} {
+		b.Emit(&provider.SearchEvent{City: city, Size: 50})
+	}
+	b.Terminate()
+
+	var cities []string
+	s := bufio.NewScanner(out)
+	for s.Scan() {
+		e := &provider.SearchEvent{}
+		if err := json.Unmarshal(s.Bytes(), e); err != nil {
+			t.Fatalf("unexpected error decoding event, error %v", err)
+		}
+		cities = append(cities, e.City)
+	}
+
+	if len(cities) != 3 || cities[0] != "barcelona" || cities[2] != "london" {
+		t.Errorf("unexpected written events, got %v", cities)
+	}
+
+	if counter.total("written") != 3 {
+		t.Errorf("unexpected written events count, got %v", counter.total("written"))
+	}
+}
+
+func TestBufferedDropsEventsOnFullBuffer(t *testing.T) {
+	counter := &fakeCounter{}
+	sink := &fakeSink{err: errors.New("sink unavailable")}
+	b := NewBuffered(sink, BufferedConfig{Size: 2, Events: counter})
+
+	for i := 0; i < 5; i++ {
+		b.Emit(&provider.SearchEvent{City: "barcelona"})
+	}
+	b.Run()
+	b.Terminate()
+
+	if counter.total("dropped") != 3 || counter.total("failed") != 2 {
+		t.Errorf("unexpected events count, dropped %v failed %v", counter.total("dropped"), counter.total("failed"))
+	}
+
+	if !sink.closed {
+		t.Error("expected closed sink")
+	}
+}
+
+type fakeSink struct {
+	err    error
+	closed bool
+}
+
+func (f *fakeSink) Write(_ context.Context, _ []*provider.SearchEvent) error {
+	return f.err
+}
+
+func (f *fakeSink) Close() error {
+	f.closed = true
+	return nil
+}
+
+type fakeCounter struct {
+	status string
+	totals map[string]float64
+	mutex  sync.Mutex
+}
+
+func (f *fakeCounter) With(labelValues ...string) metrics.Counter {
+	return &fakeCounter{status: labelValues[1], totals: f.init()}
+}
+
+func (f *fakeCounter) Add(delta float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.totals[f.status] += delta
+}
+
+func (f *fakeCounter) init() map[string]float64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.totals == nil {
+		f.totals = make(map[string]float64)
+	}
+	return f.totals
+}
+
+func (f *fakeCounter) total(status string) float64 {
+	return f.init()[status]
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"os"
+	"sync"
+)
+
+// File sinks search events on an append only newline delimited json file
+type File struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+	mutex   sync.Mutex
+}
+
+// NewFile opens event log file, created if missing, events are appended to existing ones
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bufio.NewWriter(f)
+
+	return &File{
+		file:    f,
+		buffer:  b,
+		encoder: json.NewEncoder(b),
+	}, nil
+}
+
+// Write appends events batch, flushed at once
+func (f *File) Write(_ context.Context, events []*provider.SearchEvent) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, e := range events {
+		if err := f.encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return f.buffer.Flush()
+}
+
+// Close flushes pending events to disk and closes file
+func (f *File) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.buffer.Flush(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+
+	if err := f.file.Sync(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+
+	return f.file.Close()
+}
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileAppendsEventsAcrossReopens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "events.ndjson")
+
+	for _, city := range []string{"barcelona", "madrid"} {
+		f, err := NewFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error opening file, error %v", err)
+		}
+		if err := f.Write(context.Background(), []*provider.SearchEvent{{City: city}}); err != nil {
+			t.Fatalf("unexpected error writing events, error %v", err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("unexpected error closing file, error %v", err)
+		}
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading file, error %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"city":"barcelona"`) || !strings.Contains(lines[1], `"city":"madrid"`) {
+		t.Errorf("unexpected event log, got %s", raw)
+	}
+}
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io"
+	"os"
+	"time"
+)
+
+// maxEventLineSize bounds event log lines
+const maxEventLineSize = 1024 * 1024
+
+// FileSource reads search events from newline delimited json logs, as written by File sink
+type FileSource struct {
+	path string
+}
+
+// NewFileSource instantiates event log file source
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Read scans event log from start, empty lines are skipped
+func (f *FileSource) Read(ctx context.Context, from, to time.Time, fn func(e *provider.SearchEvent) error) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return readEvents(ctx, file, from, to, fn)
+}
+
+// readEvents decodes newline delimited json events on range
+func readEvents(ctx context.Context, r io.Reader, from, to time.Time, fn func(e *provider.SearchEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		e := &provider.SearchEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return fmt.Errorf("unexpected event on line %d, error %v", line, err)
+		}
+
+		if !e.InRange(from, to) {
+			continue
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSourceReadsWrittenEventsOnRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "events.ndjson")
+
+	at := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening file, error %v", err)
+	}
+	err = f.Write(context.Background(), []*provider.SearchEvent{
+		{Time: at.Add(-time.Minute), City: "madrid"},
+		{Time: at, City: "barcelona"},
+		{Time: at.Add(time.Minute), City: "london"},
+		{Time: at.Add(time.Hour), City: "paris"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error writing events, error %v", err)
+	}
+	_ = f.Close()
+
+	var cities []string
+	err = NewFileSource(path).Read(context.Background(), at, at.Add(time.Minute), func(e *provider.SearchEvent) error {
+		cities = append(cities, e.City)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error reading events, error %v", err)
+	}
+
+	if strings.Join(cities, ",") != "barcelona,london" {
+		t.Errorf("unexpected read events, got %v", cities)
+	}
+}
+
+func TestReadEventsFailsOnMalformedLines(t *testing.T) {
+	raw := "{\"city\":\"barcelona\"}\n\nnot an event\n"
+	err := readEvents(context.Background(), strings.NewReader(raw), time.Time{}, time.Time{}, func(e *provider.SearchEvent) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("unexpected error, got %v", err)
+	}
+}
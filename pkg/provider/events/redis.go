@@ -0,0 +1,144 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// StreamKey stores search events stream
+	StreamKey = "search-events"
+	// StreamField holds json encoded event on each stream entry
+	StreamField = "event"
+	// DefaultStreamMaxLen bounds stream length, oldest entries are trimmed
+	DefaultStreamMaxLen = 1000000
+	// streamPageSize defines entries read by round trip
+	streamPageSize = 1000
+)
+
+// RedisStream sinks search events on a capped redis stream
+type RedisStream struct {
+	client redis.UniversalClient
+	key    string
+	maxLen int64
+}
+
+// NewRedisStream instantiates redis stream sink with namespaced key, trimmed approximately to maxLen entries
+func NewRedisStream(cl redis.UniversalClient, prefix string, maxLen int64) *RedisStream {
+	if maxLen <= 0 {
+		maxLen = DefaultStreamMaxLen
+	}
+
+	return &RedisStream{
+		client: cl,
+		key:    prefix + StreamKey,
+		maxLen: maxLen,
+	}
+}
+
+// Write adds events batch on a single round trip
+func (r *RedisStream) Write(ctx context.Context, events []*provider.SearchEvent) error {
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, e := range events {
+			raw, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+
+			p.XAdd(ctx, &redis.XAddArgs{
+				Stream:       r.key,
+				MaxLenApprox: r.maxLen,
+				Values:       map[string]interface{}{StreamField: raw},
+			})
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Close does nothing, client is shared
+func (r *RedisStream) Close() error {
+	return nil
+}
+
+// RedisStreamSource reads search events from redis stream, as written by RedisStream sink
+type RedisStreamSource struct {
+	client redis.UniversalClient
+	key    string
+}
+
+// NewRedisStreamSource instantiates redis stream source with namespaced key
+func NewRedisStreamSource(cl redis.UniversalClient, prefix string) *RedisStreamSource {
+	return &RedisStreamSource{
+		client: cl,
+		key:    prefix + StreamKey,
+	}
+}
+
+// Read pages stream entries, entry ids are added after event time, so reading starts on "from" milliseconds,
+// events are filtered by its own time
+func (r *RedisStreamSource) Read(ctx context.Context, from, to time.Time, fn func(e *provider.SearchEvent) error) error {
+	start := "-"
+	if !from.IsZero() {
+		start = strconv.FormatInt(from.UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	for {
+		entries, err := r.client.XRangeN(ctx, r.key, start, "+", streamPageSize).Result()
+		if err != nil {
+			return err
+		}
+
+		for _, m := range entries {
+			raw, ok := m.Values[StreamField].(string)
+			if !ok {
+				return fmt.Errorf("unexpected stream entry %s without event", m.ID)
+			}
+
+			e := &provider.SearchEvent{}
+			if err := json.Unmarshal([]byte(raw), e); err != nil {
+				return fmt.Errorf("unexpected event on stream entry %s, error %v", m.ID, err)
+			}
+
+			if !e.InRange(from, to) {
+				continue
+			}
+
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+		if len(entries) < streamPageSize {
+			return nil
+		}
+
+		start, err = nextStreamID(entries[len(entries)-1].ID)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// nextStreamID returns the lowest entry id after id, so that pages do not overlap
+func nextStreamID(id string) (string, error) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("unexpected stream entry id %s", id)
+	}
+
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("unexpected stream entry id %s, error %v", id, err)
+	}
+
+	return parts[0] + "-" + strconv.FormatUint(seq+1, 10), nil
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRedisStreamAddsEvents(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewRedisStream(cl, "test-events:", 0)
+	defer func() {
+		if err := cl.Del(context.Background(), r.key).Err(); err != nil {
+			t.Fatalf("unexpected error removing stream, error %v", err)
+		}
+	}()
+
+	err := r.Write(context.Background(), []*provider.SearchEvent{{City: "barcelona", Results: 50}, {City: "madrid"}})
+	if err != nil {
+		t.Fatalf("unexpected error writing events, error %v", err)
+	}
+
+	entries, err := cl.XRange(context.Background(), r.key, "-", "+").Result()
+	if err != nil {
+		t.Fatalf("unexpected error reading stream, error %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("unexpected stream size, got %d", len(entries))
+	}
+
+	e := &provider.SearchEvent{}
+	if err := json.Unmarshal([]byte(entries[0].Values[StreamField].(string)), e); err != nil {
+		t.Fatalf("unexpected error decoding event, error %v", err)
+	}
+	if e.City != "barcelona" || e.Results != 50 {
+		t.Errorf("unexpected event, got %+v", e)
+	}
+}
+
+func TestRedisStreamSourcePagesEventsOnRange(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	w := NewRedisStream(cl, "test-events-source:", 0)
+	defer func() {
+		if err := cl.Del(context.Background(), w.key).Err(); err != nil {
+			t.Fatalf("unexpected error removing stream, error %v", err)
+		}
+	}()
+
+	from := time.Now().Add(-time.Minute)
+	batch := make([]*provider.SearchEvent, 0, streamPageSize+10)
+	for i := 0; i < streamPageSize+10; i++ {
+		batch = append(batch, &provider.SearchEvent{Time: time.Now(), City: strconv.Itoa(i)})
+	}
+	batch[0].Time = from.Add(-time.Minute)
+	if err := w.Write(context.Background(), batch); err != nil {
+		t.Fatalf("unexpected error writing events, error %v", err)
+	}
+
+	read := 0
+	err := NewRedisStreamSource(cl, "test-events-source:").Read(context.Background(), from, time.Time{}, func(e *provider.SearchEvent) error {
+		read++
+		if e.City != strconv.Itoa(read) {
+			t.Fatalf("unexpected event order, expected %d got %s", read, e.City)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error reading events, error %v", err)
+	}
+
+	if read != streamPageSize+9 {
+		t.Errorf("unexpected read events, expected %d got %d", streamPageSize+9, read)
+	}
+}
+
+func TestNextStreamID(t *testing.T) {
+	if id, err := nextStreamID("1756720800000-9"); err != nil || id != "1756720800000-10" {
+		t.Errorf("unexpected next id, got %s error %v", id, err)
+	}
+
+	if _, err := nextStreamID("foo"); err == nil {
+		t.Error("expected error on malformed id")
+	}
+}
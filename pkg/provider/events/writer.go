@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io"
+	"sync"
+)
+
+// Writer sinks search events as newline delimited json on a writer, as stdout
+type Writer struct {
+	encoder *json.Encoder
+	mutex   sync.Mutex
+}
+
+// NewWriter instantiates writer sink, writer is not closed
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Write encodes events, one by line
+func (w *Writer) Write(_ context.Context, events []*provider.SearchEvent) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, e := range events {
+		if err := w.encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close does nothing, writer is owned by caller
+func (w *Writer) Close() error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultHistoryRetention keeps top snapshots a bit more than a year
+const DefaultHistoryRetention = time.Hour * 24 * 400
+
+// ErrTopSnapshotNotFound happens on cities without snapshots fetched before requested time
+var ErrTopSnapshotNotFound = errors.New("top contributors snapshot not found")
+
+// TopSnapshot defines a city top contributors list as fetched from github
+type TopSnapshot struct {
+	City         string         `json:"city"`
+	Size         int            `json:"size"`
+	FetchedAt    time.Time      `json:"fetched_at"`
+	Contributors []*Contributor `json:"contributors"`
+}
+
+// TopHistory records city top contributors lists on each cache refresh, snapshots older than
+// retention are dropped
+type TopHistory interface {
+	// Record stores snapshot
+	Record(ctx context.Context, s *TopSnapshot) error
+
+	// At returns latest city snapshot fetched at or before t, ErrTopSnapshotNotFound if none
+	At(ctx context.Context, city string, size int, t time.Time) (*TopSnapshot, error)
+}
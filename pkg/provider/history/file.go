@@ -0,0 +1,144 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxSnapshotLine bounds snapshot line size
+const maxSnapshotLine = 16 * 1024 * 1024
+
+// File appends top snapshots to a file, one json snapshot per line, served from memory. Snapshots out of
+// retention are compacted on open.
+type File struct {
+	index *InMemory
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// NewFile loads history file, compacting it, and opens it for appending
+func NewFile(path string, retention time.Duration) (*File, error) {
+	index := NewInMemory(retention)
+	if err := load(path, index); err != nil {
+		return nil, err
+	}
+
+	if err := compact(path, index); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		index: index,
+		file:  f,
+	}, nil
+}
+
+// Record appends snapshot to file
+func (f *File) Record(ctx context.Context, s *provider.TopSnapshot) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	_, err = f.file.Write(append(raw, '\n'))
+	f.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return f.index.Record(ctx, s)
+}
+
+// At returns latest city snapshot fetched at or before t
+func (f *File) At(ctx context.Context, city string, size int, t time.Time) (*provider.TopSnapshot, error) {
+	return f.index.At(ctx, city, size, t)
+}
+
+// Close closes history file
+func (f *File) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.file.Close()
+}
+
+// load reads history file into index, a missing file is an empty history
+func load(path string, index *InMemory) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxSnapshotLine)
+	var line int
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		s := &provider.TopSnapshot{}
+		if err := json.Unmarshal(scanner.Bytes(), s); err != nil {
+			return fmt.Errorf("invalid top snapshot on line %d, error %w", line, err)
+		}
+		index.add(s)
+	}
+	index.expire()
+
+	return scanner.Err()
+}
+
+// compact rewrites history file with retained snapshots, written to a temporary file and renamed,
+// so that a failed compaction keeps the previous file
+func compact(path string, index *InMemory) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, s := range index.all() {
+		if err := enc.Encode(s); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
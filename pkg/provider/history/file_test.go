@@ -0,0 +1,92 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileHistorySurvivesRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "history.ndjson")
+	f, err := NewFile(path, time.Hour*24*365*10)
+	if err != nil {
+		t.Fatalf("unexpected error opening history, error %v", err)
+	}
+	testHistoryAt(t, f)
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error closing history, error %v", err)
+	}
+
+	restored, err := NewFile(path, time.Hour*24*365*10)
+	if err != nil {
+		t.Fatalf("unexpected error reopening history, error %v", err)
+	}
+	defer func() {
+		_ = restored.Close()
+	}()
+
+	s, err := restored.At(context.Background(), "barcelona", 50, day.Add(time.Hour))
+	if err != nil || s.Contributors[0].Name != "first" {
+		t.Errorf("unexpected restored snapshot, got %v error %v", s, err)
+	}
+}
+
+func TestFileHistoryCompactsSnapshotsOutOfRetentionOnOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "history.ndjson")
+	f, err := NewFile(path, time.Hour*24*365*10)
+	if err != nil {
+		t.Fatalf("unexpected error opening history, error %v", err)
+	}
+	now := time.Now()
+	_ = f.Record(context.Background(), snapshot("barcelona", now.Add(-time.Hour*48), "old"))
+	_ = f.Record(context.Background(), snapshot("barcelona", now, "new"))
+	_ = f.Close()
+
+	compacted, err := NewFile(path, time.Hour*24)
+	if err != nil {
+		t.Fatalf("unexpected error reopening history, error %v", err)
+	}
+	_ = compacted.Close()
+
+	if n := lines(t, path); n != 1 {
+		t.Errorf("unexpected compacted snapshots, expected 1 got %d", n)
+	}
+}
+
+func lines(t *testing.T, path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening file, error %v", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var n int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		n++
+	}
+
+	return n
+}
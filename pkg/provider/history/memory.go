@@ -0,0 +1,96 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InMemory keeps top snapshots by city and size sorted by fetch time
+type InMemory struct {
+	snapshots map[string][]*provider.TopSnapshot
+	retention time.Duration
+	now       func() time.Time
+	mutex     sync.RWMutex
+}
+
+// NewInMemory instantiates inMemory history, retention defaults to provider.DefaultHistoryRetention
+func NewInMemory(retention time.Duration) *InMemory {
+	if retention <= 0 {
+		retention = provider.DefaultHistoryRetention
+	}
+
+	return &InMemory{
+		snapshots: make(map[string][]*provider.TopSnapshot),
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Record stores snapshot, snapshots out of retention are dropped
+func (m *InMemory) Record(_ context.Context, s *provider.TopSnapshot) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.add(s)
+	m.expire()
+
+	return nil
+}
+
+// At returns latest city snapshot fetched at or before t
+func (m *InMemory) At(_ context.Context, city string, size int, t time.Time) (*provider.TopSnapshot, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	snapshots := m.snapshots[key(city, size)]
+	i := sort.Search(len(snapshots), func(i int) bool { return snapshots[i].FetchedAt.After(t) })
+	if i == 0 {
+		return nil, provider.ErrTopSnapshotNotFound
+	}
+
+	return snapshots[i-1], nil
+}
+
+// add inserts snapshot keeping fetch time order, as late records may come from restored files
+func (m *InMemory) add(s *provider.TopSnapshot) {
+	k := key(s.City, s.Size)
+	snapshots := m.snapshots[k]
+	i := sort.Search(len(snapshots), func(i int) bool { return snapshots[i].FetchedAt.After(s.FetchedAt) })
+	snapshots = append(snapshots, nil)
+	copy(snapshots[i+1:], snapshots[i:])
+	snapshots[i] = s
+	m.snapshots[k] = snapshots
+}
+
+func (m *InMemory) expire() {
+	oldest := m.now().Add(-m.retention)
+	for k, snapshots := range m.snapshots {
+		i := sort.Search(len(snapshots), func(i int) bool { return !snapshots[i].FetchedAt.Before(oldest) })
+		if i == len(snapshots) {
+			delete(m.snapshots, k)
+			continue
+		}
+		m.snapshots[k] = snapshots[i:]
+	}
+}
+
+// all returns retained snapshots
+func (m *InMemory) all() []*provider.TopSnapshot {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var res []*provider.TopSnapshot
+	for _, snapshots := range m.snapshots {
+		res = append(res, snapshots...)
+	}
+
+	return res
+}
+
+func key(city string, size int) string {
+	return fmt.Sprintf("%s:%d", city, size)
+}
@@ -0,0 +1,80 @@
+package history
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2026, time.September, 1, 12, 0, 0, 0, time.UTC)
+
+func TestInMemoryReturnsLatestSnapshotFetchedBeforeTime(t *testing.T) {
+	m := NewInMemory(time.Hour * 24 * 30)
+	m.now = func() time.Time { return day.Add(time.Hour * 48) }
+	testHistoryAt(t, m)
+}
+
+func TestInMemoryDropsSnapshotsOutOfRetention(t *testing.T) {
+	ctx := context.Background()
+	m := NewInMemory(time.Hour * 24)
+	m.now = func() time.Time { return day }
+
+	_ = m.Record(ctx, snapshot("barcelona", day.Add(-time.Hour*48), "old"))
+	_ = m.Record(ctx, snapshot("barcelona", day, "new"))
+
+	if _, err := m.At(ctx, "barcelona", 50, day.Add(-time.Hour*24)); err != provider.ErrTopSnapshotNotFound {
+		t.Errorf("Unexpected expired snapshot result, error %v", err)
+	}
+}
+
+// testHistoryAt records barcelona snapshots on day, day+24h and madrid one on day+12h, recorded out of order
+func testHistoryAt(t *testing.T, h provider.TopHistory) {
+	ctx := context.Background()
+	for _, s := range []*provider.TopSnapshot{
+		snapshot("barcelona", day.Add(time.Hour*24), "second"),
+		snapshot("barcelona", day, "first"),
+		snapshot("madrid", day.Add(time.Hour*12), "madrid"),
+	} {
+		if err := h.Record(ctx, s); err != nil {
+			t.Fatalf("Unexpected error recording snapshot, error %v", err)
+		}
+	}
+
+	tests := []struct {
+		at       time.Time
+		expected string
+	}{
+		{day, "first"},
+		{day.Add(time.Hour * 23), "first"},
+		{day.Add(time.Hour * 24), "second"},
+		{day.Add(time.Hour * 240), "second"},
+	}
+	for _, tt := range tests {
+		s, err := h.At(ctx, "barcelona", 50, tt.at)
+		if err != nil {
+			t.Fatalf("Unexpected error getting snapshot at %s, error %v", tt.at, err)
+		}
+
+		if s.Contributors[0].Name != tt.expected || s.City != "barcelona" {
+			t.Errorf("Unexpected snapshot at %s, expected %s got %s", tt.at, tt.expected, s.Contributors[0].Name)
+		}
+	}
+
+	if _, err := h.At(ctx, "barcelona", 50, day.Add(-time.Second)); err != provider.ErrTopSnapshotNotFound {
+		t.Errorf("Unexpected result before first snapshot, error %v", err)
+	}
+
+	if _, err := h.At(ctx, "barcelona", 100, day.Add(time.Hour*24)); err != provider.ErrTopSnapshotNotFound {
+		t.Errorf("Unexpected result on another size, error %v", err)
+	}
+}
+
+func snapshot(city string, at time.Time, name string) *provider.TopSnapshot {
+	return &provider.TopSnapshot{
+		City:         city,
+		Size:         50,
+		FetchedAt:    at,
+		Contributors: []*provider.Contributor{{ID: 1, Name: name}},
+	}
+}
@@ -0,0 +1,87 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"strconv"
+	"time"
+)
+
+// historyKey namespaces top snapshots sorted sets
+const historyKey = "history:"
+
+// Redis keeps top snapshots by city and size on a sorted set scored by fetch time in milliseconds,
+// snapshots out of retention are dropped on each record and idle sets expire
+type Redis struct {
+	client    redis.UniversalClient
+	prefix    string
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewRedis instantiates redis history, retention defaults to provider.DefaultHistoryRetention
+func NewRedis(cl redis.UniversalClient, prefix string, retention time.Duration) *Redis {
+	if retention <= 0 {
+		retention = provider.DefaultHistoryRetention
+	}
+
+	return &Redis{
+		client:    cl,
+		prefix:    prefix,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Record stores snapshot, snapshots out of retention are dropped
+func (r *Redis) Record(ctx context.Context, s *provider.TopSnapshot) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	k := r.key(s.City, s.Size)
+	oldest := millis(r.now().Add(-r.retention))
+	_, err = r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZAdd(ctx, k, &redis.Z{Score: float64(millis(s.FetchedAt)), Member: raw})
+		p.ZRemRangeByScore(ctx, k, "-inf", "("+strconv.FormatInt(oldest, 10))
+		p.Expire(ctx, k, r.retention)
+
+		return nil
+	})
+
+	return err
+}
+
+// At returns latest city snapshot fetched at or before t
+func (r *Redis) At(ctx context.Context, city string, size int, t time.Time) (*provider.TopSnapshot, error) {
+	res, err := r.client.ZRevRangeByScore(ctx, r.key(city, size), &redis.ZRangeBy{
+		Max:   strconv.FormatInt(millis(t), 10),
+		Min:   "-inf",
+		Count: 1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res) == 0 {
+		return nil, provider.ErrTopSnapshotNotFound
+	}
+
+	s := &provider.TopSnapshot{}
+	if err := json.Unmarshal([]byte(res[0]), s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *Redis) key(city string, size int) string {
+	return r.prefix + historyKey + key(city, size)
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
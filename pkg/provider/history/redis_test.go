@@ -0,0 +1,62 @@
+package history
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"testing"
+	"time"
+)
+
+const testPrefix = "test:top:"
+
+func TestRedisHistoryReturnsLatestSnapshotFetchedBeforeTime(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer cleanup(t, cl)
+
+	r := NewRedis(cl, testPrefix, time.Hour*24*30)
+	r.now = func() time.Time { return day.Add(time.Hour * 48) }
+	testHistoryAt(t, r)
+}
+
+func TestRedisHistoryDropsSnapshotsOutOfRetention(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer cleanup(t, cl)
+
+	ctx := context.Background()
+	r := NewRedis(cl, testPrefix, time.Hour*24)
+	r.now = func() time.Time { return day }
+
+	_ = r.Record(ctx, snapshot("barcelona", day.Add(-time.Hour*48), "old"))
+	_ = r.Record(ctx, snapshot("barcelona", day, "new"))
+
+	if _, err := r.At(ctx, "barcelona", 50, day.Add(-time.Hour*24)); err != provider.ErrTopSnapshotNotFound {
+		t.Errorf("Unexpected expired snapshot result, error %v", err)
+	}
+}
+
+func cleanup(t *testing.T, cl *redis.Client) {
+	ctx := context.Background()
+	keys, err := cl.Keys(ctx, testPrefix+"*").Result()
+	if err != nil {
+		t.Fatalf("unexpected error listing keys, error %v", err)
+	}
+
+	if len(keys) > 0 {
+		if err := cl.Del(ctx, keys...).Err(); err != nil {
+			t.Fatalf("unexpected error deleting keys, error %v", err)
+		}
+	}
+	_ = cl.Close()
+}
@@ -0,0 +1,128 @@
+package limiter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"golang.org/x/time/rate"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultKeyPrefix namespaces token buckets
+	DefaultKeyPrefix = "ratelimit:"
+	// DefaultTimeout bounds each bucket request, local limiter is used once exceeded
+	DefaultTimeout = time.Millisecond * 200
+	// anonymousBucket identifies requests without oauth token
+	anonymousBucket = "anonymous"
+)
+
+// tokenBucket refills bucket by elapsed time, one token each interval up to burst, and takes a token
+// if available. Callers clock is used, as scripts writing after reading server time are not replicable
+// on older redis versions; elapsed time never goes backwards so skewed replicas do not refill it twice.
+// Buckets expire once they would be full again.
+var tokenBucket = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) / interval)
+	ts = now
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(ts))
+redis.call("PEXPIRE", KEYS[1], math.ceil(interval * burst))
+
+return allowed
+`)
+
+// Config defines shared limiter, defaults on zero values
+type Config struct {
+	Prefix  string
+	Timeout time.Duration
+}
+
+// Redis implements a token bucket shared by all replicas using the same github token, so that
+// they share its budget. Bucket refills a token each rate limit window up to max requests, as
+// local limiter does. If redis is unavailable, replica falls back to its own local limiter.
+type Redis struct {
+	client   redis.UniversalClient
+	key      string
+	interval time.Duration
+	burst    int
+	timeout  time.Duration
+	local    *rate.Limiter
+	degraded int32
+}
+
+// NewRedis instantiates shared limiter for oauth token, bucket key is the token hash
+func NewRedis(cl redis.UniversalClient, token string, rl provider.RateLimitConfig, cfg Config) *Redis {
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultKeyPrefix
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	return &Redis{
+		client:   cl,
+		key:      cfg.Prefix + bucket(token),
+		interval: rl.RateLimitWindow,
+		burst:    rl.RateLimitMaxReq,
+		timeout:  cfg.Timeout,
+		local:    rate.NewLimiter(rate.Every(rl.RateLimitWindow), rl.RateLimitMaxReq),
+	}
+}
+
+// Allow takes a token from shared bucket, from local limiter if redis fails
+func (r *Redis) Allow() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	allowed, err := tokenBucket.Run(ctx, r.client, []string{r.key}, r.interval.Milliseconds(), r.burst, now).Int()
+	if err != nil {
+		if atomic.CompareAndSwapInt32(&r.degraded, 0, 1) {
+			log.Errorf("unexpected error on shared rate limiter, falling back to local one, error %v", err)
+		}
+
+		return r.local.Allow()
+	}
+
+	if atomic.CompareAndSwapInt32(&r.degraded, 1, 0) {
+		log.Info("shared rate limiter recovered")
+	}
+
+	return allowed == 1
+}
+
+// bucket identifies token bucket without storing token
+func bucket(token string) string {
+	if token == "" {
+		return anonymousBucket
+	}
+
+	h := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,105 @@
+package limiter
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"testing"
+	"time"
+)
+
+const testPrefix = "test:ratelimit:"
+
+func TestRedisLimiterSharesTokenBudgetAcrossReplicas(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer cleanup(t, cl)
+
+	rl := provider.NewRateLimitConfig(time.Hour, 3)
+	a := NewRedis(cl, "token", rl, Config{Prefix: testPrefix})
+	b := NewRedis(cl, "token", rl, Config{Prefix: testPrefix})
+
+	for i, l := range []*Redis{a, b, a} {
+		if !l.Allow() {
+			t.Fatalf("Unexpected request %d not allowed", i)
+		}
+	}
+
+	if a.Allow() || b.Allow() {
+		t.Error("Unexpected request allowed on exhausted shared budget")
+	}
+
+	other := NewRedis(cl, "other-token", rl, Config{Prefix: testPrefix})
+	if !other.Allow() {
+		t.Error("Unexpected request not allowed on another token budget")
+	}
+}
+
+func TestRedisLimiterRefillsTokensByWindow(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer cleanup(t, cl)
+
+	l := NewRedis(cl, "token", provider.NewRateLimitConfig(time.Millisecond*100, 1), Config{Prefix: testPrefix})
+	if !l.Allow() {
+		t.Fatal("Unexpected first request not allowed")
+	}
+	if l.Allow() {
+		t.Fatal("Unexpected request allowed on empty bucket")
+	}
+
+	time.Sleep(time.Millisecond * 150)
+	if !l.Allow() {
+		t.Error("Unexpected request not allowed once refilled")
+	}
+}
+
+func TestRedisLimiterFallsBackToLocalLimiterOnRedisFailures(t *testing.T) {
+	cl := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer func() {
+		_ = cl.Close()
+	}()
+
+	l := NewRedis(cl, "token", provider.NewRateLimitConfig(time.Hour, 2), Config{Prefix: testPrefix})
+	if !l.Allow() || !l.Allow() {
+		t.Fatal("Unexpected request not allowed by local limiter")
+	}
+
+	if l.Allow() {
+		t.Error("Unexpected request allowed on exhausted local limiter")
+	}
+}
+
+func TestBucketDoesNotExposeToken(t *testing.T) {
+	if b := bucket("secret-token"); b == "secret-token" || len(b) != 16 {
+		t.Errorf("Unexpected bucket, got %s", b)
+	}
+
+	if b := bucket(""); b != anonymousBucket {
+		t.Errorf("Unexpected anonymous bucket, got %s", b)
+	}
+}
+
+func cleanup(t *testing.T, cl *redis.Client) {
+	ctx := context.Background()
+	keys, err := cl.Keys(ctx, testPrefix+"*").Result()
+	if err != nil {
+		t.Fatalf("unexpected error listing keys, error %v", err)
+	}
+
+	if len(keys) > 0 {
+		if err := cl.Del(ctx, keys...).Err(); err != nil {
+			t.Fatalf("unexpected error deleting keys, error %v", err)
+		}
+	}
+	_ = cl.Close()
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PeriodKind defines calendar period granularity
+type PeriodKind string
+
+const (
+	// PeriodMonth identifies calendar months, as 2026-09
+	PeriodMonth PeriodKind = "month"
+	// PeriodWeek identifies ISO weeks, as 2026-W36
+	PeriodWeek PeriodKind = "week"
+)
+
+// PeriodKinds defines leaderboard periods tracked by rankings
+var PeriodKinds = []PeriodKind{PeriodMonth, PeriodWeek}
+
+var (
+	// ErrInvalidPeriod happens on malformed period names
+	ErrInvalidPeriod = errors.New("invalid ranking period, expected as 2026-09 or 2026-W36")
+	// ErrUnsupportedPeriod happens on rankings without period leaderboards
+	ErrUnsupportedPeriod = errors.New("unsupported ranking period")
+)
+
+const (
+	monthLayout = "2006-01"
+	weekFormat  = "%04d-W%02d"
+	weekScan    = "%d-W%d"
+)
+
+// Period identifies a calendar month or ISO week on UTC, names sort as periods do
+type Period string
+
+// PeriodOf returns period of kind containing t
+func PeriodOf(k PeriodKind, t time.Time) Period {
+	t = t.UTC()
+	if k == PeriodWeek {
+		y, w := t.ISOWeek()
+		return Period(fmt.Sprintf(weekFormat, y, w))
+	}
+
+	return Period(t.Format(monthLayout))
+}
+
+// ParsePeriod validates period name, as 2026-09 or 2026-W36
+func ParsePeriod(p string) (Period, error) {
+	if _, err := time.Parse(monthLayout, p); err == nil {
+		return Period(p), nil
+	}
+
+	var y, w int
+	if n, err := fmt.Sscanf(p, weekScan, &y, &w); err != nil || n != 2 || w < 1 || w > 53 {
+		return "", ErrInvalidPeriod
+	}
+
+	// canonical names only, week 53 does not exist on all years
+	if PeriodOf(PeriodWeek, weekStart(y, w)) != Period(p) {
+		return "", ErrInvalidPeriod
+	}
+
+	return Period(p), nil
+}
+
+// Kind returns period granularity
+func (p Period) Kind() PeriodKind {
+	if len(p) > 5 && p[5] == 'W' {
+		return PeriodWeek
+	}
+
+	return PeriodMonth
+}
+
+// Start returns period first instant
+func (p Period) Start() time.Time {
+	if p.Kind() == PeriodWeek {
+		var y, w int
+		_, _ = fmt.Sscanf(string(p), weekScan, &y, &w)
+		return weekStart(y, w)
+	}
+
+	t, _ := time.Parse(monthLayout, string(p))
+
+	return t
+}
+
+// Add returns period n periods after, before on negative n
+func (p Period) Add(n int) Period {
+	if p.Kind() == PeriodWeek {
+		return PeriodOf(PeriodWeek, p.Start().AddDate(0, 0, 7*n))
+	}
+
+	return PeriodOf(PeriodMonth, p.Start().AddDate(0, n, 0))
+}
+
+// weekStart returns ISO week monday, week 1 is the one containing january 4th
+func weekStart(year, week int) time.Time {
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+
+	return monday.AddDate(0, 0, 7*(week-1))
+}
+
+// PeriodRanking defines rankings archiving calendar period leaderboards, periods are frozen once ended
+type PeriodRanking interface {
+	// TopPeriod returns sorted period leaderboard page, empty on periods without searches
+	TopPeriod(ctx context.Context, p Period, offset, limit int) ([]*Location, error)
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPeriodOf(t *testing.T) {
+	at := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	if p := PeriodOf(PeriodMonth, at); p != "2026-09" {
+		t.Errorf("unexpected month period, got %s", p)
+	}
+
+	if p := PeriodOf(PeriodWeek, at); p != "2026-W36" {
+		t.Errorf("unexpected week period, got %s", p)
+	}
+
+	// iso week year differs from calendar one
+	if p := PeriodOf(PeriodWeek, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)); p != "2026-W53" {
+		t.Errorf("unexpected week period, got %s", p)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	for _, p := range []string{"2026-09", "2026-W36", "2026-W53"} {
+		if _, err := ParsePeriod(p); err != nil {
+			t.Errorf("unexpected error parsing %s, error %v", p, err)
+		}
+	}
+
+	for _, p := range []string{"", "2026-13", "2026-9", "2026-W7", "2025-W53", "2026-W36x", "september"} {
+		if _, err := ParsePeriod(p); err != ErrInvalidPeriod {
+			t.Errorf("unexpected error parsing %s, expected %v got %v", p, ErrInvalidPeriod, err)
+		}
+	}
+}
+
+func TestPeriodStartAndAdd(t *testing.T) {
+	if s := Period("2026-W36").Start(); !s.Equal(time.Date(2026, 8, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected week start, got %v", s)
+	}
+
+	if s := Period("2026-09").Start(); !s.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected month start, got %v", s)
+	}
+
+	for _, c := range []struct {
+		period   Period
+		n        int
+		expected Period
+	}{{"2026-09", 4, "2027-01"}, {"2026-09", -9, "2025-12"}, {"2026-W52", 2, "2027-W01"}, {"2026-W01", -1, "2025-W52"}} {
+		if p := c.period.Add(c.n); p != c.expected {
+			t.Errorf("unexpected %s plus %d, expected %s got %s", c.period, c.n, c.expected, p)
+		}
+	}
+}
+
+type fakeWindowRanking struct {
+	Ranking
+}
+
+func TestLocationRankingOnUnsupportedPeriod(t *testing.T) {
+	_, err := NewLocationRanking(&fakeWindowRanking{}).GetTopSearchedLocations(context.Background(), TopLocationsRequest{Period: "2026-09"})
+	if err != ErrUnsupportedPeriod {
+		t.Errorf("unexpected error, expected %v got %v", ErrUnsupportedPeriod, err)
+	}
+}
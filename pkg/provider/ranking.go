@@ -1,6 +1,115 @@
 package provider
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Window defines a sliding ranking time window
+type Window string
+
+const (
+	// WindowAll ranks all time searches
+	WindowAll Window = ""
+	// WindowHour ranks last hour searches
+	WindowHour Window = "hour"
+	// WindowDay ranks last day searches
+	WindowDay Window = "day"
+	// WindowWeek ranks last week searches
+	WindowWeek Window = "week"
+)
+
+// RankingMode defines how searches are counted
+type RankingMode string
+
+const (
+	// RankingRaw counts every search
+	RankingRaw RankingMode = "raw"
+	// RankingDistinct counts distinct searchers, up to a capped contribution each
+	RankingDistinct RankingMode = "distinct"
+)
+
+var (
+	// ErrInvalidRankingMode happens on unknown ranking modes
+	ErrInvalidRankingMode = errors.New("invalid ranking mode")
+	// ErrLocationNotRanked happens on rank lookups of non searched locations
+	ErrLocationNotRanked = errors.New("location not ranked")
+	// ErrInvalidWindow happens on unknown window names
+	ErrInvalidWindow = errors.New("invalid ranking window")
+	// ErrUnsupportedWindow happens on rankings without windowed scores
+	ErrUnsupportedWindow = errors.New("unsupported ranking window")
+	// ErrInvalidMerge happens on merges of a location into itself
+	ErrInvalidMerge = errors.New("invalid location merge")
+)
+
+// ParseRankingMode validates ranking mode, empty one means service default
+func ParseRankingMode(m string) (RankingMode, error) {
+	switch RankingMode(m) {
+	case "", RankingRaw, RankingDistinct:
+		return RankingMode(m), nil
+	}
+
+	return "", ErrInvalidRankingMode
+}
+
+// Windows defines available sliding windows
+var Windows = []Window{WindowHour, WindowDay, WindowWeek}
+
+// ParseWindow validates window name, empty one means all time
+func ParseWindow(w string) (Window, error) {
+	switch Window(w) {
+	case WindowAll, WindowHour, WindowDay, WindowWeek:
+		return Window(w), nil
+	}
+
+	return WindowAll, ErrInvalidWindow
+}
+
+// Span returns window length
+func (w Window) Span() time.Duration {
+	switch w {
+	case WindowHour:
+		return time.Hour
+	case WindowDay:
+		return time.Hour * 24
+	case WindowWeek:
+		return time.Hour * 24 * 7
+	}
+
+	return 0
+}
+
+// Resolution returns window bucket length, windows slide one bucket at a time
+func (w Window) Resolution() time.Duration {
+	switch w {
+	case WindowHour:
+		return time.Minute
+	case WindowDay:
+		return time.Hour
+	case WindowWeek:
+		return time.Hour * 6
+	}
+
+	return 0
+}
+
+// Bucket returns bucket id where t belongs
+func (w Window) Bucket(t time.Time) int64 {
+	return t.UnixNano() / int64(w.Resolution())
+}
+
+// Buckets returns window buckets ids at t, from oldest to current one
+func (w Window) Buckets(t time.Time) []int64 {
+	n := int64(w.Span() / w.Resolution())
+	last := w.Bucket(t)
+	res := make([]int64, 0, n)
+	for b := last - n + 1; b <= last; b++ {
+		res = append(res, b)
+	}
+
+	return res
+}
 
 // Location models searched locations
 type Location struct {
@@ -9,11 +118,40 @@ type Location struct {
 	Index int    `json:"index"`
 }
 
+// TopLocationsRequest defines searched locations ranking query, Size locations from Offset position,
+// on all time, sliding Window or calendar Period searches
+type TopLocationsRequest struct {
+	Offset int
+	Size   int
+	Window Window
+	Period Period
+	Mode   RankingMode
+}
+
 // Ranking defines a generic Ranking
 type Ranking interface {
 	IncreaseScore(ctx context.Context, city string) error
-	Top(ctx context.Context, size int) ([]*Location, error)
+	// Top returns sorted ranking page, up to limit locations from offset
+	Top(ctx context.Context, offset, limit int) ([]*Location, error)
+	// TopWindow returns sorted ranking page on searches inside sliding window
+	TopWindow(ctx context.Context, w Window, offset, limit int) ([]*Location, error)
 	Len(ctx context.Context) (int64, error)
+	// Score returns city score, zero on non ranked cities
+	Score(ctx context.Context, city string) (int, error)
+	// Rank returns city location, its index is the zero based rank, ErrLocationNotRanked on non ranked cities
+	Rank(ctx context.Context, city string) (*Location, error)
+	// Remove deletes city from ranking, windows included, ErrLocationNotRanked on non ranked cities
+	Remove(ctx context.Context, city string) error
+	// Reset deletes all ranked cities
+	Reset(ctx context.Context) error
+	// Merge adds from city scores to the ones of to city and removes it, ErrLocationNotRanked on non ranked from city
+	Merge(ctx context.Context, from, to string) error
+}
+
+// BatchRanking defines rankings increasing many city scores at once, searchers are not tracked
+type BatchRanking interface {
+	// IncreaseScores increases each city score by its count
+	IncreaseScores(ctx context.Context, counts map[string]int) error
 }
 
 // LocationRanking defines top searched locations generic ranking
@@ -33,7 +171,72 @@ func (d *LocationRanking) IncreaseCityScore(ctx context.Context, city string) er
 	return d.ranking.IncreaseScore(ctx, city)
 }
 
-// GetTopSearchedLocations returns ranking top with "size"
-func (d *LocationRanking) GetTopSearchedLocations(ctx context.Context, size int) ([]*Location, error) {
-	return d.ranking.Top(ctx, size)
+// IncreaseCityScores increases searched cities scores, aggregated on batch rankings,
+// one by one with its searcher otherwise
+func (d *LocationRanking) IncreaseCityScores(ctx context.Context, searches []Search) error {
+	if b, ok := d.ranking.(BatchRanking); ok {
+		counts := make(map[string]int)
+		for _, s := range searches {
+			counts[s.City]++
+		}
+
+		return b.IncreaseScores(ctx, counts)
+	}
+
+	var err error
+	for _, s := range searches {
+		if e := d.ranking.IncreaseScore(WithSearcher(ctx, s.Searcher), s.City); e != nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// GetTopSearchedLocations returns ranking page with "size" from "offset", on all time, sliding window
+// or calendar period searches
+func (d *LocationRanking) GetTopSearchedLocations(ctx context.Context, r TopLocationsRequest) ([]*Location, error) {
+	if r.Period != "" {
+		p, ok := d.ranking.(PeriodRanking)
+		if !ok {
+			return nil, ErrUnsupportedPeriod
+		}
+
+		return p.TopPeriod(ctx, r.Period, r.Offset, r.Size)
+	}
+
+	if r.Window == WindowAll {
+		return d.ranking.Top(ctx, r.Offset, r.Size)
+	}
+
+	return d.ranking.TopWindow(ctx, r.Window, r.Offset, r.Size)
+}
+
+// GetLocationRank returns city rank and score
+func (d *LocationRanking) GetLocationRank(ctx context.Context, city string) (*Location, error) {
+	return d.ranking.Rank(ctx, city)
+}
+
+// CityScore returns city score
+func (d *LocationRanking) CityScore(ctx context.Context, city string) (int, error) {
+	return d.ranking.Score(ctx, city)
+}
+
+// RemoveCity deletes city from ranking
+func (d *LocationRanking) RemoveCity(ctx context.Context, city string) error {
+	return d.ranking.Remove(ctx, city)
+}
+
+// Reset deletes all ranked cities
+func (d *LocationRanking) Reset(ctx context.Context) error {
+	return d.ranking.Reset(ctx)
+}
+
+// MergeCities moves from city scores to city, as on misspelled locations
+func (d *LocationRanking) MergeCities(ctx context.Context, from, to string) error {
+	if from == to {
+		return ErrInvalidMerge
+	}
+
+	return d.ranking.Merge(ctx, from, to)
 }
@@ -0,0 +1,69 @@
+package ranking
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sync"
+	"time"
+)
+
+const (
+	// ContributorsSortedSetKey stores viewed contributors scores, city scopes are suffixed
+	ContributorsSortedSetKey = "contributor-ranking"
+	// DefaultCityRankingSize bounds inMemory city scoped rankings
+	DefaultCityRankingSize = 1000
+	// DefaultRankedCities bounds inMemory city scoped rankings, least recently used cities are evicted
+	DefaultRankedCities = 1000
+	// DefaultCityRankingTTL expires idle redis city scoped rankings
+	DefaultCityRankingTTL = time.Hour * 24 * 30
+)
+
+// NewInMemoryFactory returns inMemory scoped rankings without windows nor periods, global one bounded by size,
+// scoped ones by scopeSize and up to maxScopes, least recently used scopes are dropped
+func NewInMemoryFactory(size, scopeSize, maxScopes int) provider.RankingFactory {
+	global := newInMemory(size, nil, nil)
+	// simplelru only fails on non positive sizes
+	scopes, _ := simplelru.NewLRU(maxScopes, nil)
+	var mutex sync.Mutex
+
+	return func(scope string, create bool) (provider.WeightedRanking, bool) {
+		if scope == "" {
+			return global, true
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if r, ok := scopes.Get(scope); ok {
+			return r.(*InMemory), true
+		}
+
+		if !create {
+			return nil, false
+		}
+
+		r := newInMemory(scopeSize, nil, nil)
+		scopes.Add(scope, r)
+
+		return r, true
+	}
+}
+
+// NewRedisFactory returns redis scoped rankings without windows nor periods on namespaced keys, sharing client.
+// Scoped rankings keep its scopeSize top members and expire after ttl without writes.
+func NewRedisFactory(cl redis.UniversalClient, prefix string, scopeSize int, ttl time.Duration) provider.RankingFactory {
+	return func(scope string, _ bool) (provider.WeightedRanking, bool) {
+		key := prefix + ContributorsSortedSetKey
+		if scope == "" {
+			return newRedis(cl, key, nil, nil), true
+		}
+
+		// reads on missing keys do not create them
+		r := newRedis(cl, key+":city:"+scope, nil, nil)
+		r.maxSize = int64(scopeSize)
+		r.ttl = ttl
+
+		return r, true
+	}
+}
@@ -0,0 +1,123 @@
+package ranking
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"testing"
+	"time"
+)
+
+func contributors(names ...string) []*provider.Contributor {
+	res := make([]*provider.Contributor, 0, len(names))
+	for i, n := range names {
+		res = append(res, &provider.Contributor{ID: int64(i), Name: n})
+	}
+
+	return res
+}
+
+func TestContributorRankingsScoreViewsByPositionAndCity(t *testing.T) {
+	factories := map[string]provider.RankingFactory{"inMemory": NewInMemoryFactory(100, 10, 2)}
+	if !testing.Short() {
+		cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+		factories["redis"] = NewRedisFactory(cl, "test-viewed:", 10, time.Hour)
+		defer func() {
+			keys, _ := cl.Keys(context.Background(), "*test-viewed:*").Result()
+			if len(keys) > 0 {
+				_ = cl.Del(context.Background(), keys...).Err()
+			}
+		}()
+	}
+
+	for name, f := range factories {
+		r := provider.NewContributorRanking(f)
+		_ = r.IncreaseViews(context.Background(), "barcelona", contributors("foo", "bar", "zoo"))
+		_ = r.IncreaseViews(context.Background(), "madrid", contributors("zoo", "foo"))
+
+		top, err := r.GetTopViewedContributors(context.Background(), provider.TopViewedContributorsRequest{Size: 10})
+		if err != nil {
+			t.Fatalf("unexpected error getting top on %s, error %v", name, err)
+		}
+		if len(top) != 3 || top[0].Name != "foo" || top[0].Score != 150 || top[1].Name != "zoo" || top[1].Score != 134 {
+			t.Errorf("unexpected global top on %s, got %v", name, top)
+		}
+
+		top, err = r.GetTopViewedContributors(context.Background(), provider.TopViewedContributorsRequest{City: "madrid", Offset: 1, Size: 10})
+		if err != nil {
+			t.Fatalf("unexpected error getting city top on %s, error %v", name, err)
+		}
+		if len(top) != 1 || top[0].Name != "foo" || top[0].Score != 50 || top[0].Index != 1 {
+			t.Errorf("unexpected city top on %s, got %v", name, top)
+		}
+
+		global, _ := f("", false)
+		if _, err := global.TopWindow(context.Background(), provider.WindowHour, 0, 10); err != provider.ErrUnsupportedWindow {
+			t.Errorf("unexpected error on %s window, expected %v got %v", name, provider.ErrUnsupportedWindow, err)
+		}
+	}
+}
+
+func TestInMemoryFactoryEvictsLeastRecentlyUsedCities(t *testing.T) {
+	f := NewInMemoryFactory(100, 10, 2)
+	for _, city := range []string{"barcelona", "madrid", "barcelona", "london"} {
+		r, _ := f(city, true)
+		_ = r.IncreaseScores(context.Background(), map[string]int{"foo": 1})
+	}
+
+	for city, expected := range map[string]bool{"barcelona": true, "london": true, "madrid": false} {
+		if _, ok := f(city, false); ok != expected {
+			t.Errorf("unexpected %s ranking found, expected %t got %t", city, expected, ok)
+		}
+	}
+}
+
+func TestContributorRankingReadsOnNonRankedCitiesKeepRankedOnes(t *testing.T) {
+	r := provider.NewContributorRanking(NewInMemoryFactory(100, 10, 2))
+	_ = r.IncreaseViews(context.Background(), "barcelona", contributors("foo"))
+
+	for _, city := range []string{"nowhere", "elsewhere"} {
+		top, err := r.GetTopViewedContributors(context.Background(), provider.TopViewedContributorsRequest{City: city, Size: 10})
+		if err != nil || len(top) != 0 {
+			t.Errorf("unexpected %s top, got %v error %v", city, top, err)
+		}
+	}
+
+	top, _ := r.GetTopViewedContributors(context.Background(), provider.TopViewedContributorsRequest{City: "barcelona", Size: 10})
+	if len(top) != 1 || top[0].Score != 100 {
+		t.Errorf("unexpected barcelona top, got %v", top)
+	}
+}
+
+func TestRedisFactoryBoundsCityRankings(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	defer func() {
+		keys, _ := cl.Keys(context.Background(), "*test-viewed-bound:*").Result()
+		if len(keys) > 0 {
+			_ = cl.Del(context.Background(), keys...).Err()
+		}
+	}()
+
+	f := NewRedisFactory(cl, "test-viewed-bound:", 2, time.Hour)
+	r, _ := f("barcelona", true)
+	_ = r.IncreaseScores(context.Background(), map[string]int{"foo": 3, "bar": 2, "zoo": 1})
+
+	top, err := r.Top(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+	if len(top) != 2 || top[0].Name != "foo" || top[1].Name != "bar" {
+		t.Errorf("unexpected trimmed top, got %v", top)
+	}
+
+	ttl, _ := cl.TTL(context.Background(), "test-viewed-bound:"+ContributorsSortedSetKey+":city:barcelona").Result()
+	if ttl <= 0 || ttl > time.Hour {
+		t.Errorf("unexpected city ranking ttl, got %s", ttl)
+	}
+}
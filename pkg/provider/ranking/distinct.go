@@ -0,0 +1,431 @@
+package ranking
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DistinctSortedSetKey stores distinct searchers estimations by city
+	DistinctSortedSetKey = "distinct-ranking"
+	// DefaultContributionCap defines how many searches from the same client are counted on a city
+	DefaultContributionCap = 1
+	// DefaultTrackedContributions bounds inMemory client contribution counters
+	DefaultTrackedContributions = 100000
+	// DefaultContributionTTL defines client contribution counters expiration on redis
+	DefaultContributionTTL = time.Hour * 24
+
+	// counterDeleteBatch bounds keys deleted on each call on reset
+	counterDeleteBatch = 500
+)
+
+// contributionMember returns the n-th client contribution member, members above cap are never added,
+// so each client adds up to cap to city estimation, and counters loss just re-adds existing members.
+func contributionMember(client string, n int) string {
+	return fmt.Sprintf("%s#%d", client, n)
+}
+
+type distinctCity struct {
+	hll   hll
+	count int
+}
+
+// DistinctInMemory implements a bounded inMemory ranking by distinct searchers, estimated with HyperLogLog
+type DistinctInMemory struct {
+	cities          map[string]*distinctCity
+	contributions   *simplelru.LRU
+	maxSize         int
+	contributionCap int
+	mutex           sync.RWMutex
+}
+
+// NewDistinctInMemory instantiates inMemory distinct ranking, each client adds up to cap searches by city
+func NewDistinctInMemory(size, contributionCap int) *DistinctInMemory {
+	if contributionCap <= 0 {
+		contributionCap = DefaultContributionCap
+	}
+
+	// simplelru only fails on non positive sizes
+	contributions, _ := simplelru.NewLRU(DefaultTrackedContributions, nil)
+
+	return &DistinctInMemory{
+		cities:          make(map[string]*distinctCity),
+		contributions:   contributions,
+		maxSize:         size,
+		contributionCap: contributionCap,
+	}
+}
+
+// IncreaseScore tracks context searcher on city, searches above client cap are ignored
+func (d *DistinctInMemory) IncreaseScore(ctx context.Context, city string) error {
+	client := provider.SearcherFromContext(ctx)
+	key := city + "\x00" + client
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	n := 1
+	if v, ok := d.contributions.Get(key); ok {
+		n = v.(int) + 1
+	}
+	d.contributions.Add(key, n)
+	if n > d.contributionCap {
+		return nil
+	}
+
+	c, ok := d.cities[city]
+	if !ok {
+		if len(d.cities) >= d.maxSize {
+			d.evict()
+		}
+		c = &distinctCity{}
+		d.cities[city] = c
+	}
+
+	if c.hll.add(contributionMember(client, n)) {
+		c.count = c.hll.count()
+	}
+
+	return nil
+}
+
+// Top returns locations with most distinct searchers, up to limit locations from offset
+func (d *DistinctInMemory) Top(_ context.Context, offset, limit int) ([]*provider.Location, error) {
+	d.mutex.RLock()
+	res := make([]*provider.Location, 0, len(d.cities))
+	for city, c := range d.cities {
+		res = append(res, &provider.Location{Name: city, Score: c.count})
+	}
+	d.mutex.RUnlock()
+
+	sortLocations(res)
+
+	return page(res, offset, limit), nil
+}
+
+// TopWindow is not supported on distinct rankings
+func (d *DistinctInMemory) TopWindow(_ context.Context, _ provider.Window, _, _ int) ([]*provider.Location, error) {
+	return nil, provider.ErrUnsupportedWindow
+}
+
+// Len returns ranking size
+func (d *DistinctInMemory) Len(_ context.Context) (int64, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return int64(len(d.cities)), nil
+}
+
+// Score returns city distinct searchers estimation
+func (d *DistinctInMemory) Score(_ context.Context, city string) (int, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	c, ok := d.cities[city]
+	if !ok {
+		return 0, nil
+	}
+
+	return c.count, nil
+}
+
+// Rank returns city location, rank is the number of locations ranked before it
+func (d *DistinctInMemory) Rank(_ context.Context, city string) (*provider.Location, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	c, ok := d.cities[city]
+	if !ok {
+		return nil, provider.ErrLocationNotRanked
+	}
+
+	var rank int
+	for k, v := range d.cities {
+		if ranksBefore(k, v.count, city, c.count) {
+			rank++
+		}
+	}
+
+	return &provider.Location{Name: city, Score: c.count, Index: rank}, nil
+}
+
+// Remove deletes city from ranking, its contribution counters are kept until evicted
+func (d *DistinctInMemory) Remove(_ context.Context, city string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.cities[city]; !ok {
+		return provider.ErrLocationNotRanked
+	}
+	delete(d.cities, city)
+
+	return nil
+}
+
+// Reset deletes all ranked cities and contribution counters
+func (d *DistinctInMemory) Reset(_ context.Context) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.cities = make(map[string]*distinctCity)
+	d.contributions.Purge()
+
+	return nil
+}
+
+// Merge adds from city searchers to city and removes it, searchers on both cities are counted once
+func (d *DistinctInMemory) Merge(_ context.Context, from, to string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	f, ok := d.cities[from]
+	if !ok {
+		return provider.ErrLocationNotRanked
+	}
+	delete(d.cities, from)
+
+	c, ok := d.cities[to]
+	if !ok {
+		c = &distinctCity{}
+		d.cities[to] = c
+	}
+	c.hll.merge(&f.hll)
+	c.count = c.hll.count()
+
+	return nil
+}
+
+func (d *DistinctInMemory) evict() {
+	var coldest string
+	lowest := math.MaxInt64
+	for city, c := range d.cities {
+		if c.count < lowest {
+			coldest, lowest = city, c.count
+		}
+	}
+	delete(d.cities, coldest)
+}
+
+// DistinctRedis implements a ranking by distinct searchers in top of redis HyperLogLogs,
+// estimations are mirrored on a sorted set to sort cities, all keys share cluster slot.
+type DistinctRedis struct {
+	client          redis.UniversalClient
+	key             string
+	contributionCap int
+	ttl             time.Duration
+}
+
+// NewDistinctRedis instantiates redis distinct ranking with namespaced keys, each client adds up to cap searches by city
+func NewDistinctRedis(cl redis.UniversalClient, prefix string, contributionCap int) *DistinctRedis {
+	if contributionCap <= 0 {
+		contributionCap = DefaultContributionCap
+	}
+
+	return &DistinctRedis{
+		client:          cl,
+		key:             "{" + prefix + DistinctSortedSetKey + "}",
+		contributionCap: contributionCap,
+		ttl:             DefaultContributionTTL,
+	}
+}
+
+// IncreaseScore tracks context searcher on city, searches above client cap are ignored
+func (r *DistinctRedis) IncreaseScore(ctx context.Context, city string) error {
+	client := provider.SearcherFromContext(ctx)
+	counterKey := fmt.Sprintf("%s:client:%s:%s", r.key, city, client)
+
+	var incr *redis.IntCmd
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		incr = p.Incr(ctx, counterKey)
+		p.Expire(ctx, counterKey, r.ttl)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	n := int(incr.Val())
+	if n > r.contributionCap {
+		return nil
+	}
+
+	hllKey := r.hllKey(city)
+	var added *redis.IntCmd
+	var count *redis.IntCmd
+	_, err = r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		added = p.PFAdd(ctx, hllKey, contributionMember(client, n))
+		count = p.PFCount(ctx, hllKey)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if added.Val() == 0 {
+		return nil
+	}
+
+	return r.client.ZAdd(ctx, r.key, &redis.Z{Score: float64(count.Val()), Member: city}).Err()
+}
+
+// Top returns locations with most distinct searchers, up to limit locations from offset
+func (r *DistinctRedis) Top(ctx context.Context, offset, limit int) ([]*provider.Location, error) {
+	if limit <= 0 {
+		return []*provider.Location{}, nil
+	}
+
+	res, err := r.client.ZRevRangeWithScores(ctx, r.key, int64(offset), rangeStop(offset, limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return locations(res, offset), nil
+}
+
+// TopWindow is not supported on distinct rankings
+func (r *DistinctRedis) TopWindow(_ context.Context, _ provider.Window, _, _ int) ([]*provider.Location, error) {
+	return nil, provider.ErrUnsupportedWindow
+}
+
+// Len returns ranking size
+func (r *DistinctRedis) Len(ctx context.Context) (int64, error) {
+	return r.client.ZCard(ctx, r.key).Result()
+}
+
+// Score returns city distinct searchers estimation
+func (r *DistinctRedis) Score(ctx context.Context, city string) (int, error) {
+	n, err := r.client.PFCount(ctx, r.hllKey(city)).Result()
+
+	return int(n), err
+}
+
+// Rank returns city location from its reverse rank
+func (r *DistinctRedis) Rank(ctx context.Context, city string) (*provider.Location, error) {
+	var rank *redis.IntCmd
+	var score *redis.FloatCmd
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		rank = p.ZRevRank(ctx, r.key, city)
+		score = p.ZScore(ctx, r.key, city)
+
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, provider.ErrLocationNotRanked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &provider.Location{Name: city, Score: int(score.Val()), Index: int(rank.Val())}, nil
+}
+
+// Remove deletes city estimation, its contribution counters just expire
+func (r *DistinctRedis) Remove(ctx context.Context, city string) error {
+	var removed *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		removed = p.ZRem(ctx, r.key, city)
+		p.Del(ctx, r.hllKey(city))
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if removed.Val() == 0 {
+		return provider.ErrLocationNotRanked
+	}
+
+	return nil
+}
+
+// Reset deletes ranked cities estimations and contribution counters, so replayed searches are counted again
+func (r *DistinctRedis) Reset(ctx context.Context) error {
+	cities, err := r.client.ZRange(ctx, r.key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{r.key}
+	for _, city := range cities {
+		keys = append(keys, r.hllKey(city))
+	}
+
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+
+	if cl, ok := r.client.(*redis.ClusterClient); ok {
+		return cl.ForEachMaster(ctx, func(ctx context.Context, m *redis.Client) error {
+			return r.deleteCounters(ctx, m)
+		})
+	}
+
+	return r.deleteCounters(ctx, r.client)
+}
+
+// deleteCounters scans and deletes client contribution counters
+func (r *DistinctRedis) deleteCounters(ctx context.Context, cl redis.Cmdable) error {
+	iter := cl.Scan(ctx, 0, r.key+":client:*", 0).Iterator()
+	keys := make([]string, 0, counterDeleteBatch)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) < counterDeleteBatch {
+			continue
+		}
+
+		if err := cl.Del(ctx, keys...).Err(); err != nil {
+			return err
+		}
+		keys = keys[:0]
+	}
+
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return cl.Del(ctx, keys...).Err()
+}
+
+// Merge adds from city searchers to city and removes it, searchers on both cities are counted once
+func (r *DistinctRedis) Merge(ctx context.Context, from, to string) error {
+	err := r.client.ZScore(ctx, r.key, from).Err()
+	if err == redis.Nil {
+		return provider.ErrLocationNotRanked
+	}
+	if err != nil {
+		return err
+	}
+
+	toKey := r.hllKey(to)
+	var count *redis.IntCmd
+	_, err = r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.PFMerge(ctx, toKey, toKey, r.hllKey(from))
+		count = p.PFCount(ctx, toKey)
+		p.Del(ctx, r.hllKey(from))
+		p.ZRem(ctx, r.key, from)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.client.ZAdd(ctx, r.key, &redis.Z{Score: float64(count.Val()), Member: to}).Err()
+}
+
+func (r *DistinctRedis) hllKey(city string) string {
+	return r.key + ":hll:" + city
+}
@@ -0,0 +1,219 @@
+package ranking
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"math"
+	"testing"
+)
+
+func TestHLLEstimatesDistinctValues(t *testing.T) {
+	for _, total := range []int{10, 1000, 50000} {
+		h := &hll{}
+		for i := 0; i < total; i++ {
+			h.add(fmt.Sprintf("client_%d", i))
+			h.add(fmt.Sprintf("client_%d", i))
+		}
+
+		e := h.count()
+		if math.Abs(float64(e-total))/float64(total) > 0.1 {
+			t.Errorf("unexpected estimation on %d values, got %d", total, e)
+		}
+	}
+}
+
+func TestDistinctInMemoryIgnoresRepeatedSearcher(t *testing.T) {
+	r := NewDistinctInMemory(10, 1)
+	spammer := provider.WithSearcher(context.Background(), "ip:10.0.0.1")
+	for i := 0; i < 1000; i++ {
+		_ = r.IncreaseScore(spammer, "spamville")
+	}
+
+	for i := 0; i < 5; i++ {
+		ctx := provider.WithSearcher(context.Background(), fmt.Sprintf("ip:10.0.1.%d", i))
+		_ = r.IncreaseScore(ctx, "barcelona")
+	}
+
+	top, err := r.Top(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+
+	if len(top) != 2 || top[0].Name != "barcelona" || top[0].Score != 5 {
+		t.Fatalf("unexpected distinct top, got %v", top)
+	}
+
+	s, _ := r.Score(context.Background(), "spamville")
+	if s != 1 {
+		t.Errorf("unexpected spammed city score, expected 1 got %d", s)
+	}
+}
+
+func TestDistinctInMemoryCapsClientContribution(t *testing.T) {
+	r := NewDistinctInMemory(10, 3)
+	ctx := provider.WithSearcher(context.Background(), "user:foo")
+	for i := 0; i < 10; i++ {
+		_ = r.IncreaseScore(ctx, "barcelona")
+	}
+
+	l, err := r.Rank(context.Background(), "barcelona")
+	if err != nil {
+		t.Fatalf("unexpected error getting rank, error %v", err)
+	}
+
+	if l.Score != 3 || l.Index != 0 {
+		t.Errorf("unexpected capped score, expected 3 got %d", l.Score)
+	}
+}
+
+func TestDistinctRedisIgnoresRepeatedSearcher(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewDistinctRedis(cl, "test-distinct:", 2)
+	defer func() {
+		keys, err := cl.Keys(context.Background(), "*test-distinct:*").Result()
+		if err != nil {
+			t.Fatalf("unexpected error listing keys, error %v", err)
+		}
+		if err := cl.Del(context.Background(), keys...).Err(); err != nil {
+			t.Fatalf("unexpected error removing keys, error %v", err)
+		}
+	}()
+
+	spammer := provider.WithSearcher(context.Background(), "ip:10.0.0.1")
+	for i := 0; i < 50; i++ {
+		if err := r.IncreaseScore(spammer, "spamville"); err != nil {
+			t.Fatalf("unexpected error increasing score, error %v", err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		ctx := provider.WithSearcher(context.Background(), fmt.Sprintf("ip:10.0.1.%d", i))
+		_ = r.IncreaseScore(ctx, "barcelona")
+	}
+
+	top, err := r.Top(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+
+	if len(top) != 2 || top[0].Name != "barcelona" || top[0].Score != 5 || top[1].Score != 2 {
+		t.Fatalf("unexpected distinct top, got %v", top)
+	}
+
+	l, err := r.Rank(context.Background(), "spamville")
+	if err != nil {
+		t.Fatalf("unexpected error getting rank, error %v", err)
+	}
+	if l.Index != 1 {
+		t.Errorf("unexpected spamville rank, got %d", l.Index)
+	}
+}
+
+func TestDistinctRedisResetCountsReplayedSearchersAgain(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewDistinctRedis(cl, "test-distinct-reset:", 1)
+	defer func() {
+		keys, _ := cl.Keys(context.Background(), "*test-distinct-reset:*").Result()
+		if len(keys) > 0 {
+			_ = cl.Del(context.Background(), keys...).Err()
+		}
+	}()
+
+	search := func() {
+		for i := 0; i < 5; i++ {
+			ctx := provider.WithSearcher(context.Background(), fmt.Sprintf("ip:10.0.3.%d", i))
+			if err := r.IncreaseScore(ctx, "barcelona"); err != nil {
+				t.Fatalf("unexpected error increasing score, error %v", err)
+			}
+		}
+	}
+
+	search()
+	if err := r.Reset(context.Background()); err != nil {
+		t.Fatalf("unexpected error resetting, error %v", err)
+	}
+
+	if keys, _ := cl.Keys(context.Background(), "*test-distinct-reset:*").Result(); len(keys) != 0 {
+		t.Errorf("unexpected keys after reset, got %v", keys)
+	}
+
+	search()
+	if s, _ := r.Score(context.Background(), "barcelona"); s != 5 {
+		t.Errorf("unexpected replayed score, expected 5 got %d", s)
+	}
+}
+
+func TestDistinctRankingsMergeCountsSearchersOnce(t *testing.T) {
+	rankings := map[string]provider.Ranking{"inMemory": NewDistinctInMemory(10, 1)}
+	if !testing.Short() {
+		cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+		rankings["redis"] = NewDistinctRedis(cl, "test-distinct-merge:", 1)
+		defer func() {
+			keys, _ := cl.Keys(context.Background(), "*test-distinct-merge:*").Result()
+			if len(keys) > 0 {
+				_ = cl.Del(context.Background(), keys...).Err()
+			}
+		}()
+	}
+
+	for name, r := range rankings {
+		// searchers 0 to 9 on barcelona, 5 to 14 on misspelled barcelone
+		for i := 0; i < 15; i++ {
+			ctx := provider.WithSearcher(context.Background(), fmt.Sprintf("ip:10.0.2.%d", i))
+			if i < 10 {
+				_ = r.IncreaseScore(ctx, "barcelona")
+			}
+			if i >= 5 {
+				_ = r.IncreaseScore(ctx, "barcelone")
+			}
+		}
+
+		if err := r.Merge(context.Background(), "barcelone", "barcelona"); err != nil {
+			t.Fatalf("unexpected error merging on %s, error %v", name, err)
+		}
+
+		top, err := r.Top(context.Background(), 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error getting top on %s, error %v", name, err)
+		}
+		if len(top) != 1 || top[0].Name != "barcelona" || top[0].Score != 15 {
+			t.Errorf("unexpected merged top on %s, got %v", name, top)
+		}
+
+		if err := r.Remove(context.Background(), "barcelona"); err != nil {
+			t.Fatalf("unexpected error removing on %s, error %v", name, err)
+		}
+		if s, _ := r.Score(context.Background(), "barcelona"); s != 0 {
+			t.Errorf("unexpected removed city score on %s, got %d", name, s)
+		}
+	}
+}
+
+func TestDistinctInMemoryBatchedSearchesKeepSearchers(t *testing.T) {
+	r := NewDistinctInMemory(10, 1)
+	searches := []provider.Search{
+		{City: "barcelona", Searcher: "ip:10.0.0.1"},
+		{City: "barcelona", Searcher: "ip:10.0.0.1"},
+		{City: "barcelona", Searcher: "ip:10.0.0.2"},
+	}
+	if err := provider.NewLocationRanking(r).IncreaseCityScores(context.Background(), searches); err != nil {
+		t.Fatalf("unexpected error increasing scores, error %v", err)
+	}
+
+	if s, _ := r.Score(context.Background(), "barcelona"); s != 2 {
+		t.Errorf("unexpected distinct searchers, expected 2 got %d", s)
+	}
+}
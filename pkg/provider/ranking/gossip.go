@@ -0,0 +1,192 @@
+package ranking
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// GossipPath defines replicated ranking state exchange endpoint
+	GossipPath = "/internal/ranking/gossip"
+	// PeerSecretHeader carries shared secret on peer requests
+	PeerSecretHeader = "X-Peer-Secret"
+	// DefaultGossipInterval defines state exchange frequency
+	DefaultGossipInterval = time.Second
+	// defaultGossipTimeout bounds each peer exchange
+	defaultGossipTimeout = time.Second * 5
+	// maxGossipState bounds peer state size
+	maxGossipState = 64 * 1024 * 1024
+)
+
+// GossipConfig defines peers to gossip with, defaults on zero values
+type GossipConfig struct {
+	// Peers base urls, as http://10.0.0.2:8000
+	Peers    []string
+	Interval time.Duration
+	// Secret is required on peer requests, peer exchanges are refused without it
+	Secret string
+	Client *http.Client
+}
+
+// Gossip exchanges replicated ranking state with peers over http, on each interval full state is pushed
+// to every peer, which merges it and replies with its own one, merged back, so that replicas converge
+// even if some peers are not configured both ways. Gossip serves peer exchanges too.
+type Gossip struct {
+	ranking  *Replicated
+	peers    []string
+	interval time.Duration
+	secret   string
+	client   *http.Client
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewGossip instantiates replicated ranking gossip
+func NewGossip(r *Replicated, cfg GossipConfig) *Gossip {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultGossipInterval
+	}
+
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultGossipTimeout}
+	}
+
+	peers := make([]string, 0, len(cfg.Peers))
+	for _, p := range cfg.Peers {
+		peers = append(peers, strings.TrimRight(p, "/"))
+	}
+
+	return &Gossip{
+		ranking:  r,
+		peers:    peers,
+		interval: cfg.Interval,
+		secret:   cfg.Secret,
+		client:   cfg.Client,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run starts gossip worker
+func (g *Gossip) Run() {
+	g.wg.Add(1)
+	go g.runner()
+}
+
+// Terminate stops gossip worker, state is pushed a last time
+func (g *Gossip) Terminate() {
+	close(g.done)
+	g.wg.Wait()
+	g.Exchange()
+}
+
+// Exchange pushes state to all peers merging its replies, failed peers are just logged. Once every peer
+// has merged it, state changes are acknowledged, so that its cities can be pruned.
+func (g *Gossip) Exchange() {
+	v := g.ranking.Version()
+	acked := true
+	for _, p := range g.peers {
+		if err := g.exchange(p); err != nil {
+			log.Errorf("unexpected error gossiping ranking with peer %s, error %v", p, err)
+			acked = false
+		}
+	}
+
+	if acked {
+		g.ranking.Acknowledge(v)
+	}
+}
+
+// ServeHTTP merges peer state and replies with merged one, merges can not be undone so peers are
+// always required to share a non empty secret
+func (g *Gossip) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if g.secret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(PeerSecretHeader)), []byte(g.secret)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	s := ReplicatedState{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGossipState)).Decode(&s); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	g.ranking.MergeState(s)
+
+	res, err := g.ranking.Publish()
+	if err != nil {
+		log.Errorf("unexpected error publishing ranking state, error %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (g *Gossip) runner() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.Exchange()
+		case <-g.done:
+			return
+		}
+	}
+}
+
+func (g *Gossip) exchange(peer string) error {
+	s, err := g.ranking.Publish()
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, peer+GossipPath, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.secret != "" {
+		req.Header.Set(PeerSecretHeader, g.secret)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected gossip response status %d", resp.StatusCode)
+	}
+
+	res := ReplicatedState{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	g.ranking.MergeState(res)
+
+	return nil
+}
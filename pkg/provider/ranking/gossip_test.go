@@ -0,0 +1,102 @@
+package ranking
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGossipConvergesReplicasOverHttp(t *testing.T) {
+	replicas := []*Replicated{NewReplicated("a", 10), NewReplicated("b", 10), NewReplicated("c", 10)}
+	servers := make([]*httptest.Server, 0, len(replicas))
+	mux := make([]*http.ServeMux, 0, len(replicas))
+	for range replicas {
+		m := http.NewServeMux()
+		mux = append(mux, m)
+		servers = append(servers, httptest.NewServer(m))
+	}
+	defer func() {
+		for _, s := range servers {
+			s.Close()
+		}
+	}()
+
+	// a ring, each replica gossips with the next one only
+	gossips := make([]*Gossip, 0, len(replicas))
+	for i, r := range replicas {
+		g := NewGossip(r, GossipConfig{Peers: []string{servers[(i+1)%len(servers)].URL}, Interval: time.Millisecond * 10, Secret: "secret"})
+		mux[i].Handle(GossipPath, g)
+		gossips = append(gossips, g)
+	}
+
+	for i, r := range replicas {
+		_ = r.IncreaseScores(context.Background(), map[string]int{"barcelona": i + 1, "madrid": 1})
+	}
+
+	for _, g := range gossips {
+		g.Run()
+	}
+
+	deadline := time.Now().Add(time.Second * 2)
+	converged := func() bool {
+		for _, r := range replicas {
+			if s, _ := r.Score(context.Background(), "barcelona"); s != 6 {
+				return false
+			}
+			if s, _ := r.Score(context.Background(), "madrid"); s != 3 {
+				return false
+			}
+		}
+		return true
+	}
+	for !converged() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	for _, g := range gossips {
+		g.Terminate()
+	}
+
+	if !converged() {
+		t.Errorf("expected replicas converged, got %v %v %v", replicas[0].State(), replicas[1].State(), replicas[2].State())
+	}
+}
+
+func TestGossipRejectsPeersWithoutSecret(t *testing.T) {
+	g := NewGossip(NewReplicated("a", 10), GossipConfig{Secret: "secret"})
+	svr := httptest.NewServer(g)
+	defer svr.Close()
+
+	resp, err := http.Post(svr.URL, "application/json", strings.NewReader(`{"barcelona":{"p":{"x":100}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error, error %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected status, expected %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	if s, _ := g.ranking.Score(context.Background(), "barcelona"); s != 0 {
+		t.Errorf("unexpected merged state, got score %d", s)
+	}
+}
+
+func TestGossipRejectsPeersWhenSecretIsNotDefined(t *testing.T) {
+	g := NewGossip(NewReplicated("a", 10), GossipConfig{})
+	svr := httptest.NewServer(g)
+	defer svr.Close()
+
+	resp, err := http.Post(svr.URL, "application/json", strings.NewReader(`{"barcelona":{"p":{"x":100}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error, error %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected status, expected %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
@@ -0,0 +1,76 @@
+package ranking
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// hllPrecision defines registers index bits, 1024 registers give ~3.25% standard error
+	hllPrecision = 10
+	hllRegisters = 1 << hllPrecision
+)
+
+// hll implements a HyperLogLog cardinality estimator
+type hll struct {
+	registers [hllRegisters]uint8
+}
+
+// add tracks value, returns true if estimation may have changed
+func (h *hll) add(v string) bool {
+	x := hash64(v)
+	idx := x >> (64 - hllPrecision)
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+	if rho <= h.registers[idx] {
+		return false
+	}
+	h.registers[idx] = rho
+
+	return true
+}
+
+// merge adds o tracked values, union keeps max register values
+func (h *hll) merge(o *hll) {
+	for i, r := range o.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+// count estimates distinct values, using linear counting on small cardinalities
+func (h *hll) count() int {
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+
+	return int(math.Round(e))
+}
+
+// hash64 spreads fnv hash bits with splitmix64 finalizer
+func hash64(v string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(v))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
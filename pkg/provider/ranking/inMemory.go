@@ -6,67 +6,118 @@ import (
 	"github.com/marcosQuesada/githubTop/pkg/log"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"sync"
+	"time"
 )
 
 // DefaultPriorityQueueSize max priority queue size
 const DefaultPriorityQueueSize = 10000
 
-// InMemory defines an inMemory ranking in top of a priority queue over the heap
+// InMemory defines an inMemory ranking in top of a min priority queue over the heap
 type InMemory struct {
 	priorityQueue PriorityQueue
 	maxSize       int
 	index         map[string]*provider.Location
+	windows       map[provider.Window]*windowCounter
+	periods       map[provider.PeriodKind]*periodCounter
+	now           func() time.Time
 	mutex         sync.RWMutex
 }
 
 // NewInMemory instantiates inMemory ranking
 func NewInMemory(size int) *InMemory {
+	return newInMemory(size, provider.Windows, provider.PeriodKinds)
+}
+
+// newInMemory instantiates inMemory ranking tracking just selected windows and periods
+func newInMemory(size int, tracked []provider.Window, kinds []provider.PeriodKind) *InMemory {
 	pq := make(PriorityQueue, 0)
 	heap.Init(&pq)
 
+	windows := make(map[provider.Window]*windowCounter)
+	for _, w := range tracked {
+		windows[w] = newWindowCounter(w, size)
+	}
+
+	periods := make(map[provider.PeriodKind]*periodCounter)
+	for _, k := range kinds {
+		periods[k] = newPeriodCounter(k, size)
+	}
+
 	return &InMemory{
 		priorityQueue: pq,
 		maxSize:       size,
 		index:         make(map[string]*provider.Location),
+		windows:       windows,
+		periods:       periods,
+		now:           time.Now,
 	}
 }
 
 // IncreaseScore city score  increase by 1
-func (i *InMemory) IncreaseScore(_ context.Context, city string) error {
+func (i *InMemory) IncreaseScore(ctx context.Context, city string) error {
 	log.Infof("Increasing score from %s", city)
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
 
-	v, ok := i.index[city]
-	if !ok {
-		item := &provider.Location{
-			Name:  city,
-			Score: 1,
-		}
-		heap.Push(&i.priorityQueue, item)
-		i.index[city] = item
-		if i.priorityQueue.Len() > i.maxSize {
-			_ = i.priorityQueue.Pop()
-		}
+	now := i.now()
+	i.increase(city, 1, provider.SearchTimeFromContext(ctx, now), now, provider.IsReplay(ctx))
 
-		return nil
-	}
+	return nil
+}
+
+// IncreaseScores increases each city score by its count under a single lock
+func (i *InMemory) IncreaseScores(ctx context.Context, counts map[string]int) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
 
-	prio := v.Score + 1
-	i.priorityQueue.update(v, v.Name, prio)
+	now := i.now()
+	at := provider.SearchTimeFromContext(ctx, now)
+	replay := provider.IsReplay(ctx)
+	for city, n := range counts {
+		i.increase(city, n, at, now, replay)
+	}
 
 	return nil
 }
 
-// Top returns priority queue from head up to "size" length
-func (i *InMemory) Top(_ context.Context, size int) ([]*provider.Location, error) {
+// Top returns a sorted copy of the ranking, up to limit locations from offset
+func (i *InMemory) Top(_ context.Context, offset, limit int) ([]*provider.Location, error) {
+	i.mutex.RLock()
+	res := make([]*provider.Location, 0, len(i.priorityQueue))
+	for _, v := range i.priorityQueue {
+		res = append(res, &provider.Location{Name: v.Name, Score: v.Score})
+	}
+	i.mutex.RUnlock()
+
+	sortLocations(res)
+
+	return page(res, offset, limit), nil
+}
+
+// TopWindow returns top searched locations inside sliding window
+func (i *InMemory) TopWindow(_ context.Context, w provider.Window, offset, limit int) ([]*provider.Location, error) {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
 
-	if i.priorityQueue.Len() < size {
-		size = i.priorityQueue.Len()
+	c, ok := i.windows[w]
+	if !ok {
+		return nil, provider.ErrUnsupportedWindow
 	}
-	return i.priorityQueue[:size], nil
+
+	return c.top(i.now(), offset, limit), nil
+}
+
+// TopPeriod returns calendar period leaderboard
+func (i *InMemory) TopPeriod(_ context.Context, p provider.Period, offset, limit int) ([]*provider.Location, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	c, ok := i.periods[p.Kind()]
+	if !ok {
+		return nil, provider.ErrUnsupportedPeriod
+	}
+
+	return c.top(p, offset, limit), nil
 }
 
 // Len returns ranking size
@@ -79,15 +130,165 @@ func (i *InMemory) Len(_ context.Context) (int64, error) {
 	return int64(s), nil
 }
 
-// A PriorityQueue implements heap.Interface and holds Locations.
+// Score returns city score
+func (i *InMemory) Score(_ context.Context, city string) (int, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	v, ok := i.index[city]
+	if !ok {
+		return 0, nil
+	}
+
+	return v.Score, nil
+}
+
+// Rank returns city location, rank is the number of locations ranked before it
+func (i *InMemory) Rank(_ context.Context, city string) (*provider.Location, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	v, ok := i.index[city]
+	if !ok {
+		return nil, provider.ErrLocationNotRanked
+	}
+
+	var rank int
+	for _, l := range i.priorityQueue {
+		if ranksBefore(l.Name, l.Score, v.Name, v.Score) {
+			rank++
+		}
+	}
+
+	return &provider.Location{Name: v.Name, Score: v.Score, Index: rank}, nil
+}
+
+// Remove deletes city from ranking, windows and current periods
+func (i *InMemory) Remove(_ context.Context, city string) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	v, ok := i.index[city]
+	if !ok {
+		return provider.ErrLocationNotRanked
+	}
+
+	heap.Remove(&i.priorityQueue, v.Index)
+	delete(i.index, city)
+	for _, w := range i.windows {
+		w.remove(city)
+	}
+	now := i.now()
+	for _, c := range i.periods {
+		c.remove(city, now)
+	}
+
+	return nil
+}
+
+// Reset deletes all ranked cities, windows and period leaderboards
+func (i *InMemory) Reset(_ context.Context) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.priorityQueue = make(PriorityQueue, 0)
+	i.index = make(map[string]*provider.Location)
+	for _, w := range i.windows {
+		w.reset()
+	}
+	for _, c := range i.periods {
+		c.reset()
+	}
+
+	return nil
+}
+
+// Merge adds from city score to city, windows and current periods included, and removes from city
+func (i *InMemory) Merge(_ context.Context, from, to string) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	f, ok := i.index[from]
+	if !ok {
+		return provider.ErrLocationNotRanked
+	}
+
+	for _, w := range i.windows {
+		w.merge(from, to)
+	}
+	now := i.now()
+	for _, c := range i.periods {
+		c.merge(from, to, now)
+	}
+
+	heap.Remove(&i.priorityQueue, f.Index)
+	delete(i.index, from)
+
+	if v, ok := i.index[to]; ok {
+		i.priorityQueue.update(v, v.Name, v.Score+f.Score)
+
+		return nil
+	}
+
+	item := &provider.Location{
+		Name:  to,
+		Score: f.Score,
+	}
+	heap.Push(&i.priorityQueue, item)
+	i.index[to] = item
+
+	return nil
+}
+
+// increase adds n to city score, windows and periods at search time, lowest score location is evicted on new
+// cities when full. Ended periods are only written on replays.
+func (i *InMemory) increase(city string, n int, at, now time.Time, replay bool) {
+	for _, w := range i.windows {
+		w.increase(city, n, at)
+	}
+	for _, c := range i.periods {
+		c.increase(city, n, at, now, replay)
+	}
+
+	v, ok := i.index[city]
+	if !ok {
+		if i.priorityQueue.Len() >= i.maxSize {
+			i.evict()
+		}
+
+		item := &provider.Location{
+			Name:  city,
+			Score: n,
+		}
+		heap.Push(&i.priorityQueue, item)
+		i.index[city] = item
+
+		return
+	}
+
+	i.priorityQueue.update(v, v.Name, v.Score+n)
+}
+
+// evict removes lowest score location, the min heap root
+func (i *InMemory) evict() {
+	if i.priorityQueue.Len() == 0 {
+		return
+	}
+
+	item := heap.Pop(&i.priorityQueue).(*provider.Location)
+	delete(i.index, item.Name)
+}
+
+// A PriorityQueue implements heap.Interface and holds Locations, lowest ranked location on its root,
+// so that evictions are O(log n). Tops are sorted on copies.
 type PriorityQueue []*provider.Location
 
 // Len returns priority queue size
 func (pq PriorityQueue) Len() int { return len(pq) }
 
-// Less comparative method between locations
+// Less comparative method between locations, i is less if it ranks after j
 func (pq PriorityQueue) Less(i, j int) bool {
-	return pq[i].Score > pq[j].Score
+	return ranksBefore(pq[j].Name, pq[j].Score, pq[i].Name, pq[i].Score)
 }
 
 // Swap exchanges 2 locations
@@ -2,7 +2,10 @@ package ranking
 
 import (
 	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestInMemoryRankingPopulatesRequestedLocations(t *testing.T) {
@@ -37,7 +40,7 @@ func TestInMemoryRankingTopLocations(t *testing.T) {
 	}
 
 	topSize := 5
-	res, err := r.Top(context.Background(), topSize)
+	res, err := r.Top(context.Background(), 0, topSize)
 	if err != nil {
 		t.Fatalf("unexpected error getting top, error %v", err)
 	}
@@ -56,3 +59,369 @@ func TestInMemoryRankingTopLocations(t *testing.T) {
 		t.Errorf("unexpected top score, expected %d got %d", expected, res[0].Score)
 	}
 }
+
+func TestInMemoryRankingScore(t *testing.T) {
+	r := NewInMemory(10)
+	for _, d := range dataProvider {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	for _, d := range dataProvider {
+		s, err := r.Score(context.Background(), d.city)
+		if err != nil {
+			t.Fatalf("unexpected error getting score, error %v", err)
+		}
+
+		if s != d.score {
+			t.Errorf("unexpected %s score, expected %d got %d", d.city, d.score, s)
+		}
+	}
+
+	s, _ := r.Score(context.Background(), "unknown")
+	if s != 0 {
+		t.Errorf("unexpected unknown city score, got %d", s)
+	}
+}
+
+func TestInMemoryRankingTopWindowSlidesOverTime(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewInMemory(10)
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+
+	now = now.Add(time.Hour * 2)
+	for i := 0; i < 3; i++ {
+		_ = r.IncreaseScore(context.Background(), "madrid")
+	}
+
+	res, err := r.TopWindow(context.Background(), provider.WindowHour, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting window top, error %v", err)
+	}
+	if len(res) != 1 || res[0].Name != "madrid" || res[0].Score != 3 {
+		t.Fatalf("unexpected hour window top, got %v", res)
+	}
+
+	res, err = r.TopWindow(context.Background(), provider.WindowDay, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting window top, error %v", err)
+	}
+	if len(res) != 2 || res[0].Name != "barcelona" || res[1].Name != "madrid" {
+		t.Fatalf("unexpected day window top, got %v", res)
+	}
+
+	now = now.Add(time.Hour * 24 * 8)
+	_ = r.IncreaseScore(context.Background(), "london")
+
+	res, _ = r.TopWindow(context.Background(), provider.WindowWeek, 0, 5)
+	if len(res) != 1 || res[0].Name != "london" {
+		t.Fatalf("unexpected week window top, got %v", res)
+	}
+
+	if l := len(r.windows[provider.WindowWeek].buckets); l != 1 {
+		t.Errorf("expected old buckets expired, got %d buckets", l)
+	}
+
+	s, _ := r.Score(context.Background(), "barcelona")
+	if s != 10 {
+		t.Errorf("unexpected all time score, expected 10 got %d", s)
+	}
+}
+
+func TestInMemoryRankingBoundsWindowBucketsAndPeriods(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewInMemory(2)
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+	for i := 0; i < 100; i++ {
+		_ = r.IncreaseScore(context.Background(), "junk-"+strconv.Itoa(i))
+	}
+
+	for w, c := range r.windows {
+		for _, b := range c.buckets {
+			if len(b) > 4 {
+				t.Errorf("unexpected %s bucket size %d", w, len(b))
+			}
+		}
+	}
+
+	for k, c := range r.periods {
+		for _, p := range c.periods {
+			if len(p) > 4 {
+				t.Errorf("unexpected %s period size %d", k, len(p))
+			}
+		}
+	}
+
+	res, _ := r.TopWindow(context.Background(), provider.WindowHour, 0, 1)
+	if len(res) != 1 || res[0].Name != "barcelona" || res[0].Score != 5 {
+		t.Errorf("unexpected hour window top, got %v", res)
+	}
+}
+
+func TestInMemoryRankingWindowsRankSearchesAtContextTime(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewInMemory(10)
+	r.now = func() time.Time { return now }
+
+	_ = r.IncreaseScore(provider.WithSearchTime(context.Background(), now.Add(-time.Hour*2)), "barcelona")
+	_ = r.IncreaseScore(context.Background(), "madrid")
+
+	res, _ := r.TopWindow(context.Background(), provider.WindowHour, 0, 5)
+	if len(res) != 1 || res[0].Name != "madrid" {
+		t.Fatalf("unexpected hour window top, got %v", res)
+	}
+
+	res, _ = r.TopWindow(context.Background(), provider.WindowDay, 0, 5)
+	if len(res) != 2 {
+		t.Fatalf("unexpected day window top, got %v", res)
+	}
+}
+
+func TestInMemoryRankingArchivesPeriodLeaderboards(t *testing.T) {
+	now := time.Date(2026, 9, 30, 10, 0, 0, 0, time.UTC)
+	r := NewInMemory(10)
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+	_ = r.IncreaseScore(context.Background(), "madrid")
+
+	now = now.Add(time.Hour * 24)
+	for i := 0; i < 3; i++ {
+		_ = r.IncreaseScore(context.Background(), "madrid")
+	}
+
+	// ended periods are frozen, removal applies to current ones
+	_ = r.Remove(context.Background(), "barcelona")
+
+	res, _ := r.TopPeriod(context.Background(), "2026-09", 0, 5)
+	if len(res) != 2 || res[0].Name != "barcelona" || res[0].Score != 5 || res[1].Name != "madrid" || res[1].Score != 1 {
+		t.Fatalf("unexpected september leaderboard, got %v", res)
+	}
+
+	res, _ = r.TopPeriod(context.Background(), "2026-10", 0, 5)
+	if len(res) != 1 || res[0].Name != "madrid" || res[0].Score != 3 {
+		t.Fatalf("unexpected october leaderboard, got %v", res)
+	}
+
+	res, _ = r.TopPeriod(context.Background(), "2026-W40", 0, 5)
+	if len(res) != 1 || res[0].Name != "madrid" || res[0].Score != 4 {
+		t.Fatalf("unexpected week leaderboard, got %v", res)
+	}
+
+	now = provider.Period("2026-10").Add(DefaultArchivedPeriods + 1).Start()
+	_ = r.IncreaseScore(context.Background(), "london")
+	if res, _ := r.TopPeriod(context.Background(), "2026-09", 0, 5); len(res) != 0 {
+		t.Errorf("expected leaderboard out of retention dropped, got %v", res)
+	}
+
+	_ = r.Reset(context.Background())
+	if res, _ := r.TopPeriod(context.Background(), "2026-10", 0, 5); len(res) != 0 {
+		t.Errorf("unexpected leaderboard after reset, got %v", res)
+	}
+}
+
+func TestInMemoryRankingFreezesEndedPeriodsButOnReplays(t *testing.T) {
+	now := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	r := NewInMemory(10)
+	r.now = func() time.Time { return now }
+
+	late := provider.WithSearchTime(context.Background(), now.Add(-time.Hour*24))
+	_ = r.IncreaseScore(late, "barcelona")
+	_ = r.IncreaseScores(provider.WithReplay(late), map[string]int{"madrid": 2})
+
+	res, _ := r.TopPeriod(context.Background(), "2026-09", 0, 5)
+	if len(res) != 1 || res[0].Name != "madrid" || res[0].Score != 2 {
+		t.Errorf("unexpected september leaderboard, got %v", res)
+	}
+
+	if s, _ := r.Score(context.Background(), "barcelona"); s != 1 {
+		t.Errorf("expected late search counted on all time ranking, got %d", s)
+	}
+}
+
+func TestInMemoryRankingTopWindowOnUnsupportedWindow(t *testing.T) {
+	r := NewInMemory(10)
+	if _, err := r.TopWindow(context.Background(), provider.Window("year"), 0, 5); err != provider.ErrUnsupportedWindow {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrUnsupportedWindow, err)
+	}
+}
+
+func TestInMemoryRankingTopIsSortedAndPaginated(t *testing.T) {
+	r := NewInMemory(10)
+	for _, d := range dataProvider {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	res, err := r.Top(context.Background(), 0, len(dataProvider))
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+
+	expected := []string{"barcelona", "london", "zoo", "foo", "bar", "madrid"}
+	for i, name := range expected {
+		if res[i].Name != name || res[i].Index != i {
+			t.Errorf("unexpected location at %d, expected %s got %s with index %d", i, name, res[i].Name, res[i].Index)
+		}
+	}
+
+	res, _ = r.Top(context.Background(), 2, 3)
+	if len(res) != 3 || res[0].Name != "zoo" || res[0].Index != 2 || res[2].Name != "bar" {
+		t.Fatalf("unexpected page, got %v", res)
+	}
+
+	res, _ = r.Top(context.Background(), 10, 3)
+	if len(res) != 0 {
+		t.Errorf("unexpected page out of range size, got %d", len(res))
+	}
+
+	res, _ = r.Top(context.Background(), 1, maxInt)
+	if len(res) != len(dataProvider)-1 || res[0].Name != "london" {
+		t.Errorf("unexpected page on overflowing limit, got %v", res)
+	}
+}
+
+func TestInMemoryRankingTopReturnsCopies(t *testing.T) {
+	r := NewInMemory(10)
+	_ = r.IncreaseScore(context.Background(), "barcelona")
+
+	res, _ := r.Top(context.Background(), 0, 1)
+	_ = r.IncreaseScore(context.Background(), "barcelona")
+
+	if res[0].Score != 1 {
+		t.Errorf("unexpected shared location mutation, got score %d", res[0].Score)
+	}
+}
+
+func TestInMemoryRankingRank(t *testing.T) {
+	r := NewInMemory(10)
+	for _, d := range dataProvider {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	l, err := r.Rank(context.Background(), "foo")
+	if err != nil {
+		t.Fatalf("unexpected error getting rank, error %v", err)
+	}
+
+	if l.Index != 3 || l.Score != 4 {
+		t.Errorf("unexpected foo rank, expected 3 with score 4 got %d with score %d", l.Index, l.Score)
+	}
+
+	if _, err := r.Rank(context.Background(), "unknown"); err != provider.ErrLocationNotRanked {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+	}
+}
+
+func TestInMemoryRankingEvictsLowestScoreWhenFull(t *testing.T) {
+	r := NewInMemory(3)
+	for _, d := range dataProvider[:3] {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	_ = r.IncreaseScore(context.Background(), "newcity")
+
+	for _, city := range []string{"barcelona", "london"} {
+		if s, _ := r.Score(context.Background(), city); s == 0 {
+			t.Errorf("unexpected popular city %s evicted", city)
+		}
+	}
+
+	if _, err := r.Rank(context.Background(), "madrid"); err != provider.ErrLocationNotRanked {
+		t.Errorf("expected lowest score city evicted from index, got %v", err)
+	}
+}
+
+func TestInMemoryRankingKeepsHighestScoresOnEvictions(t *testing.T) {
+	r := NewInMemory(100)
+	for i := 1; i <= 1000; i++ {
+		_ = r.IncreaseScores(context.Background(), map[string]int{strconv.Itoa(i): i})
+	}
+
+	top, _ := r.Top(context.Background(), 0, 1000)
+	if len(top) != 100 || top[0].Score != 1000 || top[99].Score != 901 {
+		t.Errorf("unexpected top after evictions, size %d", len(top))
+	}
+}
+
+func TestInMemoryRankingRemoveResetAndMerge(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewInMemory(10)
+	r.now = func() time.Time { return now }
+
+	for _, d := range []struct {
+		city  string
+		score int
+	}{{"barcelona", 10}, {"barcelone", 3}, {"madrid", 5}, {"spamville", 50}} {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	if err := r.Remove(context.Background(), "spamville"); err != nil {
+		t.Fatalf("unexpected error removing city, error %v", err)
+	}
+	if err := r.Remove(context.Background(), "spamville"); err != provider.ErrLocationNotRanked {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+	}
+
+	if err := r.Merge(context.Background(), "barcelone", "barcelona"); err != nil {
+		t.Fatalf("unexpected error merging cities, error %v", err)
+	}
+	if err := r.Merge(context.Background(), "barcelone", "barcelona"); err != provider.ErrLocationNotRanked {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+	}
+
+	for _, w := range []provider.Window{provider.WindowAll, provider.WindowHour} {
+		res, _ := provider.NewLocationRanking(r).GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 5, Window: w})
+		if len(res) != 2 || res[0].Name != "barcelona" || res[0].Score != 13 || res[1].Name != "madrid" {
+			t.Fatalf("unexpected top on window %q, got %v", w, res)
+		}
+	}
+
+	l, err := r.Rank(context.Background(), "madrid")
+	if err != nil || l.Index != 1 {
+		t.Errorf("unexpected madrid rank, got %v error %v", l, err)
+	}
+
+	if err := r.Reset(context.Background()); err != nil {
+		t.Fatalf("unexpected error resetting ranking, error %v", err)
+	}
+	res, _ := r.TopWindow(context.Background(), provider.WindowDay, 0, 5)
+	if n, _ := r.Len(context.Background()); n != 0 || len(res) != 0 {
+		t.Errorf("unexpected ranking after reset, size %d window %v", n, res)
+	}
+}
+
+func TestInMemoryRankingIncreasesBatchedSearches(t *testing.T) {
+	r := NewInMemory(10)
+	_ = r.IncreaseScore(context.Background(), "madrid")
+
+	searches := []provider.Search{{City: "barcelona"}, {City: "madrid"}, {City: "barcelona"}, {City: "barcelona"}}
+	if err := provider.NewLocationRanking(r).IncreaseCityScores(context.Background(), searches); err != nil {
+		t.Fatalf("unexpected error increasing scores, error %v", err)
+	}
+
+	for _, w := range []provider.Window{provider.WindowAll, provider.WindowHour} {
+		res, _ := provider.NewLocationRanking(r).GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 5, Window: w})
+		if len(res) != 2 || res[0].Name != "barcelona" || res[0].Score != 3 || res[1].Score != 2 {
+			t.Errorf("unexpected top on window %q, got %v", w, res)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ranking
+
+import (
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"time"
+)
+
+// DefaultArchivedPeriods bounds archived leaderboards by period kind, older ones are dropped
+const DefaultArchivedPeriods = 104
+
+// periodCounter scores cities by calendar period, current period is live, ended ones are frozen
+// as archived leaderboards, just written by replayed searches, each leaderboard is bounded to size top cities
+type periodCounter struct {
+	kind    provider.PeriodKind
+	size    int
+	periods map[provider.Period]map[string]int
+}
+
+func newPeriodCounter(k provider.PeriodKind, size int) *periodCounter {
+	return &periodCounter{
+		kind:    k,
+		size:    size,
+		periods: make(map[provider.Period]map[string]int),
+	}
+}
+
+// increase adds n to city score on search time period, ended periods are only written on replays,
+// leaderboards out of retention are dropped
+func (c *periodCounter) increase(city string, n int, at, now time.Time, replay bool) {
+	p := provider.PeriodOf(c.kind, at)
+	if !replay && p < provider.PeriodOf(c.kind, now) {
+		return
+	}
+
+	scores, ok := c.periods[p]
+	if !ok {
+		scores = make(map[string]int)
+		c.periods[p] = scores
+	}
+	scores[city] += n
+	trimScores(scores, c.size)
+
+	c.expire(now)
+}
+
+func (c *periodCounter) expire(now time.Time) {
+	oldest := provider.PeriodOf(c.kind, now).Add(-DefaultArchivedPeriods)
+	for p := range c.periods {
+		if p < oldest {
+			delete(c.periods, p)
+		}
+	}
+}
+
+// remove deletes city from current period, archived ones are frozen
+func (c *periodCounter) remove(city string, now time.Time) {
+	delete(c.periods[provider.PeriodOf(c.kind, now)], city)
+}
+
+// merge moves from city score to city on current period, archived ones are frozen
+func (c *periodCounter) merge(from, to string, now time.Time) {
+	scores := c.periods[provider.PeriodOf(c.kind, now)]
+	if s, ok := scores[from]; ok {
+		scores[to] += s
+		delete(scores, from)
+	}
+}
+
+// reset deletes all leaderboards
+func (c *periodCounter) reset() {
+	c.periods = make(map[provider.Period]map[string]int)
+}
+
+// top returns period leaderboard
+func (c *periodCounter) top(p provider.Period, offset, limit int) []*provider.Location {
+	scores := c.periods[p]
+	res := make([]*provider.Location, 0, len(scores))
+	for city, s := range scores {
+		res = append(res, &provider.Location{Name: city, Score: s})
+	}
+	sortLocations(res)
+
+	return page(res, offset, limit)
+}
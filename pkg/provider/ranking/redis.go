@@ -2,60 +2,373 @@ package ranking
 
 import (
 	"context"
+	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"time"
 )
 
 const SortedSetKey = "location-ranking"
 
+const maxInt = int(^uint(0) >> 1)
+
+// mergeScript adds ARGV[1] member score to ARGV[2] member and removes it, atomically on a single sorted set,
+// so that it works on cluster too, returns merged score or nil if ARGV[1] member does not exist.
+var mergeScript = redis.NewScript(`
+local s = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not s then
+	return false
+end
+redis.call('ZINCRBY', KEYS[1], s, ARGV[2])
+redis.call('ZREM', KEYS[1], ARGV[1])
+return s
+`)
+
 // Redis implements a redis baked ranking in top of a sorted set
 type Redis struct {
-	client *redis.Client
+	client  redis.UniversalClient
+	key     string
+	windows []provider.Window
+	periods []provider.PeriodKind
+	now     func() time.Time
+
+	// maxSize trims all time sorted set to its top members on writes, unbounded if zero
+	maxSize int64
+	// ttl expires all time sorted set without writes, never if zero
+	ttl time.Duration
 }
 
 // NewRedis instantiates redis ranking
-func NewRedis(cl *redis.Client) *Redis {
+func NewRedis(cl redis.UniversalClient) *Redis {
+	return NewRedisWithPrefix(cl, "")
+}
+
+// NewRedisWithPrefix instantiates redis ranking with namespaced keys, so that client can be shared
+func NewRedisWithPrefix(cl redis.UniversalClient, prefix string) *Redis {
+	return newRedis(cl, prefix+SortedSetKey, provider.Windows, provider.PeriodKinds)
+}
+
+// newRedis instantiates redis ranking on key tracking just selected windows and periods
+func newRedis(cl redis.UniversalClient, key string, windows []provider.Window, periods []provider.PeriodKind) *Redis {
 	return &Redis{
-		client: cl,
+		client:  cl,
+		key:     key,
+		windows: windows,
+		periods: periods,
+		now:     time.Now,
 	}
 }
 
-// IncreaseScore city score  increase by 1
+// IncreaseScore city score  increase by 1, on all time and window buckets sorted sets
 func (r *Redis) IncreaseScore(ctx context.Context, city string) error {
-	return r.client.ZIncr(ctx, SortedSetKey, &redis.Z{
-		Score:  1,
-		Member: city,
-	}).Err()
+	return r.IncreaseScores(ctx, map[string]int{city: 1})
+}
+
+// IncreaseScores increases each city score by its count on a single round trip, ended periods are only
+// written on replays
+func (r *Redis) IncreaseScores(ctx context.Context, counts map[string]int) error {
+	current := r.now()
+	now := provider.SearchTimeFromContext(ctx, current)
+	replay := provider.IsReplay(ctx)
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for city, n := range counts {
+			p.ZIncr(ctx, r.key, &redis.Z{
+				Score:  float64(n),
+				Member: city,
+			})
+		}
+
+		if r.maxSize > 0 {
+			p.ZRemRangeByRank(ctx, r.key, 0, -r.maxSize-1)
+		}
+
+		if r.ttl > 0 {
+			p.Expire(ctx, r.key, r.ttl)
+		}
+
+		for _, w := range r.windows {
+			k := r.bucketKey(w, w.Bucket(now))
+			for city, n := range counts {
+				p.ZIncr(ctx, k, &redis.Z{
+					Score:  float64(n),
+					Member: city,
+				})
+			}
+			p.Expire(ctx, k, w.Span()+w.Resolution())
+		}
+
+		for _, kind := range r.periods {
+			period := provider.PeriodOf(kind, now)
+			if !replay && period < provider.PeriodOf(kind, current) {
+				continue
+			}
+
+			k := r.periodKey(period)
+			for city, n := range counts {
+				p.ZIncr(ctx, k, &redis.Z{
+					Score:  float64(n),
+					Member: city,
+				})
+			}
+			p.ExpireAt(ctx, k, period.Add(DefaultArchivedPeriods+1).Start())
+			p.SAdd(ctx, r.periodsKey(), k)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// rangeStop returns inclusive sorted set stop index, -1 (last) when offset plus limit overflows
+func rangeStop(offset, limit int) int64 {
+	if limit > maxInt-offset {
+		return -1
+	}
+
+	return int64(offset) + int64(limit) - 1
+}
+
+// Top returns sorted ranking page, up to limit locations from offset
+func (r *Redis) Top(ctx context.Context, offset, limit int) ([]*provider.Location, error) {
+	if limit <= 0 {
+		return []*provider.Location{}, nil
+	}
+
+	res, err := r.client.ZRevRangeWithScores(ctx, r.key, int64(offset), rangeStop(offset, limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return locations(res, offset), nil
+}
+
+// TopWindow aggregates window buckets sorted sets and returns its top
+func (r *Redis) TopWindow(ctx context.Context, w provider.Window, offset, limit int) ([]*provider.Location, error) {
+	if !r.tracks(w) {
+		return nil, provider.ErrUnsupportedWindow
+	}
+
+	if limit <= 0 {
+		return []*provider.Location{}, nil
+	}
+
+	var keys []string
+	for _, b := range w.Buckets(r.now()) {
+		keys = append(keys, r.bucketKey(w, b))
+	}
+
+	dest := r.windowKey(w) + ":union"
+	res, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZUnionStore(ctx, dest, &redis.ZStore{Keys: keys})
+		p.Expire(ctx, dest, w.Resolution())
+		p.ZRevRangeWithScores(ctx, dest, int64(offset), rangeStop(offset, limit))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return locations(res[2].(*redis.ZSliceCmd).Val(), offset), nil
+}
+
+// TopPeriod returns calendar period leaderboard page
+func (r *Redis) TopPeriod(ctx context.Context, period provider.Period, offset, limit int) ([]*provider.Location, error) {
+	if !r.tracksPeriod(period.Kind()) {
+		return nil, provider.ErrUnsupportedPeriod
+	}
+
+	if limit <= 0 {
+		return []*provider.Location{}, nil
+	}
+
+	res, err := r.client.ZRevRangeWithScores(ctx, r.periodKey(period), int64(offset), rangeStop(offset, limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return locations(res, offset), nil
+}
 
+// Len returns ranking size
+func (r *Redis) Len(ctx context.Context) (int64, error) {
+	return r.client.ZCount(ctx, r.key, "-inf", "+inf").Result()
 }
 
-// Top returns priority queue from head up to "size" length
-func (r *Redis) Top(ctx context.Context, size int) ([]*provider.Location, error) {
-	res, err := r.client.ZRevRangeByScoreWithScores(ctx, SortedSetKey, &redis.ZRangeBy{
-		Min: "-inf",
-		Max: "+inf",
-	}).Result()
+// Score returns city score
+func (r *Redis) Score(ctx context.Context, city string) (int, error) {
+	s, err := r.client.ZScore(ctx, r.key, city).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+
+	return int(s), err
+}
+
+// Rank returns city location from its reverse rank
+func (r *Redis) Rank(ctx context.Context, city string) (*provider.Location, error) {
+	var rank *redis.IntCmd
+	var score *redis.FloatCmd
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		rank = p.ZRevRank(ctx, r.key, city)
+		score = p.ZScore(ctx, r.key, city)
+
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, provider.ErrLocationNotRanked
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var top []*provider.Location
-	if len(res) > size {
-		res = res[:size]
+	return &provider.Location{Name: city, Score: int(score.Val()), Index: int(rank.Val())}, nil
+}
+
+// Remove deletes city from all time, window buckets and current periods sorted sets, archived periods are frozen
+func (r *Redis) Remove(ctx context.Context, city string) error {
+	now := r.now()
+	var removed *redis.IntCmd
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		removed = p.ZRem(ctx, r.key, city)
+		for _, w := range r.windows {
+			for _, b := range w.Buckets(now) {
+				p.ZRem(ctx, r.bucketKey(w, b), city)
+			}
+		}
+		for _, kind := range r.periods {
+			p.ZRem(ctx, r.periodKey(provider.PeriodOf(kind, now)), city)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if removed.Val() == 0 {
+		return provider.ErrLocationNotRanked
+	}
+
+	return nil
+}
+
+// Reset deletes all time, window and period sorted sets, buckets out of window just expire
+func (r *Redis) Reset(ctx context.Context) error {
+	var periods []string
+	if len(r.periods) > 0 {
+		var err error
+		periods, err = r.client.SMembers(ctx, r.periodsKey()).Result()
+		if err != nil {
+			return err
+		}
+	}
+
+	now := r.now()
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, r.key)
+		for _, w := range r.windows {
+			keys := []string{r.windowKey(w) + ":union"}
+			for _, b := range w.Buckets(now) {
+				keys = append(keys, r.bucketKey(w, b))
+			}
+			p.Del(ctx, keys...)
+		}
+		if len(r.periods) > 0 {
+			p.Del(ctx, append(periods, r.periodsKey())...)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Merge adds from city score to city and removes it, each sorted set is merged atomically, archived periods are frozen
+func (r *Redis) Merge(ctx context.Context, from, to string) error {
+	err := mergeScript.Run(ctx, r.client, []string{r.key}, from, to).Err()
+	if err == redis.Nil {
+		return provider.ErrLocationNotRanked
+	}
+	if err != nil {
+		return err
+	}
+
+	now := r.now()
+	cmds, _ := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, w := range r.windows {
+			for _, b := range w.Buckets(now) {
+				mergeScript.Eval(ctx, p, []string{r.bucketKey(w, b)}, from, to)
+			}
+		}
+		for _, kind := range r.periods {
+			mergeScript.Eval(ctx, p, []string{r.periodKey(provider.PeriodOf(kind, now))}, from, to)
+		}
+
+		return nil
+	})
+
+	// buckets without from city reply nil
+	for _, c := range cmds {
+		if err := c.Err(); err != nil && err != redis.Nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+// tracks returns if window scores are tracked
+func (r *Redis) tracks(w provider.Window) bool {
+	for _, t := range r.windows {
+		if t == w {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tracksPeriod returns if period leaderboards are tracked
+func (r *Redis) tracksPeriod(k provider.PeriodKind) bool {
+	for _, t := range r.periods {
+		if t == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// periodsKey registers period leaderboard keys, so that they can be reset, sharing cluster slot
+func (r *Redis) periodsKey() string {
+	return fmt.Sprintf("{%s}:periods", r.key)
+}
+
+func (r *Redis) periodKey(p provider.Period) string {
+	return fmt.Sprintf("{%s}:period:%s", r.key, p)
+}
+
+// windowKey uses ranking key as hash tag, so that window keys share cluster slot
+func (r *Redis) windowKey(w provider.Window) string {
+	return fmt.Sprintf("{%s}:%s", r.key, w)
+}
+
+func (r *Redis) bucketKey(w provider.Window, bucket int64) string {
+	return fmt.Sprintf("%s:%d", r.windowKey(w), bucket)
+}
+
+// locations maps sorted set page, indexes are absolute positions
+func locations(res []redis.Z, offset int) []*provider.Location {
+	top := make([]*provider.Location, 0, len(res))
 	for i, re := range res {
 		top = append(top, &provider.Location{
 			Name:  re.Member.(string),
 			Score: int(re.Score),
-			Index: i,
+			Index: offset + i,
 		})
 	}
 
-	return top, nil
-}
-
-// Len returns ranking size
-func (r *Redis) Len(ctx context.Context) (int64, error) {
-	return r.client.ZCount(ctx, SortedSetKey, "-inf", "+inf").Result()
+	return top
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"github.com/go-redis/redis/v8"
 	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"testing"
+	"time"
 )
 
 var dataProvider = []struct {
@@ -90,7 +92,7 @@ func TestRedisRankingTopLocations(t *testing.T) {
 	}
 
 	topSize := 5
-	res, err := r.Top(context.Background(), topSize)
+	res, err := r.Top(context.Background(), 0, topSize)
 	if err != nil {
 		t.Fatalf("unexpected error getting top, error %v", err)
 	}
@@ -109,3 +111,306 @@ func TestRedisRankingTopLocations(t *testing.T) {
 		t.Errorf("unexpected top score, expected %d got %d", expected, res[0].Score)
 	}
 }
+
+func TestRedisRankingTopWindowSlidesOverTime(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewRedisWithPrefix(cl, "test-window:")
+	r.now = func() time.Time { return now }
+	defer func() {
+		keys, err := cl.Keys(context.Background(), "*test-window:*").Result()
+		if err != nil {
+			t.Fatalf("unexpected error listing keys, error %v", err)
+		}
+		if err := cl.Del(context.Background(), keys...).Err(); err != nil {
+			t.Fatalf("unexpected error removing keys, error %v", err)
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+
+	now = now.Add(time.Hour * 2)
+	for i := 0; i < 3; i++ {
+		_ = r.IncreaseScore(context.Background(), "madrid")
+	}
+
+	res, err := r.TopWindow(context.Background(), provider.WindowHour, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting window top, error %v", err)
+	}
+	if len(res) != 1 || res[0].Name != "madrid" || res[0].Score != 3 {
+		t.Fatalf("unexpected hour window top, got %v", res)
+	}
+
+	res, err = r.TopWindow(context.Background(), provider.WindowDay, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting window top, error %v", err)
+	}
+	if len(res) != 2 || res[0].Name != "barcelona" || res[0].Score != 10 {
+		t.Fatalf("unexpected day window top, got %v", res)
+	}
+
+	ttl, err := cl.TTL(context.Background(), r.bucketKey(provider.WindowHour, provider.WindowHour.Bucket(now))).Result()
+	if err != nil {
+		t.Fatalf("unexpected error getting bucket ttl, error %v", err)
+	}
+	if ttl <= 0 || ttl > provider.WindowHour.Span()+provider.WindowHour.Resolution() {
+		t.Errorf("unexpected bucket ttl %s", ttl)
+	}
+}
+
+func TestRedisRankingTopPageAndRank(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewRedisWithPrefix(cl, "test-rank:")
+	defer func() {
+		keys, err := cl.Keys(context.Background(), "*test-rank:*").Result()
+		if err != nil {
+			t.Fatalf("unexpected error listing keys, error %v", err)
+		}
+		if err := cl.Del(context.Background(), keys...).Err(); err != nil {
+			t.Fatalf("unexpected error removing keys, error %v", err)
+		}
+	}()
+
+	for _, d := range dataProvider {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	res, err := r.Top(context.Background(), 2, 3)
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+	if len(res) != 3 || res[0].Name != "zoo" || res[0].Index != 2 || res[2].Name != "bar" {
+		t.Fatalf("unexpected page, got %v", res)
+	}
+
+	res, err = r.Top(context.Background(), 1, maxInt)
+	if err != nil {
+		t.Fatalf("unexpected error getting top on overflowing limit, error %v", err)
+	}
+	if len(res) != len(dataProvider)-1 || res[0].Name != "london" {
+		t.Fatalf("unexpected page on overflowing limit, got %v", res)
+	}
+
+	l, err := r.Rank(context.Background(), "foo")
+	if err != nil {
+		t.Fatalf("unexpected error getting rank, error %v", err)
+	}
+	if l.Index != 3 || l.Score != 4 {
+		t.Errorf("unexpected foo rank, expected 3 with score 4 got %d with score %d", l.Index, l.Score)
+	}
+
+	if _, err := r.Rank(context.Background(), "unknown"); err != provider.ErrLocationNotRanked {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+	}
+}
+
+func TestRedisRankingRemoveResetAndMerge(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewRedisWithPrefix(cl, "test-admin:")
+	defer func() {
+		keys, err := cl.Keys(context.Background(), "*test-admin:*").Result()
+		if err != nil {
+			t.Fatalf("unexpected error listing keys, error %v", err)
+		}
+		if len(keys) == 0 {
+			return
+		}
+		if err := cl.Del(context.Background(), keys...).Err(); err != nil {
+			t.Fatalf("unexpected error removing keys, error %v", err)
+		}
+	}()
+
+	for _, d := range []struct {
+		city  string
+		score int
+	}{{"barcelona", 10}, {"barcelone", 3}, {"madrid", 5}, {"spamville", 50}} {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	if err := r.Remove(context.Background(), "spamville"); err != nil {
+		t.Fatalf("unexpected error removing city, error %v", err)
+	}
+	if err := r.Remove(context.Background(), "spamville"); err != provider.ErrLocationNotRanked {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+	}
+
+	if err := r.Merge(context.Background(), "barcelone", "barcelona"); err != nil {
+		t.Fatalf("unexpected error merging cities, error %v", err)
+	}
+	if err := r.Merge(context.Background(), "barcelone", "barcelona"); err != provider.ErrLocationNotRanked {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+	}
+
+	for _, w := range []provider.Window{provider.WindowAll, provider.WindowHour} {
+		res, err := provider.NewLocationRanking(r).GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 5, Window: w})
+		if err != nil {
+			t.Fatalf("unexpected error getting top, error %v", err)
+		}
+		if len(res) != 2 || res[0].Name != "barcelona" || res[0].Score != 13 || res[1].Name != "madrid" {
+			t.Fatalf("unexpected top on window %q, got %v", w, res)
+		}
+	}
+
+	if err := r.Reset(context.Background()); err != nil {
+		t.Fatalf("unexpected error resetting ranking, error %v", err)
+	}
+	res, err := r.TopWindow(context.Background(), provider.WindowDay, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting window top, error %v", err)
+	}
+	if n, _ := r.Len(context.Background()); n != 0 || len(res) != 0 {
+		t.Errorf("unexpected ranking after reset, size %d window %v", n, res)
+	}
+}
+
+func TestRedisRankingArchivesPeriodLeaderboards(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewRedisWithPrefix(cl, "test-periods:")
+	defer func() {
+		keys, err := cl.Keys(context.Background(), "*test-periods:*").Result()
+		if err != nil {
+			t.Fatalf("unexpected error listing keys, error %v", err)
+		}
+		if len(keys) == 0 {
+			return
+		}
+		if err := cl.Del(context.Background(), keys...).Err(); err != nil {
+			t.Fatalf("unexpected error removing keys, error %v", err)
+		}
+	}()
+
+	now := time.Date(2026, 9, 30, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+	_ = r.IncreaseScore(context.Background(), "madrid")
+
+	now = now.Add(time.Hour * 24)
+	for i := 0; i < 3; i++ {
+		_ = r.IncreaseScore(context.Background(), "madrid")
+	}
+
+	// ended periods are frozen, removal applies to current ones
+	_ = r.Remove(context.Background(), "barcelona")
+
+	res, err := provider.NewLocationRanking(r).GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 5, Period: "2026-09"})
+	if err != nil {
+		t.Fatalf("unexpected error getting period top, error %v", err)
+	}
+	if len(res) != 2 || res[0].Name != "barcelona" || res[0].Score != 5 || res[1].Name != "madrid" || res[1].Score != 1 {
+		t.Fatalf("unexpected september leaderboard, got %v", res)
+	}
+
+	res, _ = r.TopPeriod(context.Background(), "2026-W40", 0, 5)
+	if len(res) != 1 || res[0].Name != "madrid" || res[0].Score != 4 {
+		t.Fatalf("unexpected week leaderboard, got %v", res)
+	}
+
+	ttl, _ := cl.TTL(context.Background(), r.periodKey("2026-09")).Result()
+	if ttl <= 0 {
+		t.Errorf("expected archived leaderboard expiration, got %v", ttl)
+	}
+
+	if err := r.Reset(context.Background()); err != nil {
+		t.Fatalf("unexpected error resetting ranking, error %v", err)
+	}
+	if res, _ := r.TopPeriod(context.Background(), "2026-09", 0, 5); len(res) != 0 {
+		t.Errorf("unexpected leaderboard after reset, got %v", res)
+	}
+	if n, _ := cl.Exists(context.Background(), r.periodsKey()).Result(); n != 0 {
+		t.Errorf("expected period registry removed")
+	}
+}
+
+func TestRedisRankingFreezesEndedPeriodsButOnReplays(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewRedisWithPrefix(cl, "test-frozen-periods:")
+	defer func() {
+		keys, _ := cl.Keys(context.Background(), "*test-frozen-periods:*").Result()
+		if len(keys) > 0 {
+			_ = cl.Del(context.Background(), keys...).Err()
+		}
+	}()
+
+	now := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	late := provider.WithSearchTime(context.Background(), now.Add(-time.Hour*24))
+	_ = r.IncreaseScore(late, "barcelona")
+	_ = r.IncreaseScores(provider.WithReplay(late), map[string]int{"madrid": 2})
+
+	res, _ := r.TopPeriod(context.Background(), "2026-09", 0, 5)
+	if len(res) != 1 || res[0].Name != "madrid" || res[0].Score != 2 {
+		t.Errorf("unexpected september leaderboard, got %v", res)
+	}
+
+	if s, _ := r.Score(context.Background(), "barcelona"); s != 1 {
+		t.Errorf("expected late search counted on all time ranking, got %d", s)
+	}
+}
+
+func TestRedisRankingIncreasesBatchedSearches(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	r := NewRedisWithPrefix(cl, "test-batch:")
+	defer func() {
+		keys, err := cl.Keys(context.Background(), "*test-batch:*").Result()
+		if err != nil {
+			t.Fatalf("unexpected error listing keys, error %v", err)
+		}
+		if err := cl.Del(context.Background(), keys...).Err(); err != nil {
+			t.Fatalf("unexpected error removing keys, error %v", err)
+		}
+	}()
+
+	if err := r.IncreaseScores(context.Background(), map[string]int{"barcelona": 3, "madrid": 2}); err != nil {
+		t.Fatalf("unexpected error increasing scores, error %v", err)
+	}
+
+	for _, w := range []provider.Window{provider.WindowAll, provider.WindowWeek} {
+		res, err := provider.NewLocationRanking(r).GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 5, Window: w})
+		if err != nil {
+			t.Fatalf("unexpected error getting top, error %v", err)
+		}
+		if len(res) != 2 || res[0].Name != "barcelona" || res[0].Score != 3 || res[1].Score != 2 {
+			t.Errorf("unexpected top on window %q, got %v", w, res)
+		}
+	}
+}
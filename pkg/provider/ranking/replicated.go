@@ -0,0 +1,492 @@
+package ranking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ReplicaCounter defines a city score as a PN counter, two G-counters with a slot by replica, increments
+// on P and decrements on N, each replica only writes its own slots and merges take slots max, so that
+// replicas converge whatever the merge order is
+type ReplicaCounter struct {
+	P map[string]int `json:"p"`
+	N map[string]int `json:"n,omitempty"`
+}
+
+// ReplicatedState defines replicated ranking state exchanged between replicas, counters by city
+type ReplicatedState map[string]*ReplicaCounter
+
+// ownSlots defines replica own slots of a pruned city
+type ownSlots struct {
+	P int `json:"p"`
+	N int `json:"n,omitempty"`
+}
+
+// Replicated implements a conflict free replicated inMemory ranking, without windows, merged state
+// converges on every replica once gossiped. Removals, resets and merges decrement observed scores,
+// so that concurrent searches not yet observed are kept. State is bounded to its top cities, lowest
+// merged scores are pruned once every peer has acknowledged its last change (see Acknowledge), so just
+// cities on any replica top are gossiped back. Pruned cities keep replica own slots as tombstones, revived
+// on writes or merges, as own slots must never go backwards. Own slots are persisted before being gossiped
+// (see Persist), so a restarted replica keeps counting on its slots.
+type Replicated struct {
+	id           string
+	state        ReplicatedState
+	tombstones   map[string]ownSlots
+	maxSize      int
+	version      uint64
+	changed      map[string]uint64
+	acked        uint64
+	nextPrune    int
+	path         string
+	ownVersion   uint64
+	persisted    uint64
+	mutex        sync.RWMutex
+	persistMutex sync.Mutex
+}
+
+// NewReplicated instantiates replicated ranking for unique and stable replica id bounded to size cities
+func NewReplicated(id string, size int) *Replicated {
+	return &Replicated{
+		id:         id,
+		state:      make(ReplicatedState),
+		tombstones: make(map[string]ownSlots),
+		maxSize:    size,
+		changed:    make(map[string]uint64),
+	}
+}
+
+// Persist restores replica own slots from path, if it exists, and persists them there before each gossip
+func (r *Replicated) Persist(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	slots := make(map[string]ownSlots)
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &slots); err != nil {
+			return fmt.Errorf("invalid replica state file, error %w", err)
+		}
+	}
+
+	r.persistMutex.Lock()
+	defer r.persistMutex.Unlock()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.path = path
+	for city, own := range slots {
+		c := r.counter(city)
+		if own.P > c.P[r.id] {
+			c.P[r.id] = own.P
+		}
+		if own.N > c.N[r.id] {
+			c.N[r.id] = own.N
+		}
+		// restored slots might not have reached peers, so they are gossiped before being pruned
+		r.change(city)
+	}
+	r.prune()
+
+	return nil
+}
+
+// IncreaseScore city score increase by 1 on replica slot
+func (r *Replicated) IncreaseScore(_ context.Context, city string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.counter(city).P[r.id]++
+	r.changeOwn(city)
+	r.prune()
+
+	return nil
+}
+
+// IncreaseScores increases each city score by its count under a single lock
+func (r *Replicated) IncreaseScores(_ context.Context, counts map[string]int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for city, n := range counts {
+		r.counter(city).P[r.id] += n
+		r.changeOwn(city)
+	}
+	r.prune()
+
+	return nil
+}
+
+// Top returns sorted merged ranking page, up to limit locations from offset
+func (r *Replicated) Top(_ context.Context, offset, limit int) ([]*provider.Location, error) {
+	r.mutex.RLock()
+	res := make([]*provider.Location, 0, len(r.state))
+	for city, c := range r.state {
+		if v := c.value(); v > 0 {
+			res = append(res, &provider.Location{Name: city, Score: v})
+		}
+	}
+	r.mutex.RUnlock()
+
+	sortLocations(res)
+
+	return page(res, offset, limit), nil
+}
+
+// TopWindow is not supported, window buckets are not replicated
+func (r *Replicated) TopWindow(_ context.Context, _ provider.Window, _, _ int) ([]*provider.Location, error) {
+	return nil, provider.ErrUnsupportedWindow
+}
+
+// Len returns ranked cities
+func (r *Replicated) Len(_ context.Context) (int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var n int64
+	for _, c := range r.state {
+		if c.value() > 0 {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Score returns city merged score
+func (r *Replicated) Score(_ context.Context, city string) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.score(city), nil
+}
+
+// Rank returns city location, rank is the number of locations ranked before it
+func (r *Replicated) Rank(_ context.Context, city string) (*provider.Location, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	v := r.score(city)
+	if v <= 0 {
+		return nil, provider.ErrLocationNotRanked
+	}
+
+	var rank int
+	for name, c := range r.state {
+		if s := c.value(); s > 0 && ranksBefore(name, s, city, v) {
+			rank++
+		}
+	}
+
+	return &provider.Location{Name: city, Score: v, Index: rank}, nil
+}
+
+// Remove decrements city observed score on replica slot
+func (r *Replicated) Remove(_ context.Context, city string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v := r.score(city)
+	if v <= 0 {
+		return provider.ErrLocationNotRanked
+	}
+	r.counter(city).N[r.id] += v
+	r.changeOwn(city)
+
+	return nil
+}
+
+// Reset decrements all observed scores on replica slots
+func (r *Replicated) Reset(_ context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for city, c := range r.state {
+		if v := c.value(); v > 0 {
+			r.counter(city).N[r.id] += v
+			r.changeOwn(city)
+		}
+	}
+
+	return nil
+}
+
+// Merge moves from city observed score to city on replica slots
+func (r *Replicated) Merge(_ context.Context, from, to string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v := r.score(from)
+	if v <= 0 {
+		return provider.ErrLocationNotRanked
+	}
+	r.counter(from).N[r.id] += v
+	r.counter(to).P[r.id] += v
+	r.changeOwn(from)
+	r.changeOwn(to)
+
+	return nil
+}
+
+// State returns a copy of replica state
+func (r *Replicated) State() ReplicatedState {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.copyState()
+}
+
+// Publish returns a copy of replica state to gossip, own slots are persisted first if they changed since
+// last published state, so that gossiped own slots are never lost on restarts
+func (r *Replicated) Publish() (ReplicatedState, error) {
+	r.persistMutex.Lock()
+	defer r.persistMutex.Unlock()
+
+	r.mutex.RLock()
+	s := r.copyState()
+	v := r.ownVersion
+	var own map[string]ownSlots
+	if r.path != "" && v != r.persisted {
+		own = r.ownSlots()
+	}
+	r.mutex.RUnlock()
+
+	if own != nil {
+		if err := writeOwnSlots(r.path, own); err != nil {
+			return nil, err
+		}
+		r.persisted = v
+	}
+
+	return s, nil
+}
+
+// Version returns state changes version, to be acknowledged once state is gossiped to every peer
+func (r *Replicated) Version() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.version
+}
+
+// Acknowledge marks changes up to version as held by every peer, so that its cities can be pruned
+func (r *Replicated) Acknowledge(version uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if version <= r.acked {
+		return
+	}
+
+	r.acked = version
+	for city, v := range r.changed {
+		if v <= version {
+			delete(r.changed, city)
+		}
+	}
+	r.nextPrune = 0
+	r.prune()
+}
+
+// MergeState merges replica state, taking each slot max. Cities raised by the merge, or the ones the peer
+// is behind on, are changed, so they are gossiped back before being pruned.
+func (r *Replicated) MergeState(s ReplicatedState) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for city, in := range s {
+		if in == nil {
+			continue
+		}
+
+		c := r.counter(city)
+		ownP, ownN := c.P[r.id], c.N[r.id]
+		raised := mergeSlots(c.P, in.P)
+		raised = mergeSlots(c.N, in.N) || raised
+		if raised || ahead(c.P, in.P) || ahead(c.N, in.N) {
+			r.change(city)
+		}
+		if c.P[r.id] != ownP || c.N[r.id] != ownN {
+			r.ownVersion++
+		}
+	}
+	r.prune()
+}
+
+func (r *Replicated) score(city string) int {
+	c, ok := r.state[city]
+	if !ok {
+		return 0
+	}
+
+	return c.value()
+}
+
+// change marks city as changed on a new version
+func (r *Replicated) change(city string) {
+	r.version++
+	r.changed[city] = r.version
+}
+
+// changeOwn marks city as changed on replica own slots
+func (r *Replicated) changeOwn(city string) {
+	r.change(city)
+	r.ownVersion++
+}
+
+// prune drops lowest merged score cities, acknowledged by every peer, once state exceeds its size by a quarter,
+// so that pruning cost is amortized along inserts. Pruned cities own slots are kept as tombstones.
+func (r *Replicated) prune() {
+	if r.maxSize <= 0 || len(r.state) <= r.maxSize+r.maxSize/4 || len(r.state) <= r.nextPrune {
+		return
+	}
+
+	res := make([]*provider.Location, 0, len(r.state))
+	for city, c := range r.state {
+		res = append(res, &provider.Location{Name: city, Score: c.value()})
+	}
+	sortLocations(res)
+	for _, l := range res[r.maxSize:] {
+		if _, ok := r.changed[l.Name]; ok {
+			continue
+		}
+
+		c := r.state[l.Name]
+		if own := (ownSlots{P: c.P[r.id], N: c.N[r.id]}); own.P > 0 || own.N > 0 {
+			r.tombstones[l.Name] = own
+		}
+		delete(r.state, l.Name)
+	}
+
+	// cities pending acknowledgement are not pruned, next attempt waits for a quarter more
+	if len(r.state) > r.maxSize+r.maxSize/4 {
+		r.nextPrune = len(r.state) + r.maxSize/4
+	}
+}
+
+// counter returns city counter, created if missing, pruned ones revived from its tombstone
+func (r *Replicated) counter(city string) *ReplicaCounter {
+	c, ok := r.state[city]
+	if !ok {
+		c = &ReplicaCounter{P: make(map[string]int), N: make(map[string]int)}
+		if own, ok := r.tombstones[city]; ok {
+			c.P[r.id], c.N[r.id] = own.P, own.N
+			delete(r.tombstones, city)
+		}
+		r.state[city] = c
+	}
+
+	if c.P == nil {
+		c.P = make(map[string]int)
+	}
+
+	if c.N == nil {
+		c.N = make(map[string]int)
+	}
+
+	return c
+}
+
+func (r *Replicated) copyState() ReplicatedState {
+	res := make(ReplicatedState, len(r.state))
+	for city, c := range r.state {
+		res[city] = &ReplicaCounter{P: copySlots(c.P), N: copySlots(c.N)}
+	}
+
+	return res
+}
+
+// ownSlots returns replica own slots of live and pruned cities
+func (r *Replicated) ownSlots() map[string]ownSlots {
+	res := make(map[string]ownSlots, len(r.state)+len(r.tombstones))
+	for city, own := range r.tombstones {
+		res[city] = own
+	}
+	for city, c := range r.state {
+		if own := (ownSlots{P: c.P[r.id], N: c.N[r.id]}); own.P > 0 || own.N > 0 {
+			res[city] = own
+		}
+	}
+
+	return res
+}
+
+// value returns merged score
+func (c *ReplicaCounter) value() int {
+	var v int
+	for _, n := range c.P {
+		v += n
+	}
+	for _, n := range c.N {
+		v -= n
+	}
+
+	return v
+}
+
+// mergeSlots takes each slot max, returns if any dst slot was raised
+func mergeSlots(dst, src map[string]int) bool {
+	var raised bool
+	for id, n := range src {
+		if n > dst[id] {
+			dst[id] = n
+			raised = true
+		}
+	}
+
+	return raised
+}
+
+// ahead returns if any slot is greater than the peer one
+func ahead(slots, peer map[string]int) bool {
+	for id, n := range slots {
+		if n > peer[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeOwnSlots writes own slots to a temporary file on the same directory and renames it
+func writeOwnSlots(path string, own map[string]ownSlots) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := json.NewEncoder(tmp).Encode(own); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func copySlots(s map[string]int) map[string]int {
+	res := make(map[string]int, len(s))
+	for id, n := range s {
+		res[id] = n
+	}
+
+	return res
+}
@@ -0,0 +1,184 @@
+package ranking
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestReplicatedRankingsConvergeWhateverMergeOrder(t *testing.T) {
+	a, b, c := NewReplicated("a", 10), NewReplicated("b", 10), NewReplicated("c", 10)
+	for i := 0; i < 5; i++ {
+		_ = a.IncreaseScore(context.Background(), "barcelona")
+	}
+	_ = b.IncreaseScores(context.Background(), map[string]int{"barcelona": 2, "madrid": 4})
+	_ = c.IncreaseScore(context.Background(), "london")
+
+	// duplicated and out of order merges are idempotent
+	a.MergeState(b.State())
+	c.MergeState(a.State())
+	a.MergeState(b.State())
+	b.MergeState(c.State())
+	a.MergeState(c.State())
+
+	expected := []*provider.Location{{Name: "barcelona", Score: 7, Index: 0}, {Name: "madrid", Score: 4, Index: 1}, {Name: "london", Score: 1, Index: 2}}
+	for _, r := range []*Replicated{a, b, c} {
+		top, _ := r.Top(context.Background(), 0, 5)
+		if !reflect.DeepEqual(top, expected) {
+			t.Errorf("unexpected top on replica %s, got %v", r.id, top)
+		}
+	}
+}
+
+func TestReplicatedRankingRemovesObservedScores(t *testing.T) {
+	a, b := NewReplicated("a", 10), NewReplicated("b", 10)
+	_ = a.IncreaseScores(context.Background(), map[string]int{"spamville": 10, "barcelone": 2, "barcelona": 5})
+	b.MergeState(a.State())
+
+	if err := b.Remove(context.Background(), "spamville"); err != nil {
+		t.Fatalf("unexpected error removing city, error %v", err)
+	}
+	if err := b.Merge(context.Background(), "barcelone", "barcelona"); err != nil {
+		t.Fatalf("unexpected error merging cities, error %v", err)
+	}
+
+	// concurrent search not observed by b when removing
+	_ = a.IncreaseScore(context.Background(), "spamville")
+	a.MergeState(b.State())
+	b.MergeState(a.State())
+
+	for _, r := range []*Replicated{a, b} {
+		top, _ := r.Top(context.Background(), 0, 5)
+		if len(top) != 2 || top[0].Name != "barcelona" || top[0].Score != 7 || top[1].Name != "spamville" || top[1].Score != 1 {
+			t.Errorf("unexpected top on replica %s, got %v", r.id, top)
+		}
+
+		if _, err := r.Rank(context.Background(), "barcelone"); err != provider.ErrLocationNotRanked {
+			t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+		}
+	}
+
+	_ = a.Reset(context.Background())
+	b.MergeState(a.State())
+	if n, _ := b.Len(context.Background()); n != 0 {
+		t.Errorf("unexpected ranking size after reset, got %d", n)
+	}
+
+	_ = b.IncreaseScore(context.Background(), "madrid")
+	if l, _ := b.Rank(context.Background(), "madrid"); l == nil || l.Score != 1 || l.Index != 0 {
+		t.Errorf("unexpected rank after reset, got %v", l)
+	}
+}
+
+func TestReplicatedRankingRestartedReplicaKeepsCountingOnItsSlots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replica-a.json")
+	a, b := NewReplicated("a", 10), NewReplicated("b", 10)
+	if err := a.Persist(path); err != nil {
+		t.Fatalf("unexpected error persisting replica, error %v", err)
+	}
+	_ = a.IncreaseScores(context.Background(), map[string]int{"barcelona": 5})
+	s, err := a.Publish()
+	if err != nil {
+		t.Fatalf("unexpected error publishing state, error %v", err)
+	}
+	b.MergeState(s)
+
+	// a restarts restoring its own slots, its new searches are counted on top of gossiped ones
+	a = NewReplicated("a", 10)
+	if err := a.Persist(path); err != nil {
+		t.Fatalf("unexpected error restoring replica, error %v", err)
+	}
+	_ = a.IncreaseScore(context.Background(), "barcelona")
+	a.MergeState(b.State())
+	b.MergeState(a.State())
+
+	for _, r := range []*Replicated{a, b} {
+		if s, _ := r.Score(context.Background(), "barcelona"); s != 6 {
+			t.Errorf("unexpected score on replica %s, expected 6 got %d", r.id, s)
+		}
+
+		if slots := r.State()["barcelona"].P; len(slots) != 1 {
+			t.Errorf("unexpected slots on replica %s, got %v", r.id, slots)
+		}
+	}
+}
+
+func TestReplicatedRankingBoundsStateToAcknowledgedTopCities(t *testing.T) {
+	r := NewReplicated("a", 4)
+	for i := 1; i <= 10; i++ {
+		_ = r.IncreaseScores(context.Background(), map[string]int{strconv.Itoa(i): i})
+	}
+
+	// changes not yet gossiped to every peer are kept
+	if n := len(r.State()); n != 10 {
+		t.Errorf("unexpected state size before acknowledgement, got %d", n)
+	}
+
+	r.Acknowledge(r.Version())
+	if n := len(r.State()); n > 5 {
+		t.Errorf("unexpected state size, got %d", n)
+	}
+
+	top, _ := r.Top(context.Background(), 0, 4)
+	if len(top) != 4 || top[0].Score != 10 || top[3].Score != 7 {
+		t.Errorf("unexpected top, got %v", top)
+	}
+}
+
+func TestReplicatedRankingPrunedCitiesConvergeOnNewSearches(t *testing.T) {
+	a, b := NewReplicated("a", 2), NewReplicated("b", 2)
+	_ = a.IncreaseScores(context.Background(), map[string]int{"barcelona": 3})
+	b.MergeState(a.State())
+	a.MergeState(b.State())
+	a.Acknowledge(a.Version())
+	b.Acknowledge(b.Version())
+
+	_ = a.IncreaseScores(context.Background(), map[string]int{"madrid": 10, "london": 10, "paris": 10})
+	if _, ok := a.State()["barcelona"]; ok {
+		t.Fatalf("expected barcelona pruned, got %v", a.State())
+	}
+
+	// own slots are revived from its tombstone, so b slot max does not hide new searches
+	_ = a.IncreaseScore(context.Background(), "barcelona")
+	b.MergeState(a.State())
+	a.MergeState(b.State())
+
+	for _, r := range []*Replicated{a, b} {
+		if s, _ := r.Score(context.Background(), "barcelona"); s != 4 {
+			t.Errorf("unexpected score on replica %s, expected 4 got %d", r.id, s)
+		}
+	}
+}
+
+func TestReplicatedRankingPrunedRemovalsAreNotResurrected(t *testing.T) {
+	a, b, c := NewReplicated("a", 1), NewReplicated("b", 1), NewReplicated("c", 1)
+	_ = a.IncreaseScores(context.Background(), map[string]int{"spamville": 3})
+	b.MergeState(a.State())
+	c.MergeState(a.State())
+
+	// removal is not pruned before being gossiped
+	_ = b.Remove(context.Background(), "spamville")
+	_ = b.IncreaseScores(context.Background(), map[string]int{"madrid": 5, "london": 5})
+	if _, ok := b.State()["spamville"]; !ok {
+		t.Fatalf("expected removal kept until acknowledged, got %v", b.State())
+	}
+
+	a.MergeState(b.State())
+	b.Acknowledge(b.Version())
+	if _, ok := b.State()["spamville"]; ok {
+		t.Fatalf("expected spamville pruned, got %v", b.State())
+	}
+
+	// c never observed the removal, b revives it from its tombstone and gossips it back
+	b.MergeState(c.State())
+	c.MergeState(b.State())
+
+	for _, r := range []*Replicated{a, b, c} {
+		if s, _ := r.Score(context.Background(), "spamville"); s != 0 {
+			t.Errorf("unexpected score on replica %s, expected 0 got %d", r.id, s)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package ranking
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/leader"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotVersion defines current snapshot format version
+const SnapshotVersion = 1
+
+// ErrUnknownSnapshotVersion happens on snapshots written by a newer format
+var ErrUnknownSnapshotVersion = errors.New("unknown ranking snapshot version")
+
+// ErrStaleFence happens on snapshot writes from a deposed leader, once a newer leader has written it
+var ErrStaleFence = errors.New("stale leadership fencing token")
+
+// Snapshot defines inMemory ranking persisted state, window buckets and period leaderboards included
+type Snapshot struct {
+	Version   int                                                        `json:"version"`
+	CreatedAt time.Time                                                  `json:"created_at"`
+	Locations []*provider.Location                                       `json:"locations"`
+	Windows   map[provider.Window]map[int64]map[string]int               `json:"windows"`
+	Periods   map[provider.PeriodKind]map[provider.Period]map[string]int `json:"periods,omitempty"`
+}
+
+// Save writes ranking snapshot
+func (i *InMemory) Save(w io.Writer) error {
+	i.mutex.RLock()
+	s := Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: i.now(),
+		Locations: make([]*provider.Location, 0, len(i.priorityQueue)),
+		Windows:   make(map[provider.Window]map[int64]map[string]int),
+		Periods:   make(map[provider.PeriodKind]map[provider.Period]map[string]int),
+	}
+	for _, v := range i.priorityQueue {
+		s.Locations = append(s.Locations, &provider.Location{Name: v.Name, Score: v.Score})
+	}
+	for w, c := range i.windows {
+		buckets := make(map[int64]map[string]int, len(c.buckets))
+		for id, b := range c.buckets {
+			scores := make(map[string]int, len(b))
+			for city, n := range b {
+				scores[city] = n
+			}
+			buckets[id] = scores
+		}
+		s.Windows[w] = buckets
+	}
+	for k, c := range i.periods {
+		periods := make(map[provider.Period]map[string]int, len(c.periods))
+		for p, scores := range c.periods {
+			copied := make(map[string]int, len(scores))
+			for city, n := range scores {
+				copied[city] = n
+			}
+			periods[p] = copied
+		}
+		s.Periods[k] = periods
+	}
+	i.mutex.RUnlock()
+
+	return json.NewEncoder(w).Encode(s)
+}
+
+// Load replaces ranking state from snapshot, just top locations are kept if snapshot exceeds max size
+func (i *InMemory) Load(r io.Reader) error {
+	s := Snapshot{}
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("invalid ranking snapshot, error %w", err)
+	}
+
+	if s.Version != SnapshotVersion {
+		return ErrUnknownSnapshotVersion
+	}
+
+	sort.Slice(s.Locations, func(a, b int) bool {
+		return ranksBefore(s.Locations[a].Name, s.Locations[a].Score, s.Locations[b].Name, s.Locations[b].Score)
+	})
+	if len(s.Locations) > i.maxSize {
+		s.Locations = s.Locations[:i.maxSize]
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	pq := make(PriorityQueue, 0, len(s.Locations))
+	index := make(map[string]*provider.Location, len(s.Locations))
+	for _, l := range s.Locations {
+		item := &provider.Location{Name: l.Name, Score: l.Score}
+		pq.Push(item)
+		index[l.Name] = item
+	}
+	heap.Init(&pq)
+	i.priorityQueue = pq
+	i.index = index
+
+	now := i.now()
+	for w, c := range i.windows {
+		c.buckets = make(map[int64]map[string]int)
+		for id, b := range s.Windows[w] {
+			c.buckets[id] = b
+		}
+		c.expire(now)
+	}
+	for k, c := range i.periods {
+		c.periods = make(map[provider.Period]map[string]int)
+		for p, scores := range s.Periods[k] {
+			c.periods[p] = scores
+		}
+		c.expire(now)
+	}
+
+	return nil
+}
+
+// FileSnapshot persists inMemory ranking periodically on a file, snapshots are written atomically
+type FileSnapshot struct {
+	ranking *InMemory
+	path    string
+	freq    time.Duration
+	elector leader.Elector
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewFileSnapshot instantiates ranking file snapshot
+func NewFileSnapshot(r *InMemory, path string, freq time.Duration) *FileSnapshot {
+	return &FileSnapshot{
+		ranking: r,
+		path:    path,
+		freq:    freq,
+		done:    make(chan struct{}),
+	}
+}
+
+// WithElector restricts periodic and last snapshots to leader replica, so that replicas sharing
+// snapshot file do not overwrite each other
+func (f *FileSnapshot) WithElector(e leader.Elector) *FileSnapshot {
+	f.elector = e
+
+	return f
+}
+
+// Restore loads ranking from snapshot file, a missing file is an empty ranking
+func (f *FileSnapshot) Restore() error {
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return f.ranking.Load(file)
+}
+
+// Run starts periodic snapshot worker
+func (f *FileSnapshot) Run() {
+	f.wg.Add(1)
+	go f.runner()
+}
+
+// Write dumps ranking to a temporary file on the same directory and renames it, so that readers never get partial snapshots.
+// Writes are not fenced, as on stopped instances administration.
+func (f *FileSnapshot) Write() error {
+	return f.write(0)
+}
+
+// write dumps ranking as Write does, fenced writes are rejected if a newer leader has written the snapshot,
+// its fencing token is kept on a side file. Fenced writes check the token and rename the snapshot holding an
+// exclusive lock on a side lock file, so that a deposed leader can not rename a stale snapshot in between.
+func (f *FileSnapshot) write(fence int64) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := f.ranking.Save(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if fence > 0 {
+		unlock, err := f.lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := f.fence(fence); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// lock takes snapshot exclusive lock, held by writers from check to rename, returns its release
+func (f *FileSnapshot) lock() (func(), error) {
+	l, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(l); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	return func() {
+		_ = unlockFile(l)
+		_ = l.Close()
+	}, nil
+}
+
+// fence checks stored fencing token and stores the held one, a newer stored one rejects the write, snapshot
+// lock must be held
+func (f *FileSnapshot) fence(fence int64) error {
+	path := f.path + ".fence"
+	raw, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(raw) > 0 {
+		stored, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid snapshot fencing token, error %w", err)
+		}
+
+		if stored > fence {
+			return ErrStaleFence
+		}
+
+		if stored == fence {
+			return nil
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.WriteString(strconv.FormatInt(fence, 10)); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Terminate stops worker and writes last snapshot
+func (f *FileSnapshot) Terminate() error {
+	close(f.done)
+	f.wg.Wait()
+
+	fence, ok := f.leads()
+	if !ok {
+		return nil
+	}
+
+	return f.write(fence)
+}
+
+func (f *FileSnapshot) runner() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.freq)
+	for {
+		select {
+		case <-ticker.C:
+			fence, ok := f.leads()
+			if !ok {
+				continue
+			}
+			if err := f.write(fence); err != nil {
+				log.Errorf("unexpected error writing ranking snapshot, error %v", err)
+			}
+		case <-f.done:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// leads returns leadership fencing token, zero without elector or contested leadership, as writes are not fenced
+func (f *FileSnapshot) leads() (int64, bool) {
+	if f.elector == nil {
+		return 0, true
+	}
+
+	return f.elector.Leader()
+}
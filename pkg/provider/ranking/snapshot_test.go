@@ -0,0 +1,225 @@
+package ranking
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSnapshotRestoresRankingAndWindows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ranking")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	now := time.Now()
+	r := NewInMemory(10)
+	r.now = func() time.Time { return now }
+	for _, d := range dataProvider {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	path := filepath.Join(dir, "ranking.json")
+	s := NewFileSnapshot(r, path, time.Hour)
+	s.Run()
+	if err := s.Terminate(); err != nil {
+		t.Fatalf("unexpected error writing snapshot, error %v", err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("unexpected temporary files left, got %d files", len(files))
+	}
+
+	restored := NewInMemory(10)
+	restored.now = func() time.Time { return now }
+	if err := NewFileSnapshot(restored, path, time.Hour).Restore(); err != nil {
+		t.Fatalf("unexpected error restoring snapshot, error %v", err)
+	}
+
+	for _, d := range dataProvider {
+		s, _ := restored.Score(context.Background(), d.city)
+		if s != d.score {
+			t.Errorf("unexpected %s score, expected %d got %d", d.city, d.score, s)
+		}
+	}
+
+	top, _ := restored.TopWindow(context.Background(), provider.WindowHour, 0, 1)
+	if len(top) != 1 || top[0].Name != "barcelona" || top[0].Score != 10 {
+		t.Errorf("unexpected restored window top, got %v", top)
+	}
+
+	top, _ = restored.TopPeriod(context.Background(), provider.PeriodOf(provider.PeriodMonth, now), 0, 1)
+	if len(top) != 1 || top[0].Name != "barcelona" || top[0].Score != 10 {
+		t.Errorf("unexpected restored period top, got %v", top)
+	}
+
+	_ = restored.IncreaseScore(context.Background(), "madrid")
+	s2, _ := restored.Score(context.Background(), "madrid")
+	if s2 != 4 {
+		t.Errorf("unexpected madrid score after restore, expected 4 got %d", s2)
+	}
+}
+
+func TestFileSnapshotRestoreKeepsTopLocationsOnSmallerRanking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ranking")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	r := NewInMemory(10)
+	for _, d := range dataProvider {
+		for i := 0; i < d.score; i++ {
+			_ = r.IncreaseScore(context.Background(), d.city)
+		}
+	}
+
+	path := filepath.Join(dir, "ranking.json")
+	if err := NewFileSnapshot(r, path, time.Hour).Write(); err != nil {
+		t.Fatalf("unexpected error writing snapshot, error %v", err)
+	}
+
+	restored := NewInMemory(2)
+	if err := NewFileSnapshot(restored, path, time.Hour).Restore(); err != nil {
+		t.Fatalf("unexpected error restoring snapshot, error %v", err)
+	}
+
+	top, _ := restored.Top(context.Background(), 0, 10)
+	if len(top) != 2 || top[0].Name != "barcelona" || top[1].Name != "london" {
+		t.Errorf("unexpected restored top, got %v", top)
+	}
+}
+
+func TestFileSnapshotRestoreOnMissingFile(t *testing.T) {
+	r := NewInMemory(10)
+	if err := NewFileSnapshot(r, filepath.Join(os.TempDir(), "missing-ranking.json"), time.Hour).Restore(); err != nil {
+		t.Errorf("unexpected error restoring missing snapshot, error %v", err)
+	}
+}
+
+func TestFileSnapshotIsNotWrittenByFollowers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ranking")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "ranking.json")
+	f := NewFileSnapshot(NewInMemory(10), path, time.Millisecond*10).WithElector(&fakeElector{})
+	f.Run()
+	time.Sleep(time.Millisecond * 50)
+	if err := f.Terminate(); err != nil {
+		t.Fatalf("unexpected error terminating snapshot, error %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("unexpected snapshot written by follower, error %v", err)
+	}
+}
+
+func TestFileSnapshotIsNotWrittenByDeposedLeaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ranking")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir, error %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "ranking.json")
+	current := NewInMemory(10)
+	_ = current.IncreaseScore(context.Background(), "barcelona")
+	if err := NewFileSnapshot(current, path, time.Hour).WithElector(&fakeElector{leader: true, fence: 2}).Terminate(); err != nil {
+		t.Fatalf("unexpected error writing snapshot, error %v", err)
+	}
+
+	// deposed leader, paused while holding its lease, resumes after new leader has written the snapshot
+	deposed := NewInMemory(10)
+	_ = deposed.IncreaseScore(context.Background(), "madrid")
+	if err := NewFileSnapshot(deposed, path, time.Hour).WithElector(&fakeElector{leader: true, fence: 1}).Terminate(); err != ErrStaleFence {
+		t.Fatalf("unexpected deposed leader write result, error %v", err)
+	}
+
+	restored := NewInMemory(10)
+	if err := NewFileSnapshot(restored, path, time.Hour).Restore(); err != nil {
+		t.Fatalf("unexpected error restoring snapshot, error %v", err)
+	}
+	if top, _ := restored.Top(context.Background(), 0, 10); len(top) != 1 || top[0].Name != "barcelona" {
+		t.Errorf("unexpected snapshot overwritten, got %v", top)
+	}
+}
+
+func TestFileSnapshotFencesDeposedLeadersWaitingOnLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranking.json")
+	current := NewInMemory(10)
+	_ = current.IncreaseScore(context.Background(), "barcelona")
+	newer := NewFileSnapshot(current, path, time.Hour)
+
+	deposed := NewInMemory(10)
+	_ = deposed.IncreaseScore(context.Background(), "madrid")
+
+	// new leader holds snapshot lock, a deposed leader passing its lease check waits for it
+	unlock, err := newer.lock()
+	if err != nil {
+		t.Fatalf("unexpected error locking snapshot, error %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewFileSnapshot(deposed, path, time.Hour).write(1)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("unexpected deposed leader write while snapshot is locked, error %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	if err := newer.fence(2); err != nil {
+		t.Fatalf("unexpected error storing fence, error %v", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("unexpected error creating snapshot, error %v", err)
+	}
+	if err := current.Save(file); err != nil {
+		t.Fatalf("unexpected error writing snapshot, error %v", err)
+	}
+	_ = file.Close()
+	unlock()
+
+	if err := <-done; err != ErrStaleFence {
+		t.Fatalf("unexpected deposed leader write result, error %v", err)
+	}
+
+	restored := NewInMemory(10)
+	if err := NewFileSnapshot(restored, path, time.Hour).Restore(); err != nil {
+		t.Fatalf("unexpected error restoring snapshot, error %v", err)
+	}
+	if top, _ := restored.Top(context.Background(), 0, 10); len(top) != 1 || top[0].Name != "barcelona" {
+		t.Errorf("unexpected snapshot overwritten, got %v", top)
+	}
+}
+
+type fakeElector struct {
+	leader bool
+	fence  int64
+}
+
+func (f *fakeElector) Leader() (int64, bool) {
+	return f.fence, f.leader
+}
@@ -0,0 +1,250 @@
+package ranking
+
+import (
+	"container/heap"
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sync"
+)
+
+// SpaceSaving implements a fixed memory heavy hitters ranking using Space-Saving algorithm over k counters.
+// Once full, an unmonitored city replaces the minimum counter, inheriting its count as overestimation error.
+// Being N the total searches:
+//   - estimated counts never underestimate, and overestimate up to N/k (count - error <= real <= count)
+//   - every city searched more than N/k times is monitored
+type SpaceSaving struct {
+	counters counterHeap
+	index    map[string]*counter
+	k        int
+	total    int
+	mutex    sync.RWMutex
+}
+
+// NewSpaceSaving instantiates heavy hitters ranking with k counters
+func NewSpaceSaving(k int) *SpaceSaving {
+	return &SpaceSaving{
+		counters: make(counterHeap, 0, k),
+		index:    make(map[string]*counter, k),
+		k:        k,
+	}
+}
+
+// IncreaseScore counts a city search, minimum counter is replaced on unmonitored cities when full
+func (s *SpaceSaving) IncreaseScore(_ context.Context, city string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.increase(city, 1)
+
+	return nil
+}
+
+// IncreaseScores counts each city searches under a single lock
+func (s *SpaceSaving) IncreaseScores(_ context.Context, counts map[string]int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for city, n := range counts {
+		s.increase(city, n)
+	}
+
+	return nil
+}
+
+// Top returns sorted estimated ranking, up to limit locations from offset
+func (s *SpaceSaving) Top(_ context.Context, offset, limit int) ([]*provider.Location, error) {
+	s.mutex.RLock()
+	res := make([]*provider.Location, 0, len(s.counters))
+	for _, c := range s.counters {
+		res = append(res, &provider.Location{Name: c.city, Score: c.count})
+	}
+	s.mutex.RUnlock()
+
+	sortLocations(res)
+
+	return page(res, offset, limit), nil
+}
+
+// TopWindow is not supported on heavy hitters ranking
+func (s *SpaceSaving) TopWindow(_ context.Context, _ provider.Window, _, _ int) ([]*provider.Location, error) {
+	return nil, provider.ErrUnsupportedWindow
+}
+
+// Len returns monitored cities
+func (s *SpaceSaving) Len(_ context.Context) (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return int64(len(s.counters)), nil
+}
+
+// Score returns city estimated count, zero on non monitored cities
+func (s *SpaceSaving) Score(_ context.Context, city string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	c, ok := s.index[city]
+	if !ok {
+		return 0, nil
+	}
+
+	return c.count, nil
+}
+
+// Rank returns city location, rank is the number of locations ranked before it
+func (s *SpaceSaving) Rank(_ context.Context, city string) (*provider.Location, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	c, ok := s.index[city]
+	if !ok {
+		return nil, provider.ErrLocationNotRanked
+	}
+
+	var rank int
+	for _, v := range s.counters {
+		if ranksBefore(v.city, v.count, c.city, c.count) {
+			rank++
+		}
+	}
+
+	return &provider.Location{Name: city, Score: c.count, Index: rank}, nil
+}
+
+// Remove frees city counter, total searches are kept so that error bound still holds
+func (s *SpaceSaving) Remove(_ context.Context, city string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, ok := s.index[city]
+	if !ok {
+		return provider.ErrLocationNotRanked
+	}
+	heap.Remove(&s.counters, c.index)
+	delete(s.index, city)
+
+	return nil
+}
+
+// Reset frees all counters
+func (s *SpaceSaving) Reset(_ context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.counters = make(counterHeap, 0, s.k)
+	s.index = make(map[string]*counter, s.k)
+	s.total = 0
+
+	return nil
+}
+
+// Merge adds from city count and error to city counter, from counter is reused if city is not monitored
+func (s *SpaceSaving) Merge(_ context.Context, from, to string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f, ok := s.index[from]
+	if !ok {
+		return provider.ErrLocationNotRanked
+	}
+	delete(s.index, from)
+
+	c, ok := s.index[to]
+	if !ok {
+		f.city = to
+		s.index[to] = f
+
+		return nil
+	}
+
+	heap.Remove(&s.counters, f.index)
+	c.count += f.count
+	c.err += f.err
+	heap.Fix(&s.counters, c.index)
+
+	return nil
+}
+
+// Bounds returns city estimated count and its guaranteed lower bound
+func (s *SpaceSaving) Bounds(city string) (count, guaranteed int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	c, ok := s.index[city]
+	if !ok {
+		return 0, 0
+	}
+
+	return c.count, c.count - c.err
+}
+
+// MaxError returns current overestimation bound, N/k
+func (s *SpaceSaving) MaxError() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.total / s.k
+}
+
+// increase adds n searches to city counter, replacing minimum counter on unmonitored cities when full
+func (s *SpaceSaving) increase(city string, n int) {
+	s.total += n
+	if c, ok := s.index[city]; ok {
+		c.count += n
+		heap.Fix(&s.counters, c.index)
+
+		return
+	}
+
+	if len(s.counters) < s.k {
+		c := &counter{city: city, count: n}
+		heap.Push(&s.counters, c)
+		s.index[city] = c
+
+		return
+	}
+
+	c := s.counters[0]
+	delete(s.index, c.city)
+	c.city = city
+	c.err = c.count
+	c.count += n
+	s.index[city] = c
+	heap.Fix(&s.counters, 0)
+}
+
+type counter struct {
+	city  string
+	count int
+	err   int
+	index int
+}
+
+// counterHeap implements a min heap of counters
+type counterHeap []*counter
+
+func (h counterHeap) Len() int { return len(h) }
+
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+
+	return c
+}
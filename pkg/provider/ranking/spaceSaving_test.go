@@ -0,0 +1,148 @@
+package ranking
+
+import (
+	"context"
+	"fmt"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// zipfStream generates a skewed searches stream and its exact counts
+func zipfStream(total, cities int) ([]string, map[string]int) {
+	rnd := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(rnd, 1.2, 1, uint64(cities-1))
+
+	stream := make([]string, total)
+	exact := make(map[string]int)
+	for i := range stream {
+		city := fmt.Sprintf("city_%d", z.Uint64())
+		stream[i] = city
+		exact[city]++
+	}
+
+	return stream, exact
+}
+
+func TestSpaceSavingBoundsAgainstExactCounts(t *testing.T) {
+	total, k := 100000, 100
+	stream, exact := zipfStream(total, 5000)
+
+	s := NewSpaceSaving(k)
+	for _, city := range stream {
+		_ = s.IncreaseScore(context.Background(), city)
+	}
+
+	maxErr := s.MaxError()
+	if maxErr != total/k {
+		t.Fatalf("unexpected max error, expected %d got %d", total/k, maxErr)
+	}
+
+	top, _ := s.Top(context.Background(), 0, k)
+	if len(top) != k {
+		t.Fatalf("unexpected monitored cities, expected %d got %d", k, len(top))
+	}
+
+	for _, l := range top {
+		count, guaranteed := s.Bounds(l.Name)
+		real := exact[l.Name]
+		if guaranteed > real || real > count {
+			t.Errorf("unexpected %s bounds, real %d out of [%d, %d]", l.Name, real, guaranteed, count)
+		}
+
+		if count-real > maxErr {
+			t.Errorf("unexpected %s overestimation %d above %d", l.Name, count-real, maxErr)
+		}
+	}
+
+	for city, real := range exact {
+		if real <= maxErr {
+			continue
+		}
+
+		if c, _ := s.Score(context.Background(), city); c == 0 {
+			t.Errorf("expected heavy hitter %s with %d searches monitored", city, real)
+		}
+	}
+}
+
+func TestSpaceSavingTopMatchesExactTop(t *testing.T) {
+	stream, exact := zipfStream(100000, 5000)
+
+	s := NewSpaceSaving(100)
+	for _, city := range stream {
+		_ = s.IncreaseScore(context.Background(), city)
+	}
+
+	cities := make([]string, 0, len(exact))
+	for city := range exact {
+		cities = append(cities, city)
+	}
+	sort.Slice(cities, func(i, j int) bool { return exact[cities[i]] > exact[cities[j]] })
+
+	topSize := 10
+	top, err := s.Top(context.Background(), 0, topSize)
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+
+	for i, l := range top {
+		if l.Name != cities[i] {
+			t.Errorf("unexpected location at %d, expected %s got %s", i, cities[i], l.Name)
+		}
+	}
+}
+
+func TestSpaceSavingKeepsPopularCityOnNewCitiesFlood(t *testing.T) {
+	s := NewSpaceSaving(3)
+	for i := 0; i < 10; i++ {
+		_ = s.IncreaseScore(context.Background(), "barcelona")
+	}
+
+	// 20 searches over 3 counters, cities above 6 searches are guaranteed
+	for i := 0; i < 10; i++ {
+		_ = s.IncreaseScore(context.Background(), fmt.Sprintf("city_%d", i))
+	}
+
+	l, err := s.Rank(context.Background(), "barcelona")
+	if err != nil {
+		t.Fatalf("unexpected error getting rank, error %v", err)
+	}
+
+	if l.Score != 10 {
+		t.Errorf("unexpected popular city score, expected 10 got %d", l.Score)
+	}
+}
+
+func TestSpaceSavingRemoveAndMerge(t *testing.T) {
+	s := NewSpaceSaving(3)
+	for city, n := range map[string]int{"barcelona": 10, "barcelone": 3, "madrid": 5} {
+		for i := 0; i < n; i++ {
+			_ = s.IncreaseScore(context.Background(), city)
+		}
+	}
+
+	if err := s.Merge(context.Background(), "barcelone", "barcelona"); err != nil {
+		t.Fatalf("unexpected error merging cities, error %v", err)
+	}
+	if err := s.Merge(context.Background(), "madrid", "madrid, spain"); err != nil {
+		t.Fatalf("unexpected error merging cities, error %v", err)
+	}
+	if err := s.Remove(context.Background(), "barcelone"); err != provider.ErrLocationNotRanked {
+		t.Errorf("unexpected error, expected %v got %v", provider.ErrLocationNotRanked, err)
+	}
+
+	top, _ := s.Top(context.Background(), 0, 5)
+	if len(top) != 2 || top[0].Name != "barcelona" || top[0].Score != 13 || top[1].Name != "madrid, spain" || top[1].Score != 5 {
+		t.Fatalf("unexpected merged top, got %v", top)
+	}
+
+	if err := s.Remove(context.Background(), "barcelona"); err != nil {
+		t.Fatalf("unexpected error removing city, error %v", err)
+	}
+	_ = s.IncreaseScore(context.Background(), "london")
+	if n, _ := s.Len(context.Background()); n != 2 {
+		t.Errorf("unexpected monitored cities, expected 2 got %d", n)
+	}
+}
@@ -0,0 +1,426 @@
+package ranking
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// TrendingSortedSetKey stores normalized trending scores
+	TrendingSortedSetKey = "trending-ranking"
+	// DefaultHalfLife defines time where a search weight halves
+	DefaultHalfLife = time.Hour * 24
+	// maxDecayExponent bounds normalized weights, epoch is rebased once reached so that float64 scores do not overflow
+	maxDecayExponent = 512
+	// maxTxRetries bounds optimistic transaction retries
+	maxTxRetries = 10
+)
+
+// decay applies exponential time decay using score normalization: each search adds 2^((t-epoch)/halfLife)
+// to its stored score, so stored scores never need rewrites, real scores are stored ones scaled by now weight.
+// Epoch is moved forward, rescaling stored scores, just once every maxDecayExponent half lives.
+type decay struct {
+	halfLife time.Duration
+	now      func() time.Time
+}
+
+func newDecay(halfLife time.Duration) decay {
+	if halfLife <= 0 {
+		halfLife = DefaultHalfLife
+	}
+
+	return decay{
+		halfLife: halfLife,
+		now:      time.Now,
+	}
+}
+
+// exponent returns elapsed half lives since epoch
+func (d decay) exponent(epoch, t time.Time) float64 {
+	return float64(t.Sub(epoch)) / float64(d.halfLife)
+}
+
+// score returns decayed score from normalized one
+func (d decay) score(epoch time.Time, normalized float64) int {
+	if normalized == 0 {
+		return 0
+	}
+
+	return int(math.Round(normalized / math.Exp2(d.exponent(epoch, d.now()))))
+}
+
+// rebase returns next epoch and stored scores scale factor, if required
+func (d decay) rebase(epoch, t time.Time) (time.Time, float64, bool) {
+	e := d.exponent(epoch, t)
+	if e < maxDecayExponent {
+		return epoch, 1, false
+	}
+
+	shift := math.Floor(e)
+
+	return epoch.Add(time.Duration(shift) * d.halfLife), math.Exp2(-shift), true
+}
+
+// TrendingInMemory implements a bounded inMemory trending ranking, where searches weight decays over time
+type TrendingInMemory struct {
+	decay
+	epoch   time.Time
+	scores  map[string]float64
+	maxSize int
+	mutex   sync.RWMutex
+}
+
+// NewTrendingInMemory instantiates inMemory trending ranking
+func NewTrendingInMemory(size int, halfLife time.Duration) *TrendingInMemory {
+	d := newDecay(halfLife)
+
+	return &TrendingInMemory{
+		decay:   d,
+		epoch:   d.now(),
+		scores:  make(map[string]float64),
+		maxSize: size,
+	}
+}
+
+// IncreaseScore adds a search weighted at its context time, current one by default, coldest location is evicted when full
+func (t *TrendingInMemory) IncreaseScore(ctx context.Context, city string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := provider.SearchTimeFromContext(ctx, t.now())
+	if epoch, scale, ok := t.rebase(t.epoch, now); ok {
+		for k := range t.scores {
+			t.scores[k] *= scale
+		}
+		t.epoch = epoch
+	}
+
+	if _, ok := t.scores[city]; !ok && len(t.scores) >= t.maxSize {
+		t.evict()
+	}
+	t.scores[city] += math.Exp2(t.exponent(t.epoch, now))
+
+	return nil
+}
+
+// Top returns hottest locations, up to limit locations from offset
+func (t *TrendingInMemory) Top(_ context.Context, offset, limit int) ([]*provider.Location, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	res := make([]*provider.Location, 0, len(t.scores))
+	for city, s := range t.scores {
+		res = append(res, &provider.Location{Name: city, Score: t.score(t.epoch, s)})
+	}
+	sortLocations(res)
+
+	return page(res, offset, limit), nil
+}
+
+// TopWindow is not supported, decay already favours recent searches
+func (t *TrendingInMemory) TopWindow(_ context.Context, _ provider.Window, _, _ int) ([]*provider.Location, error) {
+	return nil, provider.ErrUnsupportedWindow
+}
+
+// Len returns ranking size
+func (t *TrendingInMemory) Len(_ context.Context) (int64, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return int64(len(t.scores)), nil
+}
+
+// Score returns city decayed score
+func (t *TrendingInMemory) Score(_ context.Context, city string) (int, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.score(t.epoch, t.scores[city]), nil
+}
+
+// Rank returns city location, rank is the number of locations ranked before it
+func (t *TrendingInMemory) Rank(_ context.Context, city string) (*provider.Location, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	s, ok := t.scores[city]
+	if !ok {
+		return nil, provider.ErrLocationNotRanked
+	}
+
+	score := t.score(t.epoch, s)
+	var rank int
+	for k, v := range t.scores {
+		if ranksBefore(k, t.score(t.epoch, v), city, score) {
+			rank++
+		}
+	}
+
+	return &provider.Location{Name: city, Score: score, Index: rank}, nil
+}
+
+// Remove deletes city from ranking
+func (t *TrendingInMemory) Remove(_ context.Context, city string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.scores[city]; !ok {
+		return provider.ErrLocationNotRanked
+	}
+	delete(t.scores, city)
+
+	return nil
+}
+
+// Reset deletes all ranked cities
+func (t *TrendingInMemory) Reset(_ context.Context) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.scores = make(map[string]float64)
+
+	return nil
+}
+
+// Merge adds from city score to city and removes it, normalized scores share epoch so they can be added
+func (t *TrendingInMemory) Merge(_ context.Context, from, to string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.scores[from]
+	if !ok {
+		return provider.ErrLocationNotRanked
+	}
+	t.scores[to] += s
+	delete(t.scores, from)
+
+	return nil
+}
+
+// evict removes lowest score, normalized scores share scale so they are comparable
+func (t *TrendingInMemory) evict() {
+	var coldest string
+	lowest := math.Inf(1)
+	for city, s := range t.scores {
+		if s < lowest {
+			coldest, lowest = city, s
+		}
+	}
+	delete(t.scores, coldest)
+}
+
+// ErrTxConflict happens when optimistic transactions keep failing on concurrent epoch rebases
+var ErrTxConflict = errors.New("trending ranking transaction conflict")
+
+// TrendingRedis implements a trending ranking in top of a sorted set holding normalized scores,
+// epoch is shared by all instances on its own key, both keys share cluster slot.
+type TrendingRedis struct {
+	decay
+	client   redis.UniversalClient
+	key      string
+	epochKey string
+}
+
+// NewTrendingRedis instantiates redis trending ranking with namespaced key
+func NewTrendingRedis(cl redis.UniversalClient, prefix string, halfLife time.Duration) *TrendingRedis {
+	key := "{" + prefix + TrendingSortedSetKey + "}"
+
+	return &TrendingRedis{
+		decay:    newDecay(halfLife),
+		client:   cl,
+		key:      key,
+		epochKey: key + ":epoch",
+	}
+}
+
+// IncreaseScore adds a search weighted at its context time, current one by default, epoch is watched so that concurrent rebases retry it
+func (r *TrendingRedis) IncreaseScore(ctx context.Context, city string) error {
+	for i := 0; i < maxTxRetries; i++ {
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			now := provider.SearchTimeFromContext(ctx, r.now())
+			epoch, err := r.epoch(ctx, tx, now)
+			if err != nil {
+				return err
+			}
+
+			next, scale, rebase := r.rebase(epoch, now)
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				if rebase {
+					p.ZUnionStore(ctx, r.key, &redis.ZStore{Keys: []string{r.key}, Weights: []float64{scale}})
+					p.Set(ctx, r.epochKey, next.UnixNano(), 0)
+				}
+				p.ZIncr(ctx, r.key, &redis.Z{
+					Score:  math.Exp2(r.exponent(next, now)),
+					Member: city,
+				})
+
+				return nil
+			})
+
+			return err
+		}, r.epochKey)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+
+	return ErrTxConflict
+}
+
+// Top returns hottest locations, up to limit locations from offset
+func (r *TrendingRedis) Top(ctx context.Context, offset, limit int) ([]*provider.Location, error) {
+	if limit <= 0 {
+		return []*provider.Location{}, nil
+	}
+
+	var epoch *redis.StringCmd
+	var top *redis.ZSliceCmd
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		epoch = p.Get(ctx, r.epochKey)
+		top = p.ZRevRangeWithScores(ctx, r.key, int64(offset), rangeStop(offset, limit))
+
+		return nil
+	})
+	if err == redis.Nil {
+		return []*provider.Location{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := parseEpoch(epoch.Val())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*provider.Location, 0, len(top.Val()))
+	for i, re := range top.Val() {
+		res = append(res, &provider.Location{
+			Name:  re.Member.(string),
+			Score: r.score(e, re.Score),
+			Index: offset + i,
+		})
+	}
+
+	return res, nil
+}
+
+// TopWindow is not supported, decay already favours recent searches
+func (r *TrendingRedis) TopWindow(_ context.Context, _ provider.Window, _, _ int) ([]*provider.Location, error) {
+	return nil, provider.ErrUnsupportedWindow
+}
+
+// Len returns ranking size
+func (r *TrendingRedis) Len(ctx context.Context) (int64, error) {
+	return r.client.ZCard(ctx, r.key).Result()
+}
+
+// Score returns city decayed score
+func (r *TrendingRedis) Score(ctx context.Context, city string) (int, error) {
+	var epoch *redis.StringCmd
+	var score *redis.FloatCmd
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		epoch = p.Get(ctx, r.epochKey)
+		score = p.ZScore(ctx, r.key, city)
+
+		return nil
+	})
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	e, err := parseEpoch(epoch.Val())
+	if err != nil {
+		return 0, err
+	}
+
+	return r.score(e, score.Val()), nil
+}
+
+// Rank returns city location from its reverse rank
+func (r *TrendingRedis) Rank(ctx context.Context, city string) (*provider.Location, error) {
+	var epoch *redis.StringCmd
+	var rank *redis.IntCmd
+	var score *redis.FloatCmd
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		epoch = p.Get(ctx, r.epochKey)
+		rank = p.ZRevRank(ctx, r.key, city)
+		score = p.ZScore(ctx, r.key, city)
+
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, provider.ErrLocationNotRanked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := parseEpoch(epoch.Val())
+	if err != nil {
+		return nil, err
+	}
+
+	return &provider.Location{Name: city, Score: r.score(e, score.Val()), Index: int(rank.Val())}, nil
+}
+
+// Remove deletes city from ranking
+func (r *TrendingRedis) Remove(ctx context.Context, city string) error {
+	n, err := r.client.ZRem(ctx, r.key, city).Result()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return provider.ErrLocationNotRanked
+	}
+
+	return nil
+}
+
+// Reset deletes ranking and its epoch, next search sets a new one
+func (r *TrendingRedis) Reset(ctx context.Context) error {
+	return r.client.Del(ctx, r.key, r.epochKey).Err()
+}
+
+// Merge adds from city normalized score to city and removes it, atomic against epoch rebases
+func (r *TrendingRedis) Merge(ctx context.Context, from, to string) error {
+	err := mergeScript.Run(ctx, r.client, []string{r.key}, from, to).Err()
+	if err == redis.Nil {
+		return provider.ErrLocationNotRanked
+	}
+
+	return err
+}
+
+// epoch returns shared epoch, first writer sets it
+func (r *TrendingRedis) epoch(ctx context.Context, tx *redis.Tx, now time.Time) (time.Time, error) {
+	if err := tx.SetNX(ctx, r.epochKey, now.UnixNano(), 0).Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	v, err := tx.Get(ctx, r.epochKey).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return parseEpoch(v)
+}
+
+func parseEpoch(v string) (time.Time, error) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, n), nil
+}
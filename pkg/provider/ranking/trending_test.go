@@ -0,0 +1,197 @@
+package ranking
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"testing"
+	"time"
+)
+
+func TestTrendingInMemoryRecentSearchesOvertakeOlderOnes(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewTrendingInMemory(10, time.Hour)
+	r.now = func() time.Time { return now }
+	r.epoch = now
+
+	for i := 0; i < 8; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+
+	now = now.Add(time.Hour * 2)
+	for i := 0; i < 3; i++ {
+		_ = r.IncreaseScore(context.Background(), "madrid")
+	}
+
+	res, err := r.Top(context.Background(), 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+
+	if len(res) != 2 {
+		t.Fatalf("unexpected top size, expected 2 got %d", len(res))
+	}
+
+	if res[0].Name != "madrid" || res[0].Score != 3 {
+		t.Errorf("unexpected top location, got %s with score %d", res[0].Name, res[0].Score)
+	}
+
+	if res[1].Name != "barcelona" || res[1].Score != 2 {
+		t.Errorf("unexpected second location, got %s with score %d", res[1].Name, res[1].Score)
+	}
+}
+
+func TestTrendingInMemoryWeighsSearchesAtContextTime(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewTrendingInMemory(10, time.Hour)
+	r.now = func() time.Time { return now }
+	r.epoch = now.Add(-time.Hour * 2)
+
+	for i := 0; i < 8; i++ {
+		_ = r.IncreaseScore(provider.WithSearchTime(context.Background(), now.Add(-time.Hour*2)), "barcelona")
+	}
+
+	s, _ := r.Score(context.Background(), "barcelona")
+	if s != 2 {
+		t.Errorf("unexpected decayed score, expected 2 got %d", s)
+	}
+}
+
+func TestTrendingInMemoryEvictsColdestLocation(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewTrendingInMemory(2, time.Hour)
+	r.now = func() time.Time { return now }
+	r.epoch = now
+
+	_ = r.IncreaseScore(context.Background(), "barcelona")
+	_ = r.IncreaseScore(context.Background(), "barcelona")
+	_ = r.IncreaseScore(context.Background(), "madrid")
+
+	now = now.Add(time.Hour)
+	_ = r.IncreaseScore(context.Background(), "london")
+
+	l, _ := r.Len(context.Background())
+	if l != 2 {
+		t.Fatalf("unexpected ranking size, expected 2 got %d", l)
+	}
+
+	s, _ := r.Score(context.Background(), "madrid")
+	if s != 0 {
+		t.Errorf("expected madrid evicted, got score %d", s)
+	}
+}
+
+func TestTrendingInMemoryRebasesEpochKeepingScores(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewTrendingInMemory(10, time.Minute)
+	r.now = func() time.Time { return now }
+	r.epoch = now
+
+	for i := 0; i < 4; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+
+	now = now.Add(time.Minute * (maxDecayExponent + 1))
+	for i := 0; i < 4; i++ {
+		_ = r.IncreaseScore(context.Background(), "madrid")
+	}
+
+	if !r.epoch.After(now.Add(-time.Minute)) {
+		t.Fatalf("expected epoch rebased, got %s", r.epoch)
+	}
+
+	s, _ := r.Score(context.Background(), "madrid")
+	if s != 4 {
+		t.Errorf("unexpected madrid score, expected 4 got %d", s)
+	}
+
+	now = now.Add(time.Minute)
+	s, _ = r.Score(context.Background(), "madrid")
+	if s != 2 {
+		t.Errorf("unexpected decayed madrid score, expected 2 got %d", s)
+	}
+}
+
+func TestTrendingRedisRecentSearchesOvertakeOlderOnes(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewTrendingRedis(cl, "test-", time.Hour)
+	r.now = func() time.Time { return now }
+	defer func() {
+		if err := cl.Del(context.Background(), r.key, r.epochKey).Err(); err != nil {
+			t.Fatalf("unexpected error removing sorted set, error %v", err)
+		}
+	}()
+
+	for i := 0; i < 8; i++ {
+		_ = r.IncreaseScore(context.Background(), "barcelona")
+	}
+
+	now = now.Add(time.Hour * 2)
+	for i := 0; i < 3; i++ {
+		_ = r.IncreaseScore(context.Background(), "madrid")
+	}
+
+	res, err := r.Top(context.Background(), 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error getting top, error %v", err)
+	}
+
+	if len(res) != 2 || res[0].Name != "madrid" || res[1].Score != 2 {
+		t.Fatalf("unexpected trending top, got %v", res)
+	}
+
+	s, err := r.Score(context.Background(), "barcelona")
+	if err != nil {
+		t.Fatalf("unexpected error getting score, error %v", err)
+	}
+	if s != 2 {
+		t.Errorf("unexpected barcelona score, expected 2 got %d", s)
+	}
+}
+
+func TestTrendingRedisRebasesEpochKeepingScores(t *testing.T) {
+	if testing.Short() {
+		log.Info("Skipping tests because of Short flag")
+		return
+	}
+
+	cl := redis.NewClient(&redis.Options{Addr: ":6379"})
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	r := NewTrendingRedis(cl, "test-rebase-", time.Minute)
+	r.now = func() time.Time { return now }
+	defer func() {
+		if err := cl.Del(context.Background(), r.key, r.epochKey).Err(); err != nil {
+			t.Fatalf("unexpected error removing keys, error %v", err)
+		}
+	}()
+
+	_ = r.IncreaseScore(context.Background(), "barcelona")
+	now = now.Add(time.Minute * (maxDecayExponent + 1))
+	if err := r.IncreaseScore(context.Background(), "madrid"); err != nil {
+		t.Fatalf("unexpected error increasing score, error %v", err)
+	}
+
+	v, err := cl.Get(context.Background(), r.epochKey).Result()
+	if err != nil {
+		t.Fatalf("unexpected error getting epoch, error %v", err)
+	}
+	e, _ := parseEpoch(v)
+	if !e.After(now.Add(-time.Minute)) {
+		t.Fatalf("expected epoch rebased, got %s", e)
+	}
+
+	s, err := r.Score(context.Background(), "madrid")
+	if err != nil {
+		t.Fatalf("unexpected error getting score, error %v", err)
+	}
+	if s != 1 {
+		t.Errorf("unexpected madrid score, expected 1 got %d", s)
+	}
+}
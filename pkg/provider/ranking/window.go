@@ -0,0 +1,142 @@
+package ranking
+
+import (
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sort"
+	"time"
+)
+
+// windowCounter keeps city scores by time bucket on a sliding window, each bucket is bounded to size
+// top cities (see trimScores), so window scores are approximated for cities out of the bucket tops
+type windowCounter struct {
+	window  provider.Window
+	size    int
+	buckets map[int64]map[string]int
+}
+
+func newWindowCounter(w provider.Window, size int) *windowCounter {
+	return &windowCounter{
+		window:  w,
+		size:    size,
+		buckets: make(map[int64]map[string]int),
+	}
+}
+
+// increase adds n to city score on current bucket, buckets out of window are expired
+func (c *windowCounter) increase(city string, n int, now time.Time) {
+	id := c.window.Bucket(now)
+	b, ok := c.buckets[id]
+	if !ok {
+		b = make(map[string]int)
+		c.buckets[id] = b
+	}
+	b[city] += n
+	trimScores(b, c.size)
+
+	c.expire(now)
+}
+
+func (c *windowCounter) expire(now time.Time) {
+	oldest := c.window.Buckets(now)[0]
+	for id := range c.buckets {
+		if id < oldest {
+			delete(c.buckets, id)
+		}
+	}
+}
+
+// remove deletes city from all buckets
+func (c *windowCounter) remove(city string) {
+	for _, b := range c.buckets {
+		delete(b, city)
+	}
+}
+
+// merge moves from city scores to city on each bucket
+func (c *windowCounter) merge(from, to string) {
+	for _, b := range c.buckets {
+		if s, ok := b[from]; ok {
+			b[to] += s
+			delete(b, from)
+		}
+	}
+}
+
+// reset deletes all buckets
+func (c *windowCounter) reset() {
+	c.buckets = make(map[int64]map[string]int)
+}
+
+// top aggregates window buckets at now
+func (c *windowCounter) top(now time.Time, offset, limit int) []*provider.Location {
+	scores := make(map[string]int)
+	for _, id := range c.window.Buckets(now) {
+		for city, s := range c.buckets[id] {
+			scores[city] += s
+		}
+	}
+
+	res := make([]*provider.Location, 0, len(scores))
+	for city, s := range scores {
+		res = append(res, &provider.Location{Name: city, Score: s})
+	}
+	sortLocations(res)
+
+	return page(res, offset, limit)
+}
+
+// trimScores drops lowest scored cities down to size once scores hold twice as many, so that trimming cost
+// is amortized, non positive size means unbounded
+func trimScores(scores map[string]int, size int) {
+	if size <= 0 || len(scores) <= 2*size {
+		return
+	}
+
+	l := make([]*provider.Location, 0, len(scores))
+	for city, s := range scores {
+		l = append(l, &provider.Location{Name: city, Score: s})
+	}
+	sortLocations(l)
+
+	for _, v := range l[size:] {
+		delete(scores, v.Name)
+	}
+}
+
+// sortLocations sorts by score, ties sorted by name descending as redis sorted sets reverse ranges, indexes updated
+func sortLocations(l []*provider.Location) {
+	sort.Slice(l, func(i, j int) bool {
+		return ranksBefore(l[i].Name, l[i].Score, l[j].Name, l[j].Score)
+	})
+
+	for i, v := range l {
+		v.Index = i
+	}
+}
+
+// ranksBefore returns if location a ranks before b
+func ranksBefore(aName string, aScore int, bName string, bScore int) bool {
+	if aScore != bScore {
+		return aScore > bScore
+	}
+
+	return aName > bName
+}
+
+// page returns sorted locations slice from offset up to limit
+func page(l []*provider.Location, offset, limit int) []*provider.Location {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if offset >= len(l) || limit <= 0 {
+		return []*provider.Location{}
+	}
+
+	end := len(l)
+	if limit < end-offset {
+		end = offset + limit
+	}
+
+	return l[offset:end]
+}
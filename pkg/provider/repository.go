@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"github.com/go-kit/kit/ratelimit"
 	"github.com/marcosQuesada/githubTop/pkg/log"
 	"github.com/upgear/go-kit/retry"
 	"sync"
@@ -39,6 +40,8 @@ type GithubTopRequest struct {
 	Size    int
 	Version string
 	Sort    string
+	// Aliases are searched along with city
+	Aliases []string
 }
 
 // GithubResult defines github contributors top query result
@@ -54,6 +57,8 @@ type HttpConfig struct {
 	Timeout         time.Duration
 	Retries         int
 	RateLimitConfig RateLimitConfig
+	// Limiter replaces local rate limiter built from RateLimitConfig if defined, as shared ones
+	Limiter ratelimit.Allower
 }
 
 // RateLimitConfig defines rate limiter config
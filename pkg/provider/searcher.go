@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// AnonymousSearcher identifies searches from unknown clients
+const AnonymousSearcher = "anonymous"
+
+type searcherKey struct{}
+
+// WithSearcher attaches searching client identity, token subject or ip, to context
+func WithSearcher(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, searcherKey{}, id)
+}
+
+// SearcherFromContext returns searching client identity, AnonymousSearcher if unknown
+func SearcherFromContext(ctx context.Context) string {
+	id, ok := ctx.Value(searcherKey{}).(string)
+	if !ok || id == "" {
+		return AnonymousSearcher
+	}
+
+	return id
+}
+
+type searchTimeKey struct{}
+
+// WithSearchTime attaches search time to context, so that replayed searches are ranked when they happened
+func WithSearchTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, searchTimeKey{}, t)
+}
+
+// SearchTimeFromContext returns attached search time, now if none
+func SearchTimeFromContext(ctx context.Context, now time.Time) time.Time {
+	t, ok := ctx.Value(searchTimeKey{}).(time.Time)
+	if !ok || t.IsZero() {
+		return now
+	}
+
+	return t
+}
+
+type replayKey struct{}
+
+// WithReplay marks searches as replayed from search events, so that archived period leaderboards can be rewritten
+func WithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayKey{}, true)
+}
+
+// IsReplay returns if searches are replayed
+func IsReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayKey{}).(bool)
+
+	return replay
+}
+
+// Search models a counted location search, as queued by write-behind scoring
+type Search struct {
+	City     string
+	Searcher string
+}
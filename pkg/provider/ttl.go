@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// DefaultPopularityPivot defines the searches score where a location is considered half hot
+const DefaultPopularityPivot = 100
+
+// CacheEntryStats describes cached entry refresh history
+type CacheEntryStats struct {
+	Key        string        `json:"key"`
+	City       string        `json:"city"`
+	TTL        time.Duration `json:"-"`
+	TTLSeconds int64         `json:"ttl_seconds"`
+	Refreshes  int           `json:"refreshes"`
+	Changes    int           `json:"changes"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+
+	fingerprint uint64
+}
+
+// ChangeRate returns smoothed ratio of refreshes where data has changed, unknown entries are 0.5
+func (s CacheEntryStats) ChangeRate() float64 {
+	return float64(s.Changes+1) / float64(s.Refreshes+2)
+}
+
+// TTLPolicy picks cache entry expiration
+type TTLPolicy interface {
+	TTL(ctx context.Context, stats CacheEntryStats) time.Duration
+}
+
+// PopularitySource returns location search score
+type PopularitySource interface {
+	Score(ctx context.Context, city string) (int, error)
+}
+
+// AdaptiveTTLConfig defines adaptive ttl bounds
+type AdaptiveTTLConfig struct {
+	Min time.Duration
+	Max time.Duration
+
+	// Pivot defines the score where a location is considered half hot
+	Pivot int
+}
+
+// AdaptiveTTL picks shorter ttl on hot locations whose data changes often, and longer ones on cold and stable ones
+type AdaptiveTTL struct {
+	popularity PopularitySource
+	min, max   time.Duration
+	pivot      int
+}
+
+// NewAdaptiveTTL instantiates adaptive ttl policy
+func NewAdaptiveTTL(p PopularitySource, cfg AdaptiveTTLConfig) *AdaptiveTTL {
+	if cfg.Pivot <= 0 {
+		cfg.Pivot = DefaultPopularityPivot
+	}
+
+	return &AdaptiveTTL{
+		popularity: p,
+		min:        cfg.Min,
+		max:        cfg.Max,
+		pivot:      cfg.Pivot,
+	}
+}
+
+// TTL interpolates between bounds, freshness demand is the mean of popularity and change rate
+func (a *AdaptiveTTL) TTL(ctx context.Context, stats CacheEntryStats) time.Duration {
+	score, err := a.popularity.Score(ctx, stats.City)
+	if err != nil {
+		log.Errorf("unexpected error getting city score, error %v", err)
+	}
+
+	popularity := float64(score) / float64(score+a.pivot)
+	demand := (popularity + stats.ChangeRate()) / 2
+
+	return a.max - time.Duration(float64(a.max-a.min)*demand)
+}
+
+func fingerprint(c []*Contributor) uint64 {
+	h := fnv.New64a()
+	for _, v := range c {
+		if v == nil {
+			continue
+		}
+		_, _ = h.Write([]byte(strconv.FormatInt(v.ID, 10)))
+		_, _ = h.Write([]byte{0})
+	}
+
+	return h.Sum64()
+}
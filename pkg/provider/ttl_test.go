@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTTLPicksShorterTTLOnHotAndChangingLocations(t *testing.T) {
+	p := &fakePopularity{scores: map[string]int{"barcelona": 10000, "badalona": 0}}
+	a := NewAdaptiveTTL(p, AdaptiveTTLConfig{Min: time.Hour, Max: time.Hour * 72})
+
+	hot := a.TTL(context.Background(), CacheEntryStats{City: "barcelona", Refreshes: 20, Changes: 20})
+	cold := a.TTL(context.Background(), CacheEntryStats{City: "badalona", Refreshes: 20, Changes: 0})
+	unknown := a.TTL(context.Background(), CacheEntryStats{City: "badalona"})
+
+	if hot >= unknown || unknown >= cold {
+		t.Errorf("unexpected ttl order, hot %s unknown %s cold %s", hot, unknown, cold)
+	}
+
+	for _, ttl := range []time.Duration{hot, cold, unknown} {
+		if ttl < time.Hour || ttl > time.Hour*72 {
+			t.Errorf("ttl %s out of bounds", ttl)
+		}
+	}
+}
+
+func TestCacheMiddlewareTracksRefreshesAndChosenTTL(t *testing.T) {
+	ch := &fakeCache{}
+	repo := &fakeRepository{contributors: []*Contributor{{ID: 1}, {ID: 2}}}
+	p := &fakePopularity{scores: map[string]int{}}
+	r := NewCacheMiddlewareWithConfig(ch, repo, CacheMiddlewareConfig{
+		TTLPolicy: NewAdaptiveTTL(p, AdaptiveTTLConfig{Min: time.Hour, Max: time.Hour * 72}),
+	})
+
+	req := GithubTopRequest{City: "barcelona", Size: 2, Version: APIv1}
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			repo.contributors = []*Contributor{{ID: 2}, {ID: 1}}
+		}
+		if _, err := r.GetGithubTopContributors(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error getting contributors, err: %s", err.Error())
+		}
+	}
+
+	stats := r.Stats(context.Background())
+	if len(stats) != 1 {
+		t.Fatalf("unexpected stats size, expected 1 got %d", len(stats))
+	}
+
+	if stats[0].Refreshes != 2 || stats[0].Changes != 1 {
+		t.Errorf("unexpected refresh history, refreshes %d changes %d", stats[0].Refreshes, stats[0].Changes)
+	}
+
+	if stats[0].TTL != ch.ttl {
+		t.Errorf("unexpected ttl, expected %s got %s", ch.ttl, stats[0].TTL)
+	}
+}
+
+func TestCacheMiddlewareBoundsTrackedStats(t *testing.T) {
+	repo := &fakeRepository{contributors: []*Contributor{{ID: 1}}}
+	r := NewCacheMiddlewareWithConfig(&fakeCache{}, repo, CacheMiddlewareConfig{MaxStats: 2})
+
+	for _, city := range []string{"barcelona", "madrid", "valencia"} {
+		req := GithubTopRequest{City: city, Size: 2, Version: APIv1}
+		if _, err := r.GetGithubTopContributors(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error getting contributors, err: %s", err.Error())
+		}
+	}
+
+	stats := r.Stats(context.Background())
+	if len(stats) != 2 || stats[0].City != "madrid" || stats[1].City != "valencia" {
+		t.Errorf("unexpected tracked stats, got %d entries", len(stats))
+	}
+}
+
+type fakePopularity struct {
+	scores map[string]int
+}
+
+func (f *fakePopularity) Score(_ context.Context, city string) (int, error) {
+	return f.scores[city], nil
+}
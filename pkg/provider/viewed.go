@@ -0,0 +1,91 @@
+package provider
+
+import "context"
+
+// MaxViewWeight defines first result position view weight
+const MaxViewWeight = 100
+
+// ViewWeight returns contributor view weight at zero based result position, decreasing harmonically
+// as attention does, ceil(MaxViewWeight / (position + 1)), so top results weigh 100, 50, 34... down to 1
+func ViewWeight(position int) int {
+	if position < 0 {
+		position = 0
+	}
+
+	return (MaxViewWeight + position) / (position + 1)
+}
+
+// WeightedRanking defines rankings increasing scores by arbitrary weights
+type WeightedRanking interface {
+	Ranking
+	BatchRanking
+}
+
+// RankingFactory returns ranking by scope, as per city rankings, empty scope is the global one. Missing scopes
+// are created on writes only, reads on them return false, so that reads do not take room from ranked scopes.
+type RankingFactory func(scope string, create bool) (WeightedRanking, bool)
+
+// TopViewedContributorsRequest defines viewed contributors ranking query, on all cities if City is empty
+type TopViewedContributorsRequest struct {
+	City   string
+	Offset int
+	Size   int
+}
+
+// ViewedContributor models contributors ranked by weighted views
+type ViewedContributor struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Index int    `json:"index"`
+}
+
+// ContributorRanking ranks contributors each time they are served, weighted by result position,
+// on all cities and by city
+type ContributorRanking struct {
+	rankings RankingFactory
+}
+
+// NewContributorRanking wraps scoped rankings persistence
+func NewContributorRanking(f RankingFactory) *ContributorRanking {
+	return &ContributorRanking{
+		rankings: f,
+	}
+}
+
+// IncreaseViews scores served contributors by its position, on global and city rankings
+func (c *ContributorRanking) IncreaseViews(ctx context.Context, city string, contributors []*Contributor) error {
+	weights := make(map[string]int, len(contributors))
+	for i, co := range contributors {
+		weights[co.Name] += ViewWeight(i)
+	}
+
+	global, _ := c.rankings("", true)
+	if err := global.IncreaseScores(ctx, weights); err != nil {
+		return err
+	}
+
+	scoped, _ := c.rankings(city, true)
+
+	return scoped.IncreaseScores(ctx, weights)
+}
+
+// GetTopViewedContributors returns ranking page with "size" from "offset", on all cities or on request city,
+// empty on non ranked cities
+func (c *ContributorRanking) GetTopViewedContributors(ctx context.Context, r TopViewedContributorsRequest) ([]*ViewedContributor, error) {
+	ranking, ok := c.rankings(r.City, false)
+	if !ok {
+		return []*ViewedContributor{}, nil
+	}
+
+	top, err := ranking.Top(ctx, r.Offset, r.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*ViewedContributor, 0, len(top))
+	for _, l := range top {
+		res = append(res, &ViewedContributor{Name: l.Name, Score: l.Score, Index: l.Index})
+	}
+
+	return res, nil
+}
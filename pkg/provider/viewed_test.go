@@ -0,0 +1,12 @@
+package provider
+
+import "testing"
+
+func TestViewWeightDecreasesByPosition(t *testing.T) {
+	expected := map[int]int{0: 100, 1: 50, 2: 34, 3: 25, 99: 1, 149: 1}
+	for pos, w := range expected {
+		if got := ViewWeight(pos); got != w {
+			t.Errorf("Unexpected weight at position %d, expected %d got %d", pos, w, got)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/service"
+	"net/http"
+)
+
+// CacheSnapshotResponse defines cache import response
+type CacheSnapshotResponse struct {
+	Imported int
+}
+
+// MergeLocationsRequest defines ranking merge request, from location scores are moved to to location
+type MergeLocationsRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// adminHandler filters requests without a valid admin access token, so next handler is not called
+func (s *Server) adminHandler(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if c, err := r.Cookie(service.TokenName); err == nil {
+			token = c.Value
+		}
+
+		valid, err := s.authSvc.IsValidToken(r.Context(), token)
+		if err != nil || !valid {
+			errorEncoder(r.Context(), service.ErrUnauthorized, w)
+			return
+		}
+
+		admin, err := s.authSvc.IsAdminToken(r.Context(), token)
+		if err != nil || !admin {
+			errorEncoder(r.Context(), service.ErrUnauthorized, w)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// exportCacheHandler streams cache snapshot as newline delimited json
+func (s *Server) exportCacheHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	n, err := s.svc.ExportCache(r.Context(), w)
+	if err != nil {
+		log.Errorf("Unexpected error exporting cache, err %s", err)
+		// snapshot streaming has not started, so error can still be encoded
+		if n == 0 {
+			errorEncoder(r.Context(), err, w)
+		}
+		return
+	}
+
+	log.Infof("Cache snapshot exported, total entries %d", n)
+}
+
+// importCacheHandler restores cache snapshot from request body
+func (s *Server) importCacheHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	n, err := s.svc.ImportCache(r.Context(), r.Body)
+	if err != nil {
+		log.Errorf("Unexpected error importing cache, err %s", err)
+		errorEncoder(r.Context(), err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(CacheSnapshotResponse{Imported: n})
+}
+
+// removeLocationHandler deletes path city from searched locations rankings
+func (s *Server) removeLocationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.svc.RemoveLocation(r.Context(), mux.Vars(r)["city"]); err != nil {
+		log.Errorf("Unexpected error removing location, err %s", err)
+		errorEncoder(r.Context(), err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetLocationsHandler deletes all searched locations rankings
+func (s *Server) resetLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.svc.ResetLocations(r.Context()); err != nil {
+		log.Errorf("Unexpected error resetting locations, err %s", err)
+		errorEncoder(r.Context(), err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mergeLocationsHandler moves request from location scores to its to location
+func (s *Server) mergeLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	req := MergeLocationsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorEncoder(r.Context(), service.ErrInvalidArgument, w)
+		return
+	}
+
+	if err := s.svc.MergeLocations(r.Context(), req.From, req.To); err != nil {
+		log.Errorf("Unexpected error merging locations, err %s", err)
+		errorEncoder(r.Context(), err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminCacheSnapshotHandlersRequireValidToken(t *testing.T) {
+	s := &Server{svc: &fakeService{snapshot: "{}\n"}, authSvc: &fakeAuthService{token: "fakeToken", adminToken: "fakeToken"}}
+	svr := httptest.NewServer(s.adminHandler(s.exportCacheHandler))
+	defer svr.Close()
+
+	resp, err := http.Get(svr.URL)
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Unexpected status code, expected %d but got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestAdminHandlersRejectValidNonAdminTokens(t *testing.T) {
+	svc := &fakeService{snapshot: "{}\n"}
+	s := &Server{svc: svc, authSvc: &fakeAuthService{token: "fakeToken"}}
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/admin/cache/snapshot").Handler(s.adminHandler(s.exportCacheHandler))
+	r.Methods("POST").Path("/admin/cache/snapshot").Handler(s.adminHandler(s.importCacheHandler))
+	r.Methods("DELETE").Path("/admin/ranking/locations").Handler(s.adminHandler(s.resetLocationsHandler))
+	r.Methods("POST").Path("/admin/ranking/merge").Handler(s.adminHandler(s.mergeLocationsHandler))
+	svr := httptest.NewServer(r)
+	defer svr.Close()
+
+	for _, tt := range []struct{ method, path, body string }{
+		{"GET", "/admin/cache/snapshot", ""},
+		{"POST", "/admin/cache/snapshot", `{"key":"bar"}`},
+		{"DELETE", "/admin/ranking/locations", ""},
+		{"POST", "/admin/ranking/merge", `{"from":"barcelone","to":"barcelona"}`},
+	} {
+		req, _ := http.NewRequest(tt.method, svr.URL+tt.path, strings.NewReader(tt.body))
+		req.AddCookie(&http.Cookie{Name: "AccessToken", Value: "fakeToken"})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected response error, err %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Unexpected status code on %s %s, expected %d but got %d", tt.method, tt.path, http.StatusForbidden, resp.StatusCode)
+		}
+	}
+
+	if len(svc.admin) != 0 || svc.snapshot != "{}\n" {
+		t.Errorf("Unexpected admin operations, got %v snapshot %s", svc.admin, svc.snapshot)
+	}
+}
+
+func TestAdminCacheSnapshotExportAndImport(t *testing.T) {
+	svc := &fakeService{snapshot: `{"key":"foo"}` + "\n"}
+	s := &Server{svc: svc, authSvc: &fakeAuthService{token: "fakeToken", adminToken: "fakeToken"}}
+	cookie := &http.Cookie{Name: "AccessToken", Value: "fakeToken"}
+
+	export := httptest.NewServer(s.adminHandler(s.exportCacheHandler))
+	defer export.Close()
+
+	req, _ := http.NewRequest("GET", export.URL, nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if string(body) != svc.snapshot {
+		t.Errorf("Unexpected snapshot, got %s", body)
+	}
+
+	imp := httptest.NewServer(s.adminHandler(s.importCacheHandler))
+	defer imp.Close()
+
+	req, _ = http.NewRequest("POST", imp.URL, strings.NewReader(`{"key":"bar"}`))
+	req.AddCookie(cookie)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code, got %d", resp.StatusCode)
+	}
+
+	if svc.snapshot != `{"key":"bar"}` {
+		t.Errorf("Unexpected imported snapshot, got %s", svc.snapshot)
+	}
+}
+
+func TestAdminRankingHandlers(t *testing.T) {
+	svc := &fakeService{}
+	s := &Server{svc: svc, authSvc: &fakeAuthService{token: "fakeToken", adminToken: "fakeToken"}}
+	r := mux.NewRouter()
+	r.Methods("DELETE").Path("/admin/ranking/locations").Handler(s.adminHandler(s.resetLocationsHandler))
+	r.Methods("DELETE").Path("/admin/ranking/locations/{city}").Handler(s.adminHandler(s.removeLocationHandler))
+	r.Methods("POST").Path("/admin/ranking/merge").Handler(s.adminHandler(s.mergeLocationsHandler))
+	svr := httptest.NewServer(r)
+	defer svr.Close()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+	}{
+		{"DELETE", "/admin/ranking/locations/barcelona", "", "invalidToken", http.StatusForbidden},
+		{"DELETE", "/admin/ranking/locations/barcelona", "", "fakeToken", http.StatusNoContent},
+		{"DELETE", "/admin/ranking/locations/madrid", "", "fakeToken", http.StatusNotFound},
+		{"DELETE", "/admin/ranking/locations", "", "fakeToken", http.StatusNoContent},
+		{"POST", "/admin/ranking/merge", `{"from":"barcelone","to":"barcelona"}`, "fakeToken", http.StatusNoContent},
+		{"POST", "/admin/ranking/merge", `{"from":"barcelona","to":"barcelona"}`, "fakeToken", http.StatusBadRequest},
+		{"POST", "/admin/ranking/merge", `{"from"`, "fakeToken", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, svr.URL+tt.path, strings.NewReader(tt.body))
+		req.AddCookie(&http.Cookie{Name: "AccessToken", Value: tt.token})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected response error, err %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("Unexpected status code on %s %s, expected %d but got %d", tt.method, tt.path, tt.status, resp.StatusCode)
+		}
+	}
+
+	expected := []string{"remove barcelona", "reset", "merge barcelone barcelona"}
+	if strings.Join(svc.admin, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected admin operations, got %v", svc.admin)
+	}
+}
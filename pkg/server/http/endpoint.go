@@ -9,6 +9,7 @@ import (
 	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"github.com/marcosQuesada/githubTop/pkg/service"
 	"net/http"
+	"time"
 )
 
 func (s *Server) makeTopContributorsHandler(svc Service, namespace string) http.Handler {
@@ -30,9 +31,52 @@ func (s *Server) makeTopSearchedLocationsHandler(svc Service, namespace string)
 	return s.makeSearchedLocationsTransport(e, namespace, "top_searched_locations")
 }
 
-func (s *Server) makeTopContributorsTransport(e endpoint.Endpoint, namespace, metricKey string) http.Handler {
+func (s *Server) makeLocationRankHandler(svc Service, namespace string) http.Handler {
+	opts := []httptransport.ServerOption{httptransport.ServerErrorEncoder(errorEncoder)}
+
+	return httptransport.NewServer(
+		buildMiddleware(namespace, "location_rank", makeLocationRankEndpoint(svc)),
+		locationRankRequestDecoder,
+		responseEncoder,
+		opts...,
+	)
+}
+
+func (s *Server) makeTrendingLocationsHandler(svc Service, namespace string) http.Handler {
+	e := makeTrendingLocationsEndpoint(svc)
+
+	return s.makeSearchedLocationsTransport(e, namespace, "trending_locations")
+}
+
+func (s *Server) makeTopViewedContributorsHandler(svc Service, namespace string) http.Handler {
+	opts := []httptransport.ServerOption{httptransport.ServerErrorEncoder(errorEncoder)}
+
+	return httptransport.NewServer(
+		buildMiddleware(namespace, "top_viewed_contributors", makeTopViewedContributorsEndpoint(svc)),
+		topViewedContributorsRequestDecoder,
+		responseEncoder,
+		opts...,
+	)
+}
+
+func (s *Server) makeCacheStatsHandler(svc Service, namespace string) http.Handler {
 	opts := []httptransport.ServerOption{httptransport.ServerErrorEncoder(errorEncoder)}
 
+	return httptransport.NewServer(
+		buildMiddleware(namespace, "cache_stats", makeCacheStatsEndpoint(svc)),
+		httptransport.NopRequestDecoder,
+		responseEncoder,
+		opts...,
+	)
+}
+
+func (s *Server) makeTopContributorsTransport(e endpoint.Endpoint, namespace, metricKey string) http.Handler {
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(errorEncoder),
+		httptransport.ServerBefore(cacheStatusTracker, s.searcherTracker),
+		httptransport.ServerAfter(cacheStatusHeader),
+	}
+
 	return httptransport.NewServer(
 		buildMiddleware(namespace, metricKey, e),
 		topContributorsRequestDecoder,
@@ -75,6 +119,10 @@ func makeTopContributorsEndpoint(svc Service) endpoint.Endpoint {
 			Version: req.APIv,
 			Sort:    req.Sort,
 		}
+		if !req.At.IsZero() {
+			return topContributorsAt(ctx, svc, r, req.At)
+		}
+
 		c, err := svc.GetTopContributors(ctx, r)
 		if err != nil {
 			log.Errorf("Unexpected error getting Top contributors, err %s", err)
@@ -84,6 +132,17 @@ func makeTopContributorsEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+// topContributorsAt returns past top snapshot
+func topContributorsAt(ctx context.Context, svc Service, r provider.GithubTopRequest, at time.Time) (interface{}, error) {
+	s, err := svc.GetTopContributorsAt(ctx, r, at)
+	if err != nil {
+		log.Errorf("Unexpected error getting past Top contributors, err %s", err)
+		return TopContributorsResponse{}, err
+	}
+
+	return TopContributorsResponse{Top: s.Contributors, FetchedAt: &s.FetchedAt}, nil
+}
+
 func makeAuthEndpoint(s service.AuthService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AuthRequest)
@@ -103,7 +162,13 @@ func makeTopSearchedLocationsEndpoint(svc Service) endpoint.Endpoint {
 			return nil, errors.New("unexpected request type")
 		}
 
-		c, err := svc.GetTopSearchedLocations(ctx, req.Size)
+		c, err := svc.GetTopSearchedLocations(ctx, provider.TopLocationsRequest{
+			Offset: req.Offset,
+			Size:   req.Size,
+			Window: req.Window,
+			Period: req.Period,
+			Mode:   req.Mode,
+		})
 		if err != nil {
 			log.Errorf("Unexpected error getting Top contributors, err %s", err)
 		}
@@ -111,3 +176,66 @@ func makeTopSearchedLocationsEndpoint(svc Service) endpoint.Endpoint {
 		return TopSearchedLocationsResponse{Top: c}, err
 	}
 }
+
+func makeLocationRankEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(LocationRankRequest)
+		if !ok {
+			return nil, errors.New("unexpected request type")
+		}
+
+		l, err := svc.GetLocationRank(ctx, req.City)
+		if err != nil {
+			log.Errorf("Unexpected error getting location rank, err %s", err)
+		}
+
+		return LocationRankResponse{Location: l}, err
+	}
+}
+
+func makeTrendingLocationsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(TopSearchedLocationsRequest)
+		if !ok {
+			return nil, errors.New("unexpected request type")
+		}
+
+		c, err := svc.GetTrendingLocations(ctx, provider.TopLocationsRequest{Offset: req.Offset, Size: req.Size, Window: req.Window, Period: req.Period})
+		if err != nil {
+			log.Errorf("Unexpected error getting trending locations, err %s", err)
+		}
+
+		return TopSearchedLocationsResponse{Top: c}, err
+	}
+}
+
+func makeTopViewedContributorsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(TopViewedContributorsRequest)
+		if !ok {
+			return nil, errors.New("unexpected request type")
+		}
+
+		c, err := svc.GetTopViewedContributors(ctx, provider.TopViewedContributorsRequest{
+			City:   req.City,
+			Offset: req.Offset,
+			Size:   req.Size,
+		})
+		if err != nil {
+			log.Errorf("Unexpected error getting top viewed contributors, err %s", err)
+		}
+
+		return TopViewedContributorsResponse{Top: c}, err
+	}
+}
+
+func makeCacheStatsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		c, err := svc.GetCacheStats(ctx)
+		if err != nil {
+			log.Errorf("Unexpected error getting cache stats, err %s", err)
+		}
+
+		return CacheStatsResponse{Entries: c}, err
+	}
+}
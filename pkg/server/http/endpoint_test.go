@@ -6,14 +6,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/gorilla/mux"
 	"github.com/marcosQuesada/githubTop/pkg/log"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"github.com/marcosQuesada/githubTop/pkg/service"
 	"github.com/prometheus/client_golang/prometheus"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestEndpointDevelopmentFlow(t *testing.T) {
@@ -119,6 +123,81 @@ func TestOnEndpointErrorResponseHasErrorEncodedAsResponseBody(t *testing.T) {
 	}
 }
 
+func TestTopContributorsResponseReportsCacheStatus(t *testing.T) {
+	s := &Server{}
+	svc := &fakeService{cacheStatus: provider.CacheNegativeHit}
+	h := s.makeTopContributorsHandler(svc, "fakeApp")
+	svr := httptest.NewServer(h)
+
+	defer func() {
+		svr.Close()
+		// Clean metrics registry on test done
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("%s?city=barcelonaa&size=50", svr.URL))
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if h := resp.Header.Get(CacheStatusHeader); h != string(provider.CacheNegativeHit) {
+		t.Errorf("Unexpected cache status header, got %s", h)
+	}
+}
+
+func TestTopContributorsAtServesPastSnapshots(t *testing.T) {
+	s := &Server{}
+	svc := &fakeService{}
+	h := s.makeTopContributorsHandler(svc, "fakeApp")
+	svr := httptest.NewServer(h)
+
+	defer func() {
+		svr.Close()
+		// Clean metrics registry on test done
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("%s?city=barcelona&size=50&at=2026-09-01", svr.URL))
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status code, got %d", resp.StatusCode)
+	}
+
+	if expected := time.Date(2026, time.September, 2, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond); !svc.at.Equal(expected) {
+		t.Errorf("Unexpected requested time, expected day end got %s", svc.at)
+	}
+
+	res := TopContributorsResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("Unexpected error decoding response, err %v", err)
+	}
+
+	if len(res.Top) != 1 || res.Top[0].Name != "bar" || res.FetchedAt == nil {
+		t.Errorf("Unexpected past top, got %v fetched at %v", res.Top, res.FetchedAt)
+	}
+
+	for at, code := range map[string]int{"2026-08-31": http.StatusNotFound, "yesterday": http.StatusBadRequest} {
+		resp, err := http.Get(fmt.Sprintf("%s?city=barcelona&size=50&at=%s", svr.URL, at))
+		if err != nil {
+			t.Fatalf("Unexpected response error, err %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != code {
+			t.Errorf("Unexpected status code at %s, expected %d got %d", at, code, resp.StatusCode)
+		}
+	}
+}
+
 func TestAuthEndpointWorkFlowOnValidCredentials(t *testing.T) {
 	s := &Server{}
 	svc := &fakeAuthService{}
@@ -180,7 +259,7 @@ func TestAuthEndpointWorkFlowOnValidCredentials(t *testing.T) {
 func TestMakeAuthTopContributorsHandlerOnFakeAuthServicePassingCredentialsDoesNormalFlow(t *testing.T) {
 	s := &Server{}
 	svc := &fakeService{}
-	authSvc := &fakeAuthService{"fakeToken"}
+	authSvc := &fakeAuthService{token: "fakeToken"}
 
 	h := s.makeAuthTopContributorsHandler(svc, authSvc, "fakeApp")
 	svr := httptest.NewServer(h)
@@ -246,22 +325,232 @@ func TestMakeAuthTopContributorsHandlerOnFakeAuthServicePassingCredentialsDoesNo
 	}
 }
 
+func TestTopSearchedLocationsOnWindow(t *testing.T) {
+	s := &Server{}
+	svc := &fakeService{}
+	h := s.makeTopSearchedLocationsHandler(svc, "fakeApp")
+	svr := httptest.NewServer(h)
+
+	defer func() {
+		svr.Close()
+		// Clean metrics registry on test done
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("%s?size=10&window=day", svr.URL))
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status code, expected %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	svc.mutex.RLock()
+	w := svc.window
+	svc.mutex.RUnlock()
+	if w != provider.WindowDay {
+		t.Errorf("Unexpected window, expected %s but got %s", provider.WindowDay, w)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s?size=10&window=year", svr.URL))
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected status code, expected %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s?size=10&period=2026-09", svr.URL))
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	svc.mutex.RLock()
+	p := svc.period
+	svc.mutex.RUnlock()
+	if resp.StatusCode != http.StatusOK || p != "2026-09" {
+		t.Errorf("Unexpected period response, status %d period %s", resp.StatusCode, p)
+	}
+
+	for _, q := range []string{"period=2026-9", "period=2026-09&window=day"} {
+		resp, err = http.Get(fmt.Sprintf("%s?size=10&%s", svr.URL, q))
+		if err != nil {
+			t.Fatalf("Unexpected response error, err %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Unexpected status code on %s, expected %d but got %d", q, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+func TestTopViewedContributors(t *testing.T) {
+	s := &Server{}
+	h := s.makeTopViewedContributorsHandler(&fakeService{}, "fakeApp")
+	svr := httptest.NewServer(h)
+
+	defer func() {
+		svr.Close()
+		// Clean metrics registry on test done
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	}()
+
+	tests := []struct {
+		query  string
+		status int
+		top    int
+	}{
+		{"?size=10", http.StatusOK, 2},
+		{"?size=10&city=barcelona", http.StatusOK, 1},
+		{"?size=10&offset=-1", http.StatusBadRequest, 0},
+		{"", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(svr.URL + tt.query)
+		if err != nil {
+			t.Fatalf("Unexpected response error, err %v", err)
+		}
+
+		res := TopViewedContributorsResponse{}
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("Unexpected status code on %q, expected %d but got %d", tt.query, tt.status, resp.StatusCode)
+		}
+		if len(res.Top) != tt.top {
+			t.Errorf("Unexpected top size on %q, expected %d but got %d", tt.query, tt.top, len(res.Top))
+		}
+	}
+}
+
+func TestLocationRank(t *testing.T) {
+	s := &Server{}
+	svc := &fakeService{}
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/top-searched-locations/v1/{city}").Handler(s.makeLocationRankHandler(svc, "fakeApp"))
+	svr := httptest.NewServer(r)
+
+	defer func() {
+		svr.Close()
+		// Clean metrics registry on test done
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	}()
+
+	resp, err := http.Get(svr.URL + "/top-searched-locations/v1/barcelona")
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	res := LocationRankResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("Unexpected error decoding response, err %v", err)
+	}
+
+	if res.Location == nil || res.Location.Name != "barcelona" || res.Location.Score != 1000 {
+		t.Errorf("Unexpected location rank, got %v", res.Location)
+	}
+
+	resp, err = http.Get(svr.URL + "/top-searched-locations/v1/unknown")
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Unexpected status code, expected %d but got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestTopContributorsIdentifiesSearcher(t *testing.T) {
+	s := &Server{authSvc: &fakeAuthService{token: "fakeToken"}}
+	svc := &fakeService{}
+	svr := httptest.NewServer(s.makeTopContributorsHandler(svc, "fakeApp"))
+
+	defer func() {
+		svr.Close()
+		// Clean metrics registry on test done
+		prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	}()
+
+	resp, err := http.Get(svr.URL + "?city=barcelona&size=50")
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	svc.mutex.RLock()
+	searcher := svc.searcher
+	svc.mutex.RUnlock()
+	if searcher != "ip:127.0.0.1" {
+		t.Errorf("Unexpected anonymous searcher, got %s", searcher)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL+"?city=barcelona&size=50", nil)
+	req.AddCookie(&http.Cookie{Name: service.TokenName, Value: "fakeToken"})
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected response error, err %v", err)
+	}
+	_ = resp.Body.Close()
+
+	svc.mutex.RLock()
+	searcher = svc.searcher
+	svc.mutex.RUnlock()
+	if searcher != "user:fakeUser" {
+		t.Errorf("Unexpected authenticated searcher, got %s", searcher)
+	}
+}
+
 type fakeService struct {
 	requestSize int
 	mutex       sync.RWMutex
 	err         error
+	cacheStatus provider.CacheStatus
+	snapshot    string
+	window      provider.Window
+	period      provider.Period
+	searcher    string
+	admin       []string
+	at          time.Time
 }
 
 // GetTopContributors fake method
-func (s *fakeService) GetTopContributors(_ context.Context, r provider.GithubTopRequest) ([]*provider.Contributor, error) {
+func (s *fakeService) GetTopContributors(ctx context.Context, r provider.GithubTopRequest) ([]*provider.Contributor, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	s.requestSize = r.Size
+	s.searcher = provider.SearcherFromContext(ctx)
+	provider.SetCacheStatus(ctx, s.cacheStatus)
 
 	return []*provider.Contributor{{ID: 1, Name: "foo"}}, s.err
 }
 
+// GetTopContributorsAt fake method, snapshots exist from 2026-09-01 on
+func (s *fakeService) GetTopContributorsAt(_ context.Context, r provider.GithubTopRequest, t time.Time) (*provider.TopSnapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.at = t
+	fetchedAt := time.Date(2026, time.September, 1, 10, 0, 0, 0, time.UTC)
+	if t.Before(fetchedAt) {
+		return nil, provider.ErrTopSnapshotNotFound
+	}
+
+	return &provider.TopSnapshot{City: r.City, Size: r.Size, FetchedAt: fetchedAt, Contributors: []*provider.Contributor{{ID: 2, Name: "bar"}}}, nil
+}
+
 func (s *fakeService) getRequestedSize() int {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -269,14 +558,94 @@ func (s *fakeService) getRequestedSize() int {
 	return s.requestSize
 }
 
-func (s *fakeService) GetTopSearchedLocations(_ context.Context, size int) ([]*provider.Location, error) {
+func (s *fakeService) GetTopSearchedLocations(_ context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.window = r.Window
+	s.period = r.Period
+
 	return []*provider.Location{
 		{Name: "barcelona", Score: 1000}, {Name: "badalona", Score: 10},
 	}, nil
 }
 
+func (s *fakeService) GetLocationRank(_ context.Context, city string) (*provider.Location, error) {
+	if city != "barcelona" {
+		return nil, provider.ErrLocationNotRanked
+	}
+
+	return &provider.Location{Name: "barcelona", Score: 1000, Index: 0}, nil
+}
+
+func (s *fakeService) GetTrendingLocations(_ context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error) {
+	return []*provider.Location{{Name: "madrid", Score: 10}}, nil
+}
+
+func (s *fakeService) GetTopViewedContributors(_ context.Context, r provider.TopViewedContributorsRequest) ([]*provider.ViewedContributor, error) {
+	if r.City == "" {
+		return []*provider.ViewedContributor{{Name: "foo", Score: 150}, {Name: "bar", Score: 50, Index: 1}}, nil
+	}
+
+	return []*provider.ViewedContributor{{Name: "bar", Score: 50}}, nil
+}
+
+func (s *fakeService) GetCacheStats(_ context.Context) ([]*provider.CacheEntryStats, error) {
+	return []*provider.CacheEntryStats{
+		{Key: "city_barcelona_size_50", City: "barcelona", TTLSeconds: 3600},
+	}, nil
+}
+
+func (s *fakeService) ExportCache(_ context.Context, w io.Writer) (int, error) {
+	_, err := w.Write([]byte(s.snapshot))
+	return 1, err
+}
+
+func (s *fakeService) ImportCache(_ context.Context, r io.Reader) (int, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snapshot = string(raw)
+
+	return 1, nil
+}
+
+func (s *fakeService) RemoveLocation(_ context.Context, city string) error {
+	if city != "barcelona" {
+		return provider.ErrLocationNotRanked
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.admin = append(s.admin, "remove "+city)
+
+	return nil
+}
+
+func (s *fakeService) ResetLocations(_ context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.admin = append(s.admin, "reset")
+
+	return nil
+}
+
+func (s *fakeService) MergeLocations(_ context.Context, from, to string) error {
+	if from == to {
+		return provider.ErrInvalidMerge
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.admin = append(s.admin, "merge "+from+" "+to)
+
+	return nil
+}
+
 type fakeAuthService struct {
-	token string
+	token      string
+	adminToken string
 }
 
 // Authorize fake method
@@ -288,3 +657,21 @@ func (f *fakeAuthService) Authorize(_ context.Context, user, pass string) (token
 func (f *fakeAuthService) IsValidToken(_ context.Context, token string) (bool, error) {
 	return f.token == token, nil
 }
+
+// IsAdminToken fake method
+func (f *fakeAuthService) IsAdminToken(_ context.Context, token string) (bool, error) {
+	if f.token != token {
+		return false, service.ErrUnauthorized
+	}
+
+	return f.adminToken == token, nil
+}
+
+// TokenSubject fake method
+func (f *fakeAuthService) TokenSubject(_ context.Context, token string) (string, error) {
+	if f.token != token {
+		return "", service.ErrUnauthorized
+	}
+
+	return "fakeUser", nil
+}
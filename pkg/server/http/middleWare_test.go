@@ -7,7 +7,7 @@ import (
 )
 
 func TestAuthMiddlewareOnValidCredentialsForwardRequestToEndpoint(t *testing.T) {
-	svc := &fakeAuthService{"fakeToken"}
+	svc := &fakeAuthService{token: "fakeToken"}
 	a := authMiddleware(svc)
 
 	e := &fakeEndpoint{}
@@ -31,7 +31,7 @@ func TestAuthMiddlewareOnValidCredentialsForwardRequestToEndpoint(t *testing.T)
 }
 
 func TestAuthMiddlewareOnInvalidCredentialsDoesNotForwardRequestToEndpoint(t *testing.T) {
-	svc := &fakeAuthService{"fakeToken"}
+	svc := &fakeAuthService{token: "fakeToken"}
 	a := authMiddleware(svc)
 
 	e := &fakeEndpoint{}
@@ -7,12 +7,14 @@ import (
 	"github.com/marcosQuesada/githubTop/pkg/log"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"github.com/marcosQuesada/githubTop/pkg/service"
+	"io"
 	"net"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"sync"
+	"time"
 )
 
 // ErrClosedConn happens when listener is closing
@@ -21,7 +23,17 @@ var ErrClosedConn = errors.New("use of closed network connection")
 // Service defines application interface
 type Service interface {
 	GetTopContributors(ctx context.Context, r provider.GithubTopRequest) ([]*provider.Contributor, error)
-	GetTopSearchedLocations(ctx context.Context, size int) ([]*provider.Location, error)
+	GetTopContributorsAt(ctx context.Context, r provider.GithubTopRequest, t time.Time) (*provider.TopSnapshot, error)
+	GetTopSearchedLocations(ctx context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error)
+	GetLocationRank(ctx context.Context, city string) (*provider.Location, error)
+	GetTrendingLocations(ctx context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error)
+	GetTopViewedContributors(ctx context.Context, r provider.TopViewedContributorsRequest) ([]*provider.ViewedContributor, error)
+	GetCacheStats(ctx context.Context) ([]*provider.CacheEntryStats, error)
+	ExportCache(ctx context.Context, w io.Writer) (int, error)
+	ImportCache(ctx context.Context, r io.Reader) (int, error)
+	RemoveLocation(ctx context.Context, city string) error
+	ResetLocations(ctx context.Context) error
+	MergeLocations(ctx context.Context, from, to string) error
 }
 
 // Server defines http server
@@ -31,19 +43,26 @@ type Server struct {
 	svc      Service
 	authSvc  service.AuthService
 	appName  string
+	handlers map[string]http.Handler
 	mutex    sync.Mutex
 }
 
 // New instantiates http server
 func New(port int, svc Service, auth service.AuthService, appName string) *Server {
 	return &Server{
-		port:    port,
-		svc:     svc,
-		authSvc: auth,
-		appName: appName,
+		port:     port,
+		svc:      svc,
+		authSvc:  auth,
+		appName:  appName,
+		handlers: make(map[string]http.Handler),
 	}
 }
 
+// Handle mounts handler on path, as peer to peer endpoints, before running
+func (s *Server) Handle(path string, h http.Handler) {
+	s.handlers[path] = h
+}
+
 // Run http server
 func (s *Server) Run() error {
 	log.Info("Starting HTTP Server on port ", s.port)
@@ -72,6 +91,29 @@ func (s *Server) Run() error {
 	r.Methods("GET").Path("/top-searched-locations/v1").Handler(
 		s.makeTopSearchedLocationsHandler(s.svc, s.appName))
 
+	r.Methods("GET").Path("/top-searched-locations/v1/{city}").Handler(
+		s.makeLocationRankHandler(s.svc, s.appName))
+
+	r.Methods("GET").Path("/trending-locations/v1").Handler(
+		s.makeTrendingLocationsHandler(s.svc, s.appName))
+
+	r.Methods("GET").Path("/top-viewed-contributors/v1").Handler(
+		s.makeTopViewedContributorsHandler(s.svc, s.appName))
+
+	r.Methods("GET").Path("/cache-stats/v1").Handler(
+		s.makeCacheStatsHandler(s.svc, s.appName))
+
+	r.Methods("GET").Path("/admin/cache/snapshot").Handler(s.adminHandler(s.exportCacheHandler))
+	r.Methods("POST").Path("/admin/cache/snapshot").Handler(s.adminHandler(s.importCacheHandler))
+
+	r.Methods("DELETE").Path("/admin/ranking/locations").Handler(s.adminHandler(s.resetLocationsHandler))
+	r.Methods("DELETE").Path("/admin/ranking/locations/{city}").Handler(s.adminHandler(s.removeLocationHandler))
+	r.Methods("POST").Path("/admin/ranking/merge").Handler(s.adminHandler(s.mergeLocationsHandler))
+
+	for path, h := range s.handlers {
+		r.Path(path).Handler(h)
+	}
+
 	http.Handle("/", r)
 
 	err = http.Serve(ln, nil)
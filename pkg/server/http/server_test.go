@@ -34,7 +34,7 @@ func tryConnect(maxConnRetries int) (net.Conn, error) {
 		}
 
 		time.Sleep(time.Millisecond * 200)
-		_, _ = tryConnect(maxConnRetries)
+		return tryConnect(maxConnRetries)
 	}
 
 	return c, nil
@@ -7,15 +7,21 @@ import (
 	"fmt"
 	"github.com/go-kit/kit/ratelimit"
 	"github.com/google/go-github/github"
+	"github.com/gorilla/mux"
 	"github.com/marcosQuesada/githubTop/pkg/log"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
 	"github.com/marcosQuesada/githubTop/pkg/service"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// CacheStatusHeader reports cache lookup status on responses
+const CacheStatusHeader = "X-Cache"
+
 // ErrUnexpected happens on unknown source error
 var ErrUnexpected = errors.New("unexpected Error")
 
@@ -26,11 +32,15 @@ type TopContributorsRequest struct {
 	Token string
 	Sort  string
 	APIv  string
+	// At requests top snapshot fetched at or before it, current top if zero
+	At time.Time
 }
 
 // TopContributorsResponse defines api response
 type TopContributorsResponse struct {
 	Top []*provider.Contributor
+	// FetchedAt is defined on past top snapshots
+	FetchedAt *time.Time `json:",omitempty"`
 }
 
 func topContributorsRequestDecoder(_ context.Context, r *http.Request) (interface{}, error) {
@@ -76,12 +86,40 @@ func topContributorsRequestDecoder(_ context.Context, r *http.Request) (interfac
 		version = provider.APIv2
 	}
 
-	return TopContributorsRequest{City: city, Size: int(size), Token: token, Sort: sort, APIv: version}, nil
+	at, err := parseAt(r.URL.Query().Get("at"))
+	if err != nil {
+		log.Errorf("Bad request, error parsing at, err %v", err)
+		return nil, service.ErrInvalidArgument
+	}
+
+	return TopContributorsRequest{City: city, Size: int(size), Token: token, Sort: sort, APIv: version, At: at}, nil
+}
+
+// parseAt parses past top time as RFC3339 or as a date, meaning its end, zero if empty
+func parseAt(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
 }
 
 // TopContributorsRequest defines api request
 type TopSearchedLocationsRequest struct {
-	Size int
+	Offset int
+	Size   int
+	Window provider.Window
+	Period provider.Period
+	Mode   provider.RankingMode
 }
 
 // TopContributorsResponse defines api response
@@ -90,19 +128,113 @@ type TopSearchedLocationsResponse struct {
 }
 
 func topSearchedLocationsRequestDecoder(_ context.Context, r *http.Request) (interface{}, error) {
+	offset, size, err := pageParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	window, err := provider.ParseWindow(r.URL.Query().Get("window"))
+	if err != nil {
+		log.Errorf("Bad request, error parsing window, err %v", err)
+		return nil, service.ErrInvalidArgument
+	}
+
+	var period provider.Period
+	if p := r.URL.Query().Get("period"); p != "" {
+		period, err = provider.ParsePeriod(p)
+		if err != nil {
+			log.Errorf("Bad request, error parsing period, err %v", err)
+			return nil, service.ErrInvalidArgument
+		}
+
+		if window != provider.WindowAll {
+			log.Error("Bad request, window and period are exclusive")
+			return nil, service.ErrInvalidArgument
+		}
+	}
+
+	mode, err := provider.ParseRankingMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		log.Errorf("Bad request, error parsing mode, err %v", err)
+		return nil, service.ErrInvalidArgument
+	}
+
+	return TopSearchedLocationsRequest{Offset: offset, Size: size, Window: window, Period: period, Mode: mode}, nil
+}
+
+// TopViewedContributorsRequest defines viewed contributors request, on all cities if City is empty
+type TopViewedContributorsRequest struct {
+	City   string
+	Offset int
+	Size   int
+}
+
+// TopViewedContributorsResponse defines viewed contributors response
+type TopViewedContributorsResponse struct {
+	Top []*provider.ViewedContributor
+}
+
+func topViewedContributorsRequestDecoder(_ context.Context, r *http.Request) (interface{}, error) {
+	offset, size, err := pageParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return TopViewedContributorsRequest{City: r.URL.Query().Get("city"), Offset: offset, Size: size}, nil
+}
+
+// pageParams parses required bounded size and optional bounded offset query params
+func pageParams(r *http.Request) (offset, size int, err error) {
 	rawSize := r.URL.Query().Get("size")
-	var size int64
 	if rawSize == "" {
-		return nil, service.ErrInvalidArgument
+		return 0, 0, service.ErrInvalidArgument
 	}
 
-	size, err := strconv.ParseInt(rawSize, 10, 0)
+	s, err := strconv.ParseInt(rawSize, 10, 0)
 	if err != nil {
 		log.Errorf("Bad request, error parsing size, err %v", err)
-		return nil, service.ErrInvalidArgument
+		return 0, 0, service.ErrInvalidArgument
+	}
+
+	if s <= 0 || s > service.MaxPageSize {
+		log.Errorf("Bad request, size %d not permitted", s)
+		return 0, 0, service.ErrInvalidArgument
+	}
+
+	var o int64
+	if rawOffset := r.URL.Query().Get("offset"); rawOffset != "" {
+		o, err = strconv.ParseInt(rawOffset, 10, 0)
+		if err != nil || o < 0 || o > service.MaxPageOffset {
+			log.Errorf("Bad request, error parsing offset %s", rawOffset)
+			return 0, 0, service.ErrInvalidArgument
+		}
+	}
+
+	return int(o), int(s), nil
+}
+
+// LocationRankRequest defines location rank request
+type LocationRankRequest struct {
+	City string
+}
+
+// LocationRankResponse defines location rank response
+type LocationRankResponse struct {
+	Location *provider.Location
+}
+
+func locationRankRequestDecoder(_ context.Context, r *http.Request) (interface{}, error) {
+	city := mux.Vars(r)["city"]
+	if city == "" {
+		return nil, service.ErrEmptyCity
 	}
 
-	return TopSearchedLocationsRequest{Size: int(size)}, nil
+	return LocationRankRequest{City: city}, nil
+}
+
+// CacheStatsResponse defines cache stats response
+type CacheStatsResponse struct {
+	Entries []*provider.CacheEntryStats
 }
 
 // AuthRequest defines auth request
@@ -156,6 +288,36 @@ func authResponseEncoder(ctx context.Context, w http.ResponseWriter, response in
 	return json.NewEncoder(w).Encode(response)
 }
 
+// searcherTracker identifies searching client by its token subject, or by remote ip on anonymous requests
+func (s *Server) searcherTracker(ctx context.Context, r *http.Request) context.Context {
+	if c, err := r.Cookie(service.TokenName); err == nil && s.authSvc != nil {
+		if sub, err := s.authSvc.TokenSubject(ctx, c.Value); err == nil {
+			return provider.WithSearcher(ctx, "user:"+sub)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return provider.WithSearcher(ctx, "ip:"+host)
+}
+
+// cacheStatusTracker enables cache lookup status tracking on request context
+func cacheStatusTracker(ctx context.Context, _ *http.Request) context.Context {
+	return provider.WithCacheStatus(ctx)
+}
+
+// cacheStatusHeader reports cache lookup status, if any
+func cacheStatusHeader(ctx context.Context, w http.ResponseWriter) context.Context {
+	if s := provider.CacheStatusFromContext(ctx); s != "" {
+		w.Header().Set(CacheStatusHeader, string(s))
+	}
+
+	return ctx
+}
+
 func responseEncoder(_ context.Context, w http.ResponseWriter, response interface{}) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
@@ -163,15 +325,22 @@ func responseEncoder(_ context.Context, w http.ResponseWriter, response interfac
 }
 
 // encode errors from service layer
-func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
+func errorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	cacheStatusHeader(ctx, w)
 	switch err {
 	case service.ErrUnauthorized:
 		w.WriteHeader(http.StatusForbidden)
 
-	case service.ErrInvalidArgument:
+	case service.ErrInvalidArgument, service.ErrEmptyCity, provider.ErrUnsupportedWindow,
+		provider.ErrUnsupportedPeriod, provider.ErrInvalidMerge:
 		w.WriteHeader(http.StatusBadRequest)
 
+	case service.ErrCacheStatsUnavailable, service.ErrTrendingUnavailable, service.ErrDistinctUnavailable,
+		service.ErrViewedUnavailable, provider.ErrSnapshotUnavailable, provider.ErrLocationNotRanked,
+		service.ErrHistoryUnavailable, provider.ErrTopSnapshotNotFound:
+		w.WriteHeader(http.StatusNotFound)
+
 	case ratelimit.ErrLimited:
 		err = fmt.Errorf("API rate limit exceeded")
 		w.WriteHeader(http.StatusTooManyRequests)
@@ -192,6 +361,9 @@ func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
 		case *github.ErrorResponse:
 			w.WriteHeader(http.StatusServiceUnavailable)
 
+		case *provider.NegativeCacheError:
+			w.WriteHeader(http.StatusServiceUnavailable)
+
 		default:
 			err = ErrUnexpected
 			w.WriteHeader(http.StatusInternalServerError)
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"github.com/marcosQuesada/githubTop/pkg/provider/cache"
 	"github.com/marcosQuesada/githubTop/pkg/service"
 	"net/http"
 	"net/http/httptest"
@@ -62,6 +63,39 @@ func TestDecodeTopContributorsRequestCornerCases(t *testing.T) {
 	}
 }
 
+func TestDecodeTopSearchedLocationsRequestRejectsNonPositiveSize(t *testing.T) {
+	for _, size := range []string{"0", "-1", "-100"} {
+		req := httptest.NewRequest("GET", "http://localhost:8000/top-searched-locations/v1?size="+size, nil)
+		if _, err := topSearchedLocationsRequestDecoder(context.Background(), req); err != service.ErrInvalidArgument {
+			t.Errorf("Unexpected error decoding size %s, err %v", size, err)
+		}
+
+		req = httptest.NewRequest("GET", "http://localhost:8000/top-viewed-contributors/v1?size="+size, nil)
+		if _, err := topViewedContributorsRequestDecoder(context.Background(), req); err != service.ErrInvalidArgument {
+			t.Errorf("Unexpected error decoding viewed size %s, err %v", size, err)
+		}
+	}
+}
+
+func TestDecodeTopSearchedLocationsRequestRejectsHugePages(t *testing.T) {
+	for _, q := range []string{"size=9223372036854775807&offset=1", "size=1001", "size=10&offset=9223372036854775807", "size=10&offset=100001"} {
+		req := httptest.NewRequest("GET", "http://localhost:8000/top-searched-locations/v1?"+q, nil)
+		if _, err := topSearchedLocationsRequestDecoder(context.Background(), req); err != service.ErrInvalidArgument {
+			t.Errorf("Unexpected error decoding %s, err %v", q, err)
+		}
+
+		req = httptest.NewRequest("GET", "http://localhost:8000/top-viewed-contributors/v1?"+q, nil)
+		if _, err := topViewedContributorsRequestDecoder(context.Background(), req); err != service.ErrInvalidArgument {
+			t.Errorf("Unexpected error decoding viewed %s, err %v", q, err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8000/top-searched-locations/v1?size=1000&offset=100000", nil)
+	if _, err := topSearchedLocationsRequestDecoder(context.Background(), req); err != nil {
+		t.Errorf("Unexpected error decoding max page, err %v", err)
+	}
+}
+
 func TestAuthDecoderRequest(t *testing.T) {
 
 	cr := map[string]string{"user": "test", "pass": "known"}
@@ -151,3 +185,12 @@ func (rw *fakeResponseRecorder) WriteHeader(code int) {
 func (rw *fakeResponseRecorder) Flush() {
 	rw.Flushed = true
 }
+
+func TestErrorEncoderMapsInvalidTagToInternalError(t *testing.T) {
+	w := httptest.NewRecorder()
+	errorEncoder(context.Background(), cache.ErrInvalidTag, w)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected status code, expected %d got %d", http.StatusInternalServerError, w.Code)
+	}
+}
@@ -13,6 +13,8 @@ import (
 
 const (
 	TokenName = "AccessToken"
+	// AdminRole defines token role claim granting admin endpoints access
+	AdminRole = "admin"
 )
 
 // ErrUnauthorized happens on not authorized
@@ -25,6 +27,12 @@ type AuthService interface {
 
 	// IsValidToken does token validation
 	IsValidToken(ctx context.Context, token string) (bool, error)
+
+	// TokenSubject returns valid token user name
+	TokenSubject(ctx context.Context, token string) (string, error)
+
+	// IsAdminToken validates token and its admin role
+	IsAdminToken(ctx context.Context, token string) (bool, error)
 }
 
 // Authorizer delegates credential validation
@@ -39,18 +47,30 @@ type defaultAuthService struct {
 	auth      Authorizer
 	ttl       time.Duration
 	appName   string
+	admins    map[string]bool
 }
 
-// NewAuth instantiates auth service
+// NewAuth instantiates auth service without admin users
 func NewAuth(auth Authorizer, pubKey, privKey string, exp time.Duration, appName string) *defaultAuthService {
+	return NewAuthWithAdmins(auth, pubKey, privKey, exp, appName, nil)
+}
+
+// NewAuthWithAdmins instantiates auth service, admins users tokens take admin role
+func NewAuthWithAdmins(auth Authorizer, pubKey, privKey string, exp time.Duration, appName string, admins []string) *defaultAuthService {
 	verifyKey, signKey := loadKeys(pubKey, privKey)
 
+	a := make(map[string]bool, len(admins))
+	for _, u := range admins {
+		a[u] = true
+	}
+
 	return &defaultAuthService{
 		verifyKey: verifyKey,
 		signKey:   signKey,
 		auth:      auth,
 		ttl:       exp,
 		appName:   appName,
+		admins:    a,
 	}
 }
 
@@ -77,6 +97,9 @@ func (s *defaultAuthService) Authorize(_ context.Context, user, pass string) (st
 		}{user, s.appName},
 		"exp": time.Now().Add(s.ttl).Unix(),
 	}
+	if s.admins[user] {
+		claims["Role"] = AdminRole
+	}
 
 	// create RS256 signer
 	t := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
@@ -136,6 +159,55 @@ func (s *defaultAuthService) IsValidToken(_ context.Context, tokenKey string) (b
 
 }
 
+// TokenSubject returns user name from a valid jwt token
+func (s *defaultAuthService) TokenSubject(_ context.Context, tokenKey string) (string, error) {
+	claims, err := s.claims(tokenKey)
+	if err != nil {
+		return "", err
+	}
+
+	info, ok := claims["CustomUserInfo"].(map[string]interface{})
+	if !ok {
+		return "", ErrUnauthorized
+	}
+
+	name, ok := info["Name"].(string)
+	if !ok || name == "" {
+		return "", ErrUnauthorized
+	}
+
+	return name, nil
+}
+
+// IsAdminToken validates jwt token and its admin role claim
+func (s *defaultAuthService) IsAdminToken(_ context.Context, tokenKey string) (bool, error) {
+	claims, err := s.claims(tokenKey)
+	if err != nil {
+		return false, err
+	}
+
+	role, _ := claims["Role"].(string)
+
+	return role == AdminRole, nil
+}
+
+// claims returns valid jwt token claims
+func (s *defaultAuthService) claims(tokenKey string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenKey, func(token *jwt.Token) (interface{}, error) {
+		return s.verifyKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrUnauthorized
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+
+	return claims, nil
+}
+
 // loadKeys read public and private keys from file
 func loadKeys(pubKey, privKey string) (verifyKey *rsa.PublicKey, signKey *rsa.PrivateKey) {
 	absPath, _ := filepath.Abs(pubKey)
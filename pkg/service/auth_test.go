@@ -23,6 +23,43 @@ func TestAuthWorkFlowOnValidCredentials(t *testing.T) {
 	if !valid {
 		t.Error("Expected validated")
 	}
+
+	sub, err := a.TokenSubject(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Unexpected error getting token subject, error %s", err.Error())
+	}
+
+	if sub != "test" {
+		t.Errorf("Unexpected token subject, got %s", sub)
+	}
+
+	if admin, err := a.IsAdminToken(context.Background(), token); err != nil || admin {
+		t.Errorf("Unexpected admin token, admin %v error %v", admin, err)
+	}
+}
+
+func TestAuthGrantsAdminRoleJustToAdminUsers(t *testing.T) {
+	val := &fakeAuth{valid: true}
+	a := NewAuthWithAdmins(val, "../../config/app.rsa", "../../config/app.rsa.pub", time.Minute, "fakeApp", []string{"admin"})
+
+	for user, expected := range map[string]bool{"admin": true, "test": false} {
+		token, err := a.Authorize(context.Background(), user, "known")
+		if err != nil {
+			t.Fatalf("Unexpected error generating token key, error %s", err.Error())
+		}
+
+		admin, err := a.IsAdminToken(context.Background(), token)
+		if err != nil {
+			t.Fatalf("Unexpected error validating admin token, error %s", err.Error())
+		}
+		if admin != expected {
+			t.Errorf("Unexpected %s admin role, expected %v got %v", user, expected, admin)
+		}
+	}
+
+	if _, err := a.IsAdminToken(context.Background(), "invalid"); err != ErrUnauthorized {
+		t.Errorf("Unexpected invalid token error, got %v", err)
+	}
 }
 
 func TestAuthWorkFlowOnInValidCredentials(t *testing.T) {
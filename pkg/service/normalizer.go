@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"io/ioutil"
+	"strings"
+)
+
+// LocationNormalizer canonicalizes searched locations
+type LocationNormalizer interface {
+	// Normalize returns location canonical name
+	Normalize(city string) string
+	// Aliases returns canonical location known variants
+	Aliases(city string) []string
+}
+
+// NormalizeLocation applies unicode compatibility normalization, case folding and whitespace trimming
+func NormalizeLocation(city string) string {
+	city = norm.NFKC.String(city)
+	city = cases.Fold().String(city)
+
+	return strings.Join(strings.Fields(city), " ")
+}
+
+// Normalizer resolves location aliases to its canonical name
+type Normalizer struct {
+	canonical map[string]string
+	variants  map[string][]string
+}
+
+// NewNormalizer instantiates normalizer from canonical names to its variants
+func NewNormalizer(aliases map[string][]string) *Normalizer {
+	n := &Normalizer{
+		canonical: make(map[string]string),
+		variants:  make(map[string][]string),
+	}
+
+	for c, vs := range aliases {
+		c = NormalizeLocation(c)
+		for _, v := range vs {
+			v = NormalizeLocation(v)
+			if v == c || v == "" {
+				continue
+			}
+			n.canonical[v] = c
+			n.variants[c] = append(n.variants[c], v)
+		}
+	}
+
+	return n
+}
+
+// LoadNormalizer reads aliases json file, as {"barcelona": ["bcn", "barcelona, spain"]}
+func LoadNormalizer(path string) (*Normalizer, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string][]string)
+	if err := json.Unmarshal(raw, &aliases); err != nil {
+		return nil, fmt.Errorf("invalid location aliases file %s, error %w", path, err)
+	}
+
+	return NewNormalizer(aliases), nil
+}
+
+// Normalize returns location canonical name
+func (n *Normalizer) Normalize(city string) string {
+	city = NormalizeLocation(city)
+	if c, ok := n.canonical[city]; ok {
+		return c
+	}
+
+	return city
+}
+
+// Aliases returns canonical location known variants
+func (n *Normalizer) Aliases(city string) []string {
+	return n.variants[city]
+}
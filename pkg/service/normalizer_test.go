@@ -0,0 +1,62 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeLocation(t *testing.T) {
+	cases := map[string]string{
+		"Barcelona":           "barcelona",
+		"  barcelona ":        "barcelona",
+		"San   Francisco":     "san francisco",
+		"ＢＣＮ":                 "bcn",
+		"München":            "münchen",
+		"Straße":              "strasse",
+		"Barcelona,\tSpain  ": "barcelona, spain",
+	}
+
+	for in, expected := range cases {
+		if n := NormalizeLocation(in); n != expected {
+			t.Errorf("Unexpected normalization of %q, expected %q got %q", in, expected, n)
+		}
+	}
+}
+
+func TestNormalizerResolvesAliases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aliases")
+	if err != nil {
+		t.Fatalf("Unexpected error creating temp dir, err %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "aliases.json")
+	raw := []byte(`{"Barcelona": ["BCN", "Barcelona, Spain", "barcelona"]}`)
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		t.Fatalf("Unexpected error writing aliases, err %v", err)
+	}
+
+	n, err := LoadNormalizer(path)
+	if err != nil {
+		t.Fatalf("Unexpected error loading aliases, err %v", err)
+	}
+
+	for _, v := range []string{"Barcelona", "barcelona ", "BCN", "Barcelona, Spain"} {
+		if c := n.Normalize(v); c != "barcelona" {
+			t.Errorf("Unexpected canonical name of %q, got %q", v, c)
+		}
+	}
+
+	aliases := n.Aliases("barcelona")
+	if len(aliases) != 2 || aliases[0] != "bcn" || aliases[1] != "barcelona, spain" {
+		t.Errorf("Unexpected aliases, got %v", aliases)
+	}
+
+	if c := n.Normalize("Madrid"); c != "madrid" {
+		t.Errorf("Unexpected non aliased location, got %q", c)
+	}
+}
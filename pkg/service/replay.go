@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"time"
+)
+
+// ReplayConfig defines replayed events range and the rules in force at replay time
+type ReplayConfig struct {
+	// From and To bound replayed searches time, unbounded on zero values
+	From time.Time
+	To   time.Time
+	// CountPolicy defines which searches are counted on rankings, CountOnSuccess if empty
+	CountPolicy CountPolicy
+	// Normalizer canonicalizes requested locations, normalization without aliases if empty
+	Normalizer LocationNormalizer
+}
+
+// ReplayStats sums up a replay
+type ReplayStats struct {
+	Read     int
+	Replayed int
+}
+
+// Replay counts logged searches on non nil rankings, as if they were served now with current rules:
+// requested cities are normalized again and count policy applied, each search is ranked at its own
+// time by its own searcher, so that windowed, trending and distinct rankings rebuild deterministically
+func Replay(ctx context.Context, src provider.SearchEventSource, cfg ReplayConfig, rankings ...SearchedLocationsRanking) (ReplayStats, error) {
+	if cfg.CountPolicy == "" {
+		cfg.CountPolicy = CountOnSuccess
+	}
+
+	if cfg.Normalizer == nil {
+		cfg.Normalizer = NewNormalizer(nil)
+	}
+
+	rankings = nonNilRankings(rankings...)
+	ctx = provider.WithReplay(ctx)
+	stats := ReplayStats{}
+	err := src.Read(ctx, cfg.From, cfg.To, func(e *provider.SearchEvent) error {
+		stats.Read++
+		if !cfg.CountPolicy.countsEvent(e) {
+			return nil
+		}
+
+		requested := e.RequestedCity
+		if requested == "" {
+			requested = e.City
+		}
+
+		city := cfg.Normalizer.Normalize(requested)
+		if city == "" {
+			return nil
+		}
+
+		sCtx := provider.WithSearchTime(provider.WithSearcher(ctx, e.Client), e.Time)
+		for _, rnk := range rankings {
+			if err := rnk.IncreaseCityScore(sCtx, city); err != nil {
+				return err
+			}
+		}
+		stats.Replayed++
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// countsEvent returns if a logged search is counted
+func (p CountPolicy) countsEvent(e *provider.SearchEvent) bool {
+	switch p {
+	case CountOnRequest:
+		return true
+	case CountOnNonEmpty:
+		return e.Error == "" && e.Results > 0
+	}
+
+	return e.Error == ""
+}
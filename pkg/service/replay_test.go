@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeEventSource struct {
+	events []*provider.SearchEvent
+}
+
+func (f *fakeEventSource) Read(ctx context.Context, from, to time.Time, fn func(e *provider.SearchEvent) error) error {
+	for _, e := range f.events {
+		if !e.InRange(from, to) {
+			continue
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestReplayAppliesCurrentRulesOnRange(t *testing.T) {
+	at := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	src := &fakeEventSource{events: []*provider.SearchEvent{
+		{Time: at.Add(-time.Hour), RequestedCity: "Madrid", City: "madrid", Client: "ip:10.0.0.1", Results: 10},
+		{Time: at, RequestedCity: "BCN", City: "bcn", Client: "ip:10.0.0.2", Results: 10},
+		{Time: at.Add(time.Minute), RequestedCity: "Barcelona", City: "barcelona", Client: "ip:10.0.0.3"},
+		{Time: at.Add(time.Minute * 2), RequestedCity: "Barcelona", City: "barcelona", Error: "timeout"},
+		{Time: at.Add(time.Minute * 3), City: "Badalona", Client: "ip:10.0.0.4", Results: 5},
+	}}
+	rnk, trending := &fakeRanking{}, &fakeRanking{}
+	cfg := ReplayConfig{
+		From:        at,
+		CountPolicy: CountOnNonEmpty,
+		Normalizer:  NewNormalizer(map[string][]string{"barcelona": {"bcn"}}),
+	}
+
+	stats, err := Replay(context.Background(), src, cfg, rnk, nil, trending)
+	if err != nil {
+		t.Fatalf("Unexpected error replaying, error %v", err)
+	}
+
+	if stats.Read != 4 || stats.Replayed != 2 {
+		t.Errorf("Unexpected replay stats, got %+v", stats)
+	}
+
+	for _, r := range []*fakeRanking{rnk, trending} {
+		if !reflect.DeepEqual(r.increases(), []string{"barcelona", "badalona"}) {
+			t.Errorf("Unexpected replayed cities, got %v", r.increases())
+		}
+		if !reflect.DeepEqual(r.searchers, []string{"ip:10.0.0.2", "ip:10.0.0.4"}) {
+			t.Errorf("Unexpected replayed searchers, got %v", r.searchers)
+		}
+	}
+}
+
+func TestCountPolicyOnEvents(t *testing.T) {
+	failed, empty := &provider.SearchEvent{Error: "timeout"}, &provider.SearchEvent{}
+	if !CountOnRequest.countsEvent(failed) || CountOnSuccess.countsEvent(failed) {
+		t.Error("Unexpected failed search count")
+	}
+
+	if !CountOnSuccess.countsEvent(empty) || CountOnNonEmpty.countsEvent(empty) {
+		t.Error("Unexpected empty search count")
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/marcosQuesada/githubTop/pkg/log"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sync"
+	"time"
+)
+
+// CountPolicy defines which top contributors searches are counted on rankings
+type CountPolicy string
+
+const (
+	// CountOnRequest counts every search, failed ones included
+	CountOnRequest CountPolicy = "request"
+	// CountOnSuccess counts searches answered without error
+	CountOnSuccess CountPolicy = "success"
+	// CountOnNonEmpty counts searches answered with contributors
+	CountOnNonEmpty CountPolicy = "non-empty"
+)
+
+const (
+	// DefaultScoreQueueSize bounds write behind pending searches
+	DefaultScoreQueueSize = 10000
+	// DefaultScoreBatchSize defines write behind max searches by flush
+	DefaultScoreBatchSize = 500
+	// DefaultScoreFlushInterval defines write behind flush frequency
+	DefaultScoreFlushInterval = time.Second
+)
+
+// ErrInvalidCountPolicy happens on unknown count policies
+var ErrInvalidCountPolicy = errors.New("invalid ranking count policy")
+
+// ParseCountPolicy validates count policy name
+func ParseCountPolicy(p string) (CountPolicy, error) {
+	switch CountPolicy(p) {
+	case CountOnRequest, CountOnSuccess, CountOnNonEmpty:
+		return CountPolicy(p), nil
+	}
+
+	return "", ErrInvalidCountPolicy
+}
+
+// countsResult returns if a search is counted once answered, searches counted on request are already
+func (p CountPolicy) countsResult(res []*provider.Contributor, err error) bool {
+	switch p {
+	case CountOnSuccess:
+		return err == nil
+	case CountOnNonEmpty:
+		return err == nil && len(res) > 0
+	}
+
+	return false
+}
+
+// SearchRecorder records counted searches on searched locations rankings
+type SearchRecorder interface {
+	Record(ctx context.Context, city string)
+}
+
+// syncRecorder increases rankings scores on request path
+type syncRecorder struct {
+	rankings []SearchedLocationsRanking
+}
+
+// Record increases city score on all rankings, errors are just logged
+func (r *syncRecorder) Record(ctx context.Context, city string) {
+	for _, rnk := range r.rankings {
+		if err := rnk.IncreaseCityScore(ctx, city); err != nil {
+			log.Errorf("unexpected error increasing city score, error %v", err)
+		}
+	}
+}
+
+// WriteBehindConfig defines write behind queue and flush sizing, defaults on zero values
+type WriteBehindConfig struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// WriteBehind records searches off the request path, queued searches are flushed in batches to rankings
+// once batch size is reached or on each flush interval. Searches are dropped when queue is full, so that
+// slow rankings never block requests.
+type WriteBehind struct {
+	rankings      []SearchedLocationsRanking
+	queue         chan provider.Search
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewWriteBehind instantiates write behind recorder over non nil rankings
+func NewWriteBehind(cfg WriteBehindConfig, rankings ...SearchedLocationsRanking) *WriteBehind {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultScoreQueueSize
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultScoreBatchSize
+	}
+
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultScoreFlushInterval
+	}
+
+	return &WriteBehind{
+		rankings:      nonNilRankings(rankings...),
+		queue:         make(chan provider.Search, cfg.QueueSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		done:          make(chan struct{}),
+	}
+}
+
+// Record enqueues city search with its searcher, dropped if queue is full
+func (w *WriteBehind) Record(ctx context.Context, city string) {
+	select {
+	case w.queue <- provider.Search{City: city, Searcher: provider.SearcherFromContext(ctx)}:
+	default:
+		log.Errorf("ranking write behind queue full, search on %s dropped", city)
+	}
+}
+
+// Run starts flush worker
+func (w *WriteBehind) Run() {
+	w.wg.Add(1)
+	go w.runner()
+}
+
+// Terminate stops flush worker, pending searches are flushed
+func (w *WriteBehind) Terminate() {
+	close(w.done)
+	w.wg.Wait()
+}
+
+func (w *WriteBehind) runner() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]provider.Search, 0, w.batchSize)
+	for {
+		select {
+		case s := <-w.queue:
+			batch = append(batch, s)
+			if len(batch) >= w.batchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case <-w.done:
+			for {
+				select {
+				case s := <-w.queue:
+					batch = append(batch, s)
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch to all rankings, returns emptied batch
+func (w *WriteBehind) flush(batch []provider.Search) []provider.Search {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	for _, rnk := range w.rankings {
+		if err := rnk.IncreaseCityScores(context.Background(), batch); err != nil {
+			log.Errorf("unexpected error flushing city scores, error %v", err)
+		}
+	}
+
+	return batch[:0]
+}
+
+// nonNilRankings filters disabled rankings
+func nonNilRankings(rankings ...SearchedLocationsRanking) []SearchedLocationsRanking {
+	var res []SearchedLocationsRanking
+	for _, rnk := range rankings {
+		if rnk != nil {
+			res = append(res, rnk)
+		}
+	}
+
+	return res
+}
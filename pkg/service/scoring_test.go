@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"testing"
+	"time"
+)
+
+func TestParseCountPolicy(t *testing.T) {
+	if p, err := ParseCountPolicy("non-empty"); err != nil || p != CountOnNonEmpty {
+		t.Errorf("Unexpected policy, got %q error %v", p, err)
+	}
+
+	if _, err := ParseCountPolicy("always"); err != ErrInvalidCountPolicy {
+		t.Errorf("Unexpected error, expected %v got %v", ErrInvalidCountPolicy, err)
+	}
+}
+
+func TestWriteBehindFlushesBatchesWithSearchers(t *testing.T) {
+	rnk, trending := &fakeRanking{}, &fakeRanking{}
+	w := NewWriteBehind(WriteBehindConfig{BatchSize: 2, FlushInterval: time.Hour}, rnk, trending, nil)
+	w.Run()
+
+	for i := 0; i < 5; i++ {
+		w.Record(provider.WithSearcher(context.Background(), "ip:10.0.0.1"), "barcelona")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(rnk.increases()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 5)
+	}
+	if l := len(rnk.increases()); l != 4 {
+		t.Fatalf("Unexpected flushed searches before terminate, expected 4 got %d", l)
+	}
+
+	w.Terminate()
+	for _, r := range []*fakeRanking{rnk, trending} {
+		if l := len(r.increases()); l != 5 {
+			t.Errorf("Unexpected flushed searches after terminate, expected 5 got %d", l)
+		}
+		if r.searchers[0] != "ip:10.0.0.1" {
+			t.Errorf("Unexpected searcher, got %s", r.searchers[0])
+		}
+	}
+}
+
+func TestWriteBehindDropsSearchesOnFullQueue(t *testing.T) {
+	rnk := &fakeRanking{}
+	w := NewWriteBehind(WriteBehindConfig{QueueSize: 2}, rnk)
+
+	for i := 0; i < 3; i++ {
+		w.Record(context.Background(), "barcelona")
+	}
+
+	w.Run()
+	w.Terminate()
+
+	if l := len(rnk.increases()); l != 2 {
+		t.Errorf("Unexpected flushed searches, expected 2 got %d", l)
+	}
+}
+
+func TestDefaultServiceRecordsOffRequestPath(t *testing.T) {
+	rnk := &fakeRanking{}
+	w := NewWriteBehind(WriteBehindConfig{FlushInterval: time.Millisecond * 10}, rnk)
+	w.Run()
+	s := NewWithConfig(newFakeRepository(50), rnk, Config{Recorder: w})
+
+	_, err := s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "barcelona", Size: 50})
+	if err != nil {
+		t.Fatalf("Unexpected error getting contributors, err %v", err)
+	}
+
+	w.Terminate()
+	if l := rnk.increases(); len(l) != 1 || l[0] != "barcelona" {
+		t.Errorf("Unexpected recorded searches, got %v", l)
+	}
+}
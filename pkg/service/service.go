@@ -5,6 +5,8 @@ import (
 	"errors"
 	"github.com/marcosQuesada/githubTop/pkg/log"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"io"
+	"time"
 )
 
 const (
@@ -12,6 +14,11 @@ const (
 	SmallSize  = 50
 	MediumSize = 100
 	LargeSize  = 150
+
+	// MaxPageSize bounds ranking page size
+	MaxPageSize = 1000
+	// MaxPageOffset bounds ranking page offset
+	MaxPageOffset = 100000
 )
 
 var (
@@ -19,41 +26,338 @@ var (
 	ErrInvalidArgument = errors.New("invalid Arguments")
 	// ErrEmptyCity happens on request without defined city
 	ErrEmptyCity = errors.New("bad Request, void City")
+	// ErrCacheStatsUnavailable happens on repositories without cache
+	ErrCacheStatsUnavailable = errors.New("cache stats unavailable")
+	// ErrTrendingUnavailable happens on services without trending ranking
+	ErrTrendingUnavailable = errors.New("trending locations unavailable")
+	// ErrDistinctUnavailable happens on services without distinct searchers ranking
+	ErrDistinctUnavailable = errors.New("distinct searchers ranking unavailable")
+	// ErrViewedUnavailable happens on services without viewed contributors ranking
+	ErrViewedUnavailable = errors.New("viewed contributors ranking unavailable")
+	// ErrHistoryUnavailable happens on services without top contributors history
+	ErrHistoryUnavailable = errors.New("top contributors history unavailable")
 )
 
 // SearchedLocationsRanking defines location ranking
 type SearchedLocationsRanking interface {
-	GetTopSearchedLocations(ctx context.Context, size int) ([]*provider.Location, error)
+	GetTopSearchedLocations(ctx context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error)
 	IncreaseCityScore(ctx context.Context, city string) error
+	IncreaseCityScores(ctx context.Context, searches []provider.Search) error
+	GetLocationRank(ctx context.Context, city string) (*provider.Location, error)
+	RemoveCity(ctx context.Context, city string) error
+	Reset(ctx context.Context) error
+	MergeCities(ctx context.Context, from, to string) error
+}
+
+// ViewedContributorsRanking defines contributors ranking by weighted views
+type ViewedContributorsRanking interface {
+	IncreaseViews(ctx context.Context, city string, contributors []*provider.Contributor) error
+	GetTopViewedContributors(ctx context.Context, r provider.TopViewedContributorsRequest) ([]*provider.ViewedContributor, error)
+}
+
+// SearchEventEmitter emits search events for offline analysis, it must not block
+type SearchEventEmitter interface {
+	Emit(e *provider.SearchEvent)
+}
+
+// CacheStats exposes cached entries refresh history
+type CacheStats interface {
+	Stats(ctx context.Context) []*provider.CacheEntryStats
+}
+
+// CacheSnapshots dumps and restores cache contents
+type CacheSnapshots interface {
+	ExportCache(ctx context.Context, w io.Writer) (int, error)
+	ImportCache(ctx context.Context, r io.Reader) (int, error)
+}
+
+// Config defines optional service components
+type Config struct {
+	// Trending ranks locations with time decayed searches
+	Trending SearchedLocationsRanking
+	// Distinct ranks locations by distinct searchers
+	Distinct SearchedLocationsRanking
+	// DefaultMode defines ranking mode on requests without mode, raw if empty
+	DefaultMode provider.RankingMode
+	// Normalizer canonicalizes locations before ranking and searching, normalization without aliases if empty
+	Normalizer LocationNormalizer
+	// CountPolicy defines which searches are counted on rankings, CountOnSuccess if empty
+	CountPolicy CountPolicy
+	// Recorder counts searches on rankings, increased on request path if empty
+	Recorder SearchRecorder
+	// Viewed ranks contributors by served results views
+	Viewed ViewedContributorsRanking
+	// Events emits a search event on each top contributors search
+	Events SearchEventEmitter
+	// History serves past top contributors snapshots
+	History provider.TopHistory
 }
 
 // DefaultService defines core service
 type DefaultService struct {
-	repository provider.GithubRepository
-	ranking    SearchedLocationsRanking
+	repository  provider.GithubRepository
+	ranking     SearchedLocationsRanking
+	trending    SearchedLocationsRanking
+	distinct    SearchedLocationsRanking
+	defaultMode provider.RankingMode
+	normalizer  LocationNormalizer
+	countPolicy CountPolicy
+	recorder    SearchRecorder
+	viewed      ViewedContributorsRanking
+	events      SearchEventEmitter
+	history     provider.TopHistory
+	now         func() time.Time
 }
 
 // New instantiates service
 func New(r provider.GithubRepository, rnk SearchedLocationsRanking) *DefaultService {
+	return NewWithConfig(r, rnk, Config{})
+}
+
+// NewWithConfig instantiates service with optional components
+func NewWithConfig(r provider.GithubRepository, rnk SearchedLocationsRanking, cfg Config) *DefaultService {
+	if cfg.DefaultMode == "" {
+		cfg.DefaultMode = provider.RankingRaw
+	}
+
+	if cfg.Normalizer == nil {
+		cfg.Normalizer = NewNormalizer(nil)
+	}
+
+	if cfg.CountPolicy == "" {
+		cfg.CountPolicy = CountOnSuccess
+	}
+
+	if cfg.Recorder == nil {
+		cfg.Recorder = &syncRecorder{rankings: nonNilRankings(rnk, cfg.Trending, cfg.Distinct)}
+	}
+
 	return &DefaultService{
-		repository: r,
-		ranking:    rnk,
+		repository:  r,
+		ranking:     rnk,
+		trending:    cfg.Trending,
+		distinct:    cfg.Distinct,
+		defaultMode: cfg.DefaultMode,
+		normalizer:  cfg.Normalizer,
+		countPolicy: cfg.CountPolicy,
+		recorder:    cfg.Recorder,
+		viewed:      cfg.Viewed,
+		events:      cfg.Events,
+		history:     cfg.History,
+		now:         time.Now,
 	}
 }
 
 // GetTopContributors returns github top by location
 func (s *DefaultService) GetTopContributors(ctx context.Context, r provider.GithubTopRequest) ([]*provider.Contributor, error) {
-	log.Infof("GetTopContributors , city: %s size: %d sort %s", r.City, r.Size, r.Sort)
-	err := s.ranking.IncreaseCityScore(ctx, r.City)
+	requested := r.City
+	r.City = s.normalizer.Normalize(r.City)
+	if r.City == "" {
+		return nil, ErrEmptyCity
+	}
+	r.Aliases = s.normalizer.Aliases(r.City)
+
+	log.Infof("GetTopContributors , city: %s size: %d sort %s aliases %v", r.City, r.Size, r.Sort, r.Aliases)
+	if s.countPolicy == CountOnRequest {
+		s.recorder.Record(ctx, r.City)
+	}
+
+	start := s.now()
+	res, err := s.repository.GetGithubTopContributors(ctx, r)
+	s.emit(ctx, start, requested, r, res, err)
+	if s.countPolicy.countsResult(res, err) {
+		s.recorder.Record(ctx, r.City)
+	}
+
+	if err == nil && s.viewed != nil && len(res) > 0 {
+		if err := s.viewed.IncreaseViews(ctx, r.City, res); err != nil {
+			log.Errorf("unexpected error increasing contributor views, error %v", err)
+		}
+	}
+
+	return res, err
+}
+
+// GetTopContributorsAt returns city top snapshot fetched at or before t, past lookups are not ranked
+func (s *DefaultService) GetTopContributorsAt(ctx context.Context, r provider.GithubTopRequest, t time.Time) (*provider.TopSnapshot, error) {
+	if s.history == nil {
+		return nil, ErrHistoryUnavailable
+	}
+
+	r.City = s.normalizer.Normalize(r.City)
+	if r.City == "" {
+		return nil, ErrEmptyCity
+	}
+
+	log.Infof("GetTopContributorsAt , city: %s size: %d at: %s", r.City, r.Size, t.Format(time.RFC3339))
+
+	return s.history.At(ctx, r.City, r.Size, t)
+}
+
+// emit sends served search event, if enabled
+func (s *DefaultService) emit(ctx context.Context, start time.Time, requested string, r provider.GithubTopRequest, res []*provider.Contributor, err error) {
+	if s.events == nil {
+		return
+	}
+
+	e := &provider.SearchEvent{
+		Time:          start,
+		RequestedCity: requested,
+		City:          r.City,
+		Size:          r.Size,
+		Sort:          r.Sort,
+		Version:       r.Version,
+		Client:        provider.SearcherFromContext(ctx),
+		CacheStatus:   provider.CacheStatusFromContext(ctx),
+		LatencyMs:     float64(s.now().Sub(start)) / float64(time.Millisecond),
+		Results:       len(res),
+	}
 	if err != nil {
-		log.Errorf("unexpected error increasing city score, error %v", err)
+		e.Error = err.Error()
 	}
-	return s.repository.GetGithubTopContributors(ctx, r)
+
+	s.events.Emit(e)
 }
 
 // GetTopSearchedLocations return top Searched Locations
-func (s *DefaultService) GetTopSearchedLocations(ctx context.Context, size int) ([]*provider.Location, error) {
-	log.Infof("GetTopSearchedLocations , size: %d ", size)
+func (s *DefaultService) GetTopSearchedLocations(ctx context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error) {
+	if r.Mode == "" {
+		r.Mode = s.defaultMode
+	}
+	log.Infof("GetTopSearchedLocations , offset: %d size: %d window: %s period: %s mode: %s", r.Offset, r.Size, r.Window, r.Period, r.Mode)
+
+	if r.Mode == provider.RankingDistinct {
+		if s.distinct == nil {
+			return nil, ErrDistinctUnavailable
+		}
+
+		return s.distinct.GetTopSearchedLocations(ctx, r)
+	}
+
+	return s.ranking.GetTopSearchedLocations(ctx, r)
+}
+
+// GetLocationRank returns searched location rank and score
+func (s *DefaultService) GetLocationRank(ctx context.Context, city string) (*provider.Location, error) {
+	city = s.normalizer.Normalize(city)
+	log.Infof("GetLocationRank , city: %s", city)
+	if city == "" {
+		return nil, ErrEmptyCity
+	}
+
+	return s.ranking.GetLocationRank(ctx, city)
+}
+
+// GetTrendingLocations return top trending Locations
+func (s *DefaultService) GetTrendingLocations(ctx context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error) {
+	log.Infof("GetTrendingLocations , size: %d ", r.Size)
+	if s.trending == nil {
+		return nil, ErrTrendingUnavailable
+	}
+
+	return s.trending.GetTopSearchedLocations(ctx, r)
+}
+
+// GetTopViewedContributors returns most viewed contributors, on all cities or on request city
+func (s *DefaultService) GetTopViewedContributors(ctx context.Context, r provider.TopViewedContributorsRequest) ([]*provider.ViewedContributor, error) {
+	r.City = s.normalizer.Normalize(r.City)
+	log.Infof("GetTopViewedContributors , city: %s offset: %d size: %d", r.City, r.Offset, r.Size)
+	if s.viewed == nil {
+		return nil, ErrViewedUnavailable
+	}
+
+	return s.viewed.GetTopViewedContributors(ctx, r)
+}
+
+// RemoveLocation deletes location from searched locations rankings
+func (s *DefaultService) RemoveLocation(ctx context.Context, city string) error {
+	city = s.normalizer.Normalize(city)
+	log.Infof("RemoveLocation , city: %s", city)
+	if city == "" {
+		return ErrEmptyCity
+	}
+
+	if err := s.ranking.RemoveCity(ctx, city); err != nil {
+		return err
+	}
+
+	for _, rnk := range s.secondaryRankings() {
+		if err := rnk.RemoveCity(ctx, city); err != nil && err != provider.ErrLocationNotRanked {
+			log.Errorf("unexpected error removing city from secondary ranking, error %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ResetLocations deletes all searched locations rankings
+func (s *DefaultService) ResetLocations(ctx context.Context) error {
+	log.Info("ResetLocations")
+	if err := s.ranking.Reset(ctx); err != nil {
+		return err
+	}
+
+	for _, rnk := range s.secondaryRankings() {
+		if err := rnk.Reset(ctx); err != nil {
+			log.Errorf("unexpected error resetting secondary ranking, error %v", err)
+		}
+	}
+
+	return nil
+}
+
+// MergeLocations moves searched location scores into another one, as on misspelled locations
+func (s *DefaultService) MergeLocations(ctx context.Context, from, to string) error {
+	from, to = s.normalizer.Normalize(from), s.normalizer.Normalize(to)
+	log.Infof("MergeLocations , from: %s to: %s", from, to)
+	if from == "" || to == "" {
+		return ErrEmptyCity
+	}
+
+	if err := s.ranking.MergeCities(ctx, from, to); err != nil {
+		return err
+	}
+
+	for _, rnk := range s.secondaryRankings() {
+		if err := rnk.MergeCities(ctx, from, to); err != nil && err != provider.ErrLocationNotRanked {
+			log.Errorf("unexpected error merging cities on secondary ranking, error %v", err)
+		}
+	}
+
+	return nil
+}
+
+// secondaryRankings returns enabled trending and distinct rankings
+func (s *DefaultService) secondaryRankings() []SearchedLocationsRanking {
+	return nonNilRankings(s.trending, s.distinct)
+}
+
+// GetCacheStats returns cached entries refresh history, if repository is cached
+func (s *DefaultService) GetCacheStats(ctx context.Context) ([]*provider.CacheEntryStats, error) {
+	cs, ok := s.repository.(CacheStats)
+	if !ok {
+		return nil, ErrCacheStatsUnavailable
+	}
+
+	return cs.Stats(ctx), nil
+}
+
+// ExportCache writes cache snapshot, if repository is cached
+func (s *DefaultService) ExportCache(ctx context.Context, w io.Writer) (int, error) {
+	cs, ok := s.repository.(CacheSnapshots)
+	if !ok {
+		return 0, provider.ErrSnapshotUnavailable
+	}
+
+	return cs.ExportCache(ctx, w)
+}
+
+// ImportCache restores cache snapshot, if repository is cached
+func (s *DefaultService) ImportCache(ctx context.Context, r io.Reader) (int, error) {
+	cs, ok := s.repository.(CacheSnapshots)
+	if !ok {
+		return 0, provider.ErrSnapshotUnavailable
+	}
 
-	return s.ranking.GetTopSearchedLocations(ctx, size)
+	return cs.ImportCache(ctx, r)
 }
@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/marcosQuesada/githubTop/pkg/provider"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDefaultContributorServiceOnFakeRepositoryOnSinglePage(t *testing.T) {
@@ -54,8 +57,273 @@ func TestDefaultContributorServiceOnFakeRepository(t *testing.T) {
 	}
 }
 
+func TestDefaultServiceTracksTrendingLocations(t *testing.T) {
+	trending := &fakeRanking{}
+	s := NewWithConfig(newFakeRepository(50), &fakeRanking{}, Config{Trending: trending})
+
+	_, err := s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "barcelona", Size: 50})
+	if err != nil {
+		t.Fatalf("Unexpected error getting contributors, err %v", err)
+	}
+
+	if len(trending.increased) != 1 || trending.increased[0] != "barcelona" {
+		t.Errorf("Unexpected trending increases, got %v", trending.increased)
+	}
+
+	top, err := s.GetTrendingLocations(context.Background(), provider.TopLocationsRequest{Size: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error getting trending locations, err %v", err)
+	}
+
+	if len(top) != 2 {
+		t.Errorf("Unexpected trending size, got %d", len(top))
+	}
+}
+
+func TestDefaultServiceWithoutTrendingRanking(t *testing.T) {
+	s := New(newFakeRepository(50), &fakeRanking{})
+
+	_, err := s.GetTrendingLocations(context.Background(), provider.TopLocationsRequest{Size: 10})
+	if err != ErrTrendingUnavailable {
+		t.Errorf("Unexpected error, expected %v got %v", ErrTrendingUnavailable, err)
+	}
+}
+
+func TestDefaultServiceSelectsRankingMode(t *testing.T) {
+	distinct := &fakeRanking{}
+	s := NewWithConfig(newFakeRepository(50), &fakeRanking{}, Config{Distinct: distinct, DefaultMode: provider.RankingDistinct})
+
+	_, _ = s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "barcelona", Size: 50})
+	if len(distinct.increased) != 1 {
+		t.Errorf("Unexpected distinct increases, got %v", distinct.increased)
+	}
+
+	if _, err := s.GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 10}); err != nil {
+		t.Errorf("Unexpected error getting distinct locations, err %v", err)
+	}
+
+	s = New(newFakeRepository(50), &fakeRanking{})
+	_, err := s.GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 10, Mode: provider.RankingDistinct})
+	if err != ErrDistinctUnavailable {
+		t.Errorf("Unexpected error, expected %v got %v", ErrDistinctUnavailable, err)
+	}
+
+	if _, err := s.GetTopSearchedLocations(context.Background(), provider.TopLocationsRequest{Size: 10}); err != nil {
+		t.Errorf("Unexpected error getting raw locations, err %v", err)
+	}
+}
+
+func TestDefaultServiceNormalizesLocations(t *testing.T) {
+	r := newFakeRepository(50)
+	rnk := &fakeRanking{}
+	n := NewNormalizer(map[string][]string{"barcelona": {"bcn", "barcelona, spain"}})
+	s := NewWithConfig(r, rnk, Config{Normalizer: n})
+
+	for _, city := range []string{"Barcelona", "barcelona ", "BCN", "Barcelona, Spain"} {
+		_, err := s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: city, Size: 50})
+		if err != nil {
+			t.Fatalf("Unexpected error getting contributors, err %v", err)
+		}
+
+		if r.req.City != "barcelona" || len(r.req.Aliases) != 2 {
+			t.Errorf("Unexpected repository request on %q, got %s with aliases %v", city, r.req.City, r.req.Aliases)
+		}
+	}
+
+	for _, c := range rnk.increased {
+		if c != "barcelona" {
+			t.Errorf("Unexpected ranked city, got %s", c)
+		}
+	}
+
+	if _, err := s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "   ", Size: 50}); err != ErrEmptyCity {
+		t.Errorf("Unexpected error on blank city, expected %v got %v", ErrEmptyCity, err)
+	}
+}
+
+func TestDefaultServiceAdministratesAllRankings(t *testing.T) {
+	rnk, trending := &fakeRanking{}, &fakeRanking{}
+	n := NewNormalizer(map[string][]string{"barcelona": {"bcn"}})
+	s := NewWithConfig(newFakeRepository(50), rnk, Config{Trending: trending, Normalizer: n})
+
+	if err := s.RemoveLocation(context.Background(), " Madrid"); err != nil {
+		t.Fatalf("Unexpected error removing location, err %v", err)
+	}
+	if err := s.MergeLocations(context.Background(), "Barcelone", "BCN"); err != nil {
+		t.Fatalf("Unexpected error merging locations, err %v", err)
+	}
+	if err := s.ResetLocations(context.Background()); err != nil {
+		t.Fatalf("Unexpected error resetting locations, err %v", err)
+	}
+
+	for _, r := range []*fakeRanking{rnk, trending} {
+		if len(r.removed) != 1 || r.removed[0] != "madrid" {
+			t.Errorf("Unexpected removed cities, got %v", r.removed)
+		}
+		if len(r.merged) != 1 || r.merged[0] != "barcelone>barcelona" {
+			t.Errorf("Unexpected merged cities, got %v", r.merged)
+		}
+		if r.resets != 1 {
+			t.Errorf("Unexpected resets, got %d", r.resets)
+		}
+	}
+
+	if err := s.RemoveLocation(context.Background(), " "); err != ErrEmptyCity {
+		t.Errorf("Unexpected error on blank city, expected %v got %v", ErrEmptyCity, err)
+	}
+}
+
+func TestDefaultServiceCountPolicies(t *testing.T) {
+	errUpstream := errors.New("upstream failure")
+	tests := []struct {
+		policy   CountPolicy
+		items    int
+		err      error
+		expected int
+	}{
+		{CountOnRequest, 0, errUpstream, 1},
+		{CountOnSuccess, 0, errUpstream, 0},
+		{CountOnSuccess, 0, nil, 1},
+		{"", 0, errUpstream, 0},
+		{CountOnNonEmpty, 0, nil, 0},
+		{CountOnNonEmpty, 10, nil, 1},
+	}
+
+	for _, tt := range tests {
+		r := newFakeRepository(tt.items)
+		r.err = tt.err
+		rnk := &fakeRanking{}
+		s := NewWithConfig(r, rnk, Config{CountPolicy: tt.policy})
+
+		_, _ = s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "barcelona", Size: 50})
+		if len(rnk.increased) != tt.expected {
+			t.Errorf("Unexpected increases with policy %q and error %v, expected %d got %d", tt.policy, tt.err, tt.expected, len(rnk.increased))
+		}
+	}
+}
+
+func TestDefaultServiceTracksViewedContributors(t *testing.T) {
+	r := newFakeRepository(3)
+	viewed := &fakeViewedRanking{}
+	n := NewNormalizer(map[string][]string{"barcelona": {"bcn"}})
+	s := NewWithConfig(r, &fakeRanking{}, Config{Viewed: viewed, Normalizer: n})
+
+	_, _ = s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "BCN", Size: 50})
+	r.err = errors.New("upstream failure")
+	_, _ = s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "madrid", Size: 50})
+
+	if len(viewed.views) != 1 || viewed.views[0] != "barcelona:3" {
+		t.Errorf("Unexpected views, got %v", viewed.views)
+	}
+
+	if _, err := s.GetTopViewedContributors(context.Background(), provider.TopViewedContributorsRequest{City: "Bcn", Size: 10}); err != nil {
+		t.Fatalf("Unexpected error getting viewed contributors, err %v", err)
+	}
+	if viewed.city != "barcelona" {
+		t.Errorf("Unexpected viewed contributors city, got %s", viewed.city)
+	}
+
+	s = New(r, &fakeRanking{})
+	if _, err := s.GetTopViewedContributors(context.Background(), provider.TopViewedContributorsRequest{Size: 10}); err != ErrViewedUnavailable {
+		t.Errorf("Unexpected error, expected %v got %v", ErrViewedUnavailable, err)
+	}
+}
+
+type fakeViewedRanking struct {
+	views []string
+	city  string
+}
+
+func (f *fakeViewedRanking) IncreaseViews(ctx context.Context, city string, contributors []*provider.Contributor) error {
+	f.views = append(f.views, fmt.Sprintf("%s:%d", city, len(contributors)))
+	return nil
+}
+
+func (f *fakeViewedRanking) GetTopViewedContributors(ctx context.Context, r provider.TopViewedContributorsRequest) ([]*provider.ViewedContributor, error) {
+	f.city = r.City
+	return []*provider.ViewedContributor{{Name: "foo", Score: 100}}, nil
+}
+
+func TestDefaultServiceEmitsSearchEvents(t *testing.T) {
+	r := newFakeRepository(3)
+	events := &fakeEmitter{}
+	n := NewNormalizer(map[string][]string{"barcelona": {"bcn"}})
+	s := NewWithConfig(r, &fakeRanking{}, Config{Events: events, Normalizer: n})
+
+	ctx := provider.WithSearcher(provider.WithCacheStatus(context.Background()), "ip:10.0.0.1")
+	provider.SetCacheStatus(ctx, provider.CacheHit)
+	_, _ = s.GetTopContributors(ctx, provider.GithubTopRequest{City: "BCN", Size: 50, Sort: provider.SortByRepositories, Version: provider.APIv1})
+
+	r.items, r.err = nil, errors.New("upstream failure")
+	_, _ = s.GetTopContributors(context.Background(), provider.GithubTopRequest{City: "madrid", Size: 100})
+
+	if len(events.emitted) != 2 {
+		t.Fatalf("Unexpected emitted events, got %d", len(events.emitted))
+	}
+
+	e := events.emitted[0]
+	if e.RequestedCity != "BCN" || e.City != "barcelona" || e.Size != 50 || e.Client != "ip:10.0.0.1" ||
+		e.CacheStatus != provider.CacheHit || e.Results != 3 || e.Error != "" || e.Time.IsZero() {
+		t.Errorf("Unexpected search event, got %+v", e)
+	}
+
+	if e := events.emitted[1]; e.Error != "upstream failure" || e.Results != 0 || e.Client != provider.AnonymousSearcher {
+		t.Errorf("Unexpected failed search event, got %+v", e)
+	}
+}
+
+func TestDefaultServiceServesPastTopsWithoutRankingThem(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	rnk := &fakeRanking{}
+	h := &fakeHistory{}
+	n := NewNormalizer(map[string][]string{"barcelona": {"bcn"}})
+
+	if _, err := New(newFakeRepository(50), rnk).GetTopContributorsAt(ctx, provider.GithubTopRequest{City: "bcn", Size: 50}, at); err != ErrHistoryUnavailable {
+		t.Errorf("Unexpected error, expected %v got %v", ErrHistoryUnavailable, err)
+	}
+
+	s := NewWithConfig(newFakeRepository(50), rnk, Config{Normalizer: n, History: h})
+	if _, err := s.GetTopContributorsAt(ctx, provider.GithubTopRequest{City: "BCN", Size: 50}, at); err != nil {
+		t.Fatalf("Unexpected error getting past top, error %v", err)
+	}
+
+	if h.city != "barcelona" || !h.at.Equal(at) {
+		t.Errorf("Unexpected history lookup, city %s at %s", h.city, h.at)
+	}
+
+	if len(rnk.increased) != 0 {
+		t.Errorf("Unexpected past top lookup ranked, got %v", rnk.increased)
+	}
+}
+
+type fakeHistory struct {
+	city string
+	at   time.Time
+}
+
+func (f *fakeHistory) Record(_ context.Context, _ *provider.TopSnapshot) error {
+	return nil
+}
+
+func (f *fakeHistory) At(_ context.Context, city string, size int, t time.Time) (*provider.TopSnapshot, error) {
+	f.city, f.at = city, t
+
+	return &provider.TopSnapshot{City: city, Size: size, FetchedAt: t}, nil
+}
+
+type fakeEmitter struct {
+	emitted []*provider.SearchEvent
+}
+
+func (f *fakeEmitter) Emit(e *provider.SearchEvent) {
+	f.emitted = append(f.emitted, e)
+}
+
 type fakeRepository struct {
 	items []*provider.Contributor
+	req   provider.GithubTopRequest
+	err   error
 }
 
 func newFakeRepository(totalItems int) *fakeRepository {
@@ -73,17 +341,64 @@ func newFakeRepository(totalItems int) *fakeRepository {
 }
 
 func (f *fakeRepository) GetGithubTopContributors(ctx context.Context, req provider.GithubTopRequest) ([]*provider.Contributor, error) {
-	return f.items, nil
+	f.req = req
+	return f.items, f.err
 }
 
-type fakeRanking struct{}
+type fakeRanking struct {
+	mutex     sync.Mutex
+	increased []string
+	searchers []string
+	removed   []string
+	merged    []string
+	resets    int
+}
 
-func (f *fakeRanking) GetTopSearchedLocations(ctx context.Context, size int) ([]*provider.Location, error) {
+func (f *fakeRanking) GetTopSearchedLocations(ctx context.Context, r provider.TopLocationsRequest) ([]*provider.Location, error) {
 	return []*provider.Location{
 		{Name: "barcelona", Score: 1000}, {Name: "badalona", Score: 10},
 	}, nil
 }
 
+func (f *fakeRanking) GetLocationRank(ctx context.Context, city string) (*provider.Location, error) {
+	return &provider.Location{Name: city, Score: 1000}, nil
+}
+
 func (f *fakeRanking) IncreaseCityScore(ctx context.Context, city string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.increased = append(f.increased, city)
+	f.searchers = append(f.searchers, provider.SearcherFromContext(ctx))
+	return nil
+}
+
+func (f *fakeRanking) IncreaseCityScores(ctx context.Context, searches []provider.Search) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, s := range searches {
+		f.increased = append(f.increased, s.City)
+		f.searchers = append(f.searchers, s.Searcher)
+	}
+	return nil
+}
+
+func (f *fakeRanking) increases() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.increased...)
+}
+
+func (f *fakeRanking) RemoveCity(ctx context.Context, city string) error {
+	f.removed = append(f.removed, city)
+	return nil
+}
+
+func (f *fakeRanking) Reset(ctx context.Context) error {
+	f.resets++
+	return nil
+}
+
+func (f *fakeRanking) MergeCities(ctx context.Context, from, to string) error {
+	f.merged = append(f.merged, from+">"+to)
 	return nil
 }
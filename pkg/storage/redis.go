@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRedisURL used when no url is provided
+	DefaultRedisURL = "redis://localhost:6379"
+	// DefaultRedisPort used on hosts without port
+	DefaultRedisPort = "6379"
+	// HealthCheckTimeout bounds startup ping
+	HealthCheckTimeout = time.Second * 5
+)
+
+// ErrInvalidRedisURL happens on malformed connection urls
+var ErrInvalidRedisURL = errors.New("invalid redis url")
+
+// RedisConfig defines a redis connection, built from urls as:
+//
+//	redis://[user:pass@]host[:port][/db]                              single node
+//	rediss://[user:pass@]host[:port][/db]                             single node over TLS
+//	redis://[user:pass@]host1:port,host2:port?sentinel=master[&sentinel_password=pass]
+//	redis://[user:pass@]host1:port,host2:port[?cluster=true]
+type RedisConfig struct {
+	Options *redis.UniversalOptions
+	Cluster bool
+}
+
+// ParseRedisURL builds redis config from url, plain host:port values are accepted as single node urls
+func ParseRedisURL(rawURL string) (*RedisConfig, error) {
+	if rawURL == "" {
+		rawURL = DefaultRedisURL
+	}
+
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "redis://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRedisURL, err)
+	}
+
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("%w: unsupported scheme %s", ErrInvalidRedisURL, u.Scheme)
+	}
+
+	opts := &redis.UniversalOptions{}
+	if u.User != nil {
+		opts.Username = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+
+	for _, h := range strings.Split(u.Host, ",") {
+		addr, err := hostAddress(h)
+		if err != nil {
+			return nil, err
+		}
+		opts.Addrs = append(opts.Addrs, addr)
+	}
+
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		opts.DB, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid database %s", ErrInvalidRedisURL, db)
+		}
+	}
+
+	if u.Scheme == "rediss" {
+		host, _, _ := net.SplitHostPort(opts.Addrs[0])
+		opts.TLSConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+
+	q := u.Query()
+	opts.MasterName = q.Get("sentinel")
+	opts.SentinelPassword = q.Get("sentinel_password")
+
+	cluster := len(opts.Addrs) > 1 && opts.MasterName == ""
+	if v := q.Get("cluster"); v != "" {
+		cluster, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid cluster value %s", ErrInvalidRedisURL, v)
+		}
+	}
+
+	if cluster && opts.MasterName != "" {
+		return nil, fmt.Errorf("%w: sentinel and cluster modes are exclusive", ErrInvalidRedisURL)
+	}
+
+	if cluster && opts.DB != 0 {
+		return nil, fmt.Errorf("%w: cluster mode does not support database selection", ErrInvalidRedisURL)
+	}
+
+	if !cluster && opts.MasterName == "" && len(opts.Addrs) > 1 {
+		return nil, fmt.Errorf("%w: multiple hosts require sentinel or cluster mode", ErrInvalidRedisURL)
+	}
+
+	return &RedisConfig{Options: opts, Cluster: cluster}, nil
+}
+
+// NewRedisClient instantiates a redis client from url and checks its health
+func NewRedisClient(ctx context.Context, rawURL string) (redis.UniversalClient, error) {
+	cfg, err := ParseRedisURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cl := cfg.Client()
+	if err := HealthCheck(ctx, cl); err != nil {
+		_ = cl.Close()
+
+		return nil, err
+	}
+
+	return cl, nil
+}
+
+// Client instantiates redis client on configured mode
+func (c *RedisConfig) Client() redis.UniversalClient {
+	switch {
+	case c.Options.MasterName != "":
+		return redis.NewFailoverClient(c.Options.Failover())
+	case c.Cluster:
+		return redis.NewClusterClient(c.Options.Cluster())
+	}
+
+	return redis.NewClient(c.Options.Simple())
+}
+
+// HealthCheck pings redis
+func HealthCheck(ctx context.Context, cl redis.UniversalClient) error {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	if err := cl.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis health check failed, error %w", err)
+	}
+
+	return nil
+}
+
+func hostAddress(h string) (string, error) {
+	if h == "" {
+		return "", fmt.Errorf("%w: empty host", ErrInvalidRedisURL)
+	}
+
+	if _, _, err := net.SplitHostPort(h); err == nil {
+		return h, nil
+	}
+
+	return net.JoinHostPort(h, DefaultRedisPort), nil
+}